@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"backend-nagaricare/config"
+	"crypto/rand"
+	"fmt"
+	"log"
+)
+
+// FromConfig builds the Google ID token verifier used by the sign-in endpoint
+// and the issuer of our own session tokens
+func FromConfig(cfg config.AuthConfig) (*GoogleVerifier, *TokenIssuer, error) {
+	var keys KeySource
+	if cfg.GoogleJWKSFile != "" {
+		// A local JWKS file takes precedence, handy for offline tests
+		fileKeys, err := NewFileKeySource(cfg.GoogleJWKSFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load JWKS file: %w", err)
+		}
+		keys = fileKeys
+	} else {
+		keys = NewURLKeySource(cfg.GoogleJWKSURL)
+	}
+
+	if len(cfg.GoogleClientIDs) == 0 {
+		log.Println("No Google client IDs configured, Google sign-in will reject every token")
+	}
+	google := NewGoogleVerifier(keys, cfg.GoogleClientIDs)
+
+	secret := []byte(cfg.TokenSecret)
+	if len(secret) == 0 {
+		// Fall back to a random secret so development still works; sessions won't survive a restart
+		log.Println("No token secret configured, using a random secret")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, fmt.Errorf("generate token secret: %w", err)
+		}
+	}
+	tokens := NewTokenIssuer(secret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+
+	return google, tokens, nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// GoogleClaims holds the fields we use from a Google/Firebase ID token
+type GoogleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// GoogleVerifier checks the signature and claims of Google/Firebase ID tokens
+type GoogleVerifier struct {
+	keys      KeySource
+	audiences []string
+	issuers   []string
+}
+
+// NewGoogleVerifier creates a verifier that accepts tokens issued for any of
+// the given audiences (OAuth client IDs or Firebase project IDs)
+func NewGoogleVerifier(keys KeySource, audiences []string) *GoogleVerifier {
+	issuers := []string{"accounts.google.com", "https://accounts.google.com"}
+	for _, aud := range audiences {
+		// Firebase Authentication tokens are issued per project
+		issuers = append(issuers, "https://securetoken.google.com/"+aud)
+	}
+
+	return &GoogleVerifier{
+		keys:      keys,
+		audiences: audiences,
+		issuers:   issuers,
+	}
+}
+
+// Verify validates an ID token and returns its claims
+func (v *GoogleVerifier) Verify(idToken string) (*GoogleClaims, error) {
+	if len(v.audiences) == 0 {
+		return nil, errors.New("no Google client ID configured")
+	}
+
+	claims := &GoogleClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	// Check the token was issued by Google for one of our clients
+	if !slices.Contains(v.issuers, claims.Issuer) {
+		return nil, fmt.Errorf("invalid ID token: unexpected issuer %q", claims.Issuer)
+	}
+	if !slices.ContainsFunc(v.audiences, func(aud string) bool { return slices.Contains(claims.Audience, aud) }) {
+		return nil, errors.New("invalid ID token: unexpected audience")
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errors.New("invalid ID token: email is missing or not verified")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "test-client.apps.googleusercontent.com"
+
+// staticKeys serves testKey as the only JWKS key
+type staticKeys struct{}
+
+func (staticKeys) Key(kid string) (*rsa.PublicKey, error) {
+	if kid != testKeyID {
+		return nil, ErrUnknownKey
+	}
+	return &testKey.PublicKey, nil
+}
+
+// validGoogleClaims are the claims of an ID token Google issued for our client
+func validGoogleClaims() GoogleClaims {
+	now := time.Now()
+	return GoogleClaims{
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://accounts.google.com",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+// signGoogle signs claims with testKey under kid
+func signGoogle(t *testing.T, method jwt.SigningMethod, kid string, claims GoogleClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	var key any = testKey
+	switch method {
+	case jwt.SigningMethodHS256:
+		// The classic confusion attack: HMAC keyed with the public key
+		key = testKey.PublicKey.N.Bytes()
+	case jwt.SigningMethodNone:
+		key = jwt.UnsafeAllowNoneSignatureType
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestGoogleVerify(t *testing.T) {
+	otherKey := mustGenerateKey()
+
+	for _, tc := range []struct {
+		name  string
+		token func(t *testing.T) string
+		err   string
+	}{
+		{
+			name: "valid",
+			token: func(t *testing.T) string {
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, validGoogleClaims())
+			},
+		},
+		{
+			name: "bare issuer",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.Issuer = "accounts.google.com"
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+		},
+		{
+			name: "firebase issuer",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.Issuer = "https://securetoken.google.com/" + testClientID
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+		},
+		{
+			name: "one of several audiences",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.Audience = jwt.ClaimStrings{"someone-else", testClientID}
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+		},
+		{
+			name: "hmac signed with the public key",
+			token: func(t *testing.T) string {
+				return signGoogle(t, jwt.SigningMethodHS256, testKeyID, validGoogleClaims())
+			},
+			err: "signing method HS256 is invalid",
+		},
+		{
+			name:  "unsigned",
+			token: func(t *testing.T) string { return signGoogle(t, jwt.SigningMethodNone, testKeyID, validGoogleClaims()) },
+			err:   "signing method none is invalid",
+		},
+		{
+			name: "unknown kid",
+			token: func(t *testing.T) string {
+				return signGoogle(t, jwt.SigningMethodRS256, "rotated", validGoogleClaims())
+			},
+			err: ErrUnknownKey.Error(),
+		},
+		{
+			name: "signed by another key",
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, validGoogleClaims())
+				token.Header["kid"] = testKeyID
+				signed, err := token.SignedString(otherKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return signed
+			},
+			err: "verification error",
+		},
+		{
+			name: "foreign issuer",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.Issuer = "https://evil.example.com"
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "unexpected issuer",
+		},
+		{
+			name: "firebase issuer of another project",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.Issuer = "https://securetoken.google.com/other-project"
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "unexpected issuer",
+		},
+		{
+			name: "foreign audience",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.Audience = jwt.ClaimStrings{"other-client.apps.googleusercontent.com"}
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "unexpected audience",
+		},
+		{
+			name: "unverified email",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.EmailVerified = false
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "not verified",
+		},
+		{
+			name: "missing email",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.Email = ""
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "email is missing",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "token is expired",
+		},
+		{
+			name: "no expiry",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.ExpiresAt = nil
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "exp claim is required",
+		},
+		{
+			name: "issued in the future",
+			token: func(t *testing.T) string {
+				claims := validGoogleClaims()
+				claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+				return signGoogle(t, jwt.SigningMethodRS256, testKeyID, claims)
+			},
+			err: "token used before issued",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := NewGoogleVerifier(staticKeys{}, []string{testClientID}).Verify(tc.token(t))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Email != "alice@example.com" || claims.Name != "Alice" {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestGoogleVerifyWithoutClientIDs(t *testing.T) {
+	token := signGoogle(t, jwt.SigningMethodRS256, testKeyID, validGoogleClaims())
+	if _, err := NewGoogleVerifier(staticKeys{}, nil).Verify(token); err == nil {
+		t.Error("expected every token to be rejected without a client ID")
+	}
+}
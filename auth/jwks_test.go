@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testKeyID = "test-key"
+
+// testKey stands in for Google's signing key
+var testKey = mustGenerateKey()
+
+func mustGenerateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// publicJWK encodes the public half of key the way Google publishes it
+func publicJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{
+		Kid: kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// jwksDocument renders keys as a JWKS document
+func jwksDocument(t *testing.T, keys ...jwk) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		kids []string
+		err  string
+	}{
+		{
+			name: "rsa keys",
+			data: string(jwksDocument(t, publicJWK("one", testKey), publicJWK("two", testKey))),
+			kids: []string{"one", "two"},
+		},
+		{
+			name: "other key types skipped",
+			data: string(jwksDocument(t, jwk{Kid: "ec", Kty: "EC"}, publicJWK("rsa", testKey))),
+			kids: []string{"rsa"},
+		},
+		{name: "not json", data: "<html>", err: "decode jwks"},
+		{name: "no rsa key", data: string(jwksDocument(t, jwk{Kid: "ec", Kty: "EC"})), err: "no RSA keys"},
+		{name: "bad modulus", data: `{"keys":[{"kid":"k","kty":"RSA","n":"!!","e":"AQAB"}]}`, err: "decode modulus"},
+		{name: "bad exponent", data: `{"keys":[{"kid":"k","kty":"RSA","n":"AQAB","e":"!!"}]}`, err: "decode exponent"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			keys, err := parseJWKS([]byte(tc.data))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != len(tc.kids) {
+				t.Fatalf("keys = %v, want %v", keys, tc.kids)
+			}
+			for _, kid := range tc.kids {
+				if !keys[kid].Equal(&testKey.PublicKey) {
+					t.Errorf("key %q does not match the signing key", kid)
+				}
+			}
+		})
+	}
+}
+
+func TestFileKeySource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksDocument(t, publicJWK(testKeyID, testKey)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := NewFileKeySource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := keys.Key(testKeyID); err != nil || !key.Equal(&testKey.PublicKey) {
+		t.Errorf("key = %v, err = %v", key, err)
+	}
+	if _, err := keys.Key("other"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+
+	if _, err := NewFileKeySource(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestURLKeySourceCaches(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(jwksDocument(t, publicJWK(testKeyID, testKey)))
+	}))
+	defer srv.Close()
+
+	keys := NewURLKeySource(srv.URL)
+	for range 3 {
+		if key, err := keys.Key(testKeyID); err != nil || !key.Equal(&testKey.PublicKey) {
+			t.Fatalf("key = %v, err = %v", key, err)
+		}
+	}
+	// An unknown kid right after a fetch must not trigger another one
+	if _, err := keys.Key("bogus"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+}
+
+func TestURLKeySourceRejectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if _, err := NewURLKeySource(srv.URL).Key(testKeyID); err == nil || !strings.Contains(err.Error(), "unexpected status") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import "backend-nagaricare/models"
+
+// Permission names an action guarded by role-based access control
+type Permission string
+
+const (
+	PermPostCreate       Permission = "post:create"
+	PermPostUpdateAny    Permission = "post:update:any"
+	PermPostDeleteAny    Permission = "post:delete:any"
+	PermCommentCreate    Permission = "comment:create"
+	PermCommentUpdateAny Permission = "comment:update:any"
+	PermCommentDeleteAny Permission = "comment:delete:any"
+	PermUserList         Permission = "user:list"
+	PermUserCreate       Permission = "user:create"
+	PermUserUpdateAny    Permission = "user:update:any"
+	PermUserRoleUpdate   Permission = "user:role:update"
+	PermUserDeleteAny    Permission = "user:delete:any"
+	PermTrashManage      Permission = "trash:manage"
+	PermAuditRead        Permission = "audit:read"
+	PermTicketWork       Permission = "ticket:work"   // Move any ticket through its statuses and take it
+	PermTicketAssign     Permission = "ticket:assign" // Assign tickets to any agent
+	PermSLAManage        Permission = "sla:manage"
+	PermCategoryManage   Permission = "category:manage"
+	PermTagManage        Permission = "tag:manage" // Rename and merge tags across all posts
+)
+
+// rolePermissions is the permission matrix; actions on one's own posts and
+// profile are always allowed and are not listed here
+var rolePermissions = map[string][]Permission{
+	models.RoleCustomer: {
+		PermPostCreate,
+		PermCommentCreate,
+	},
+	models.RoleAgent: {
+		PermPostCreate,
+		PermCommentCreate,
+		PermUserList,
+		PermTicketWork,
+	},
+	models.RoleModerator: {
+		PermPostCreate,
+		PermPostUpdateAny,
+		PermPostDeleteAny,
+		PermCommentCreate,
+		PermCommentUpdateAny,
+		PermCommentDeleteAny,
+		PermUserList,
+		PermTicketWork,
+		PermTicketAssign,
+	},
+	models.RoleAdmin: {
+		PermPostCreate,
+		PermPostUpdateAny,
+		PermPostDeleteAny,
+		PermCommentCreate,
+		PermCommentUpdateAny,
+		PermCommentDeleteAny,
+		PermUserList,
+		PermUserCreate,
+		PermUserUpdateAny,
+		PermUserRoleUpdate,
+		PermUserDeleteAny,
+		PermTrashManage,
+		PermAuditRead,
+		PermTicketWork,
+		PermTicketAssign,
+		PermSLAManage,
+		PermCategoryManage,
+		PermTagManage,
+	},
+}
+
+// HasPermission reports whether the given role grants the permission
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
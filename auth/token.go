@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token types carried in the "typ" claim so a refresh token can never be
+// used as an access token and vice versa
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+const tokenIssuer = "nagaricare"
+
+// SessionClaims are the claims of the tokens we issue ourselves
+type SessionClaims struct {
+	Type string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned to the client after a successful sign-in
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// TokenIssuer signs and validates session tokens tied to users.id_user
+type TokenIssuer struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewTokenIssuer creates an issuer signing tokens with the given HMAC secret
+func NewTokenIssuer(secret []byte, accessTTL, refreshTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		secret:     secret,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue creates a new access and refresh token for the given user
+func (i *TokenIssuer) Issue(userID int) (TokenPair, error) {
+	access, err := i.sign(userID, TokenTypeAccess, i.accessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := i.sign(userID, TokenTypeRefresh, i.refreshTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(i.accessTTL.Seconds()),
+	}, nil
+}
+
+// ParseAccess validates an access token and returns the user ID it was issued for
+func (i *TokenIssuer) ParseAccess(token string) (int, error) {
+	return i.parse(token, TokenTypeAccess)
+}
+
+// ParseRefresh validates a refresh token and returns the user ID it was issued for
+func (i *TokenIssuer) ParseRefresh(token string) (int, error) {
+	return i.parse(token, TokenTypeRefresh)
+}
+
+func (i *TokenIssuer) sign(userID int, typ string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := SessionClaims{
+		Type: typ,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+	if err != nil {
+		return "", fmt.Errorf("sign %s token: %w", typ, err)
+	}
+	return signed, nil
+}
+
+func (i *TokenIssuer) parse(token, typ string) (int, error) {
+	claims := &SessionClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return i.secret, nil
+	},
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s token: %w", typ, err)
+	}
+
+	if claims.Type != typ {
+		return 0, fmt.Errorf("invalid %s token: got %q token", typ, claims.Type)
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, errors.New("invalid " + typ + " token: malformed subject")
+	}
+	return userID, nil
+}
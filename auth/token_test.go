@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("test-secret-test-secret-test-secret")
+
+// signSession signs claims the way TokenIssuer does, with secret
+func signSession(t *testing.T, method jwt.SigningMethod, secret []byte, claims SessionClaims) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(method, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// sessionClaims are the claims of a live token of type typ for user 7
+func sessionClaims(typ string) SessionClaims {
+	now := time.Now()
+	return SessionClaims{
+		Type: typ,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   "7",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func TestIssueAndParse(t *testing.T) {
+	tokens := NewTokenIssuer(testSecret, 15*time.Minute, time.Hour)
+	pair, err := tokens.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != 900 {
+		t.Errorf("pair = %+v", pair)
+	}
+
+	if userID, err := tokens.ParseAccess(pair.AccessToken); err != nil || userID != 7 {
+		t.Errorf("access: user = %d, err = %v", userID, err)
+	}
+	if userID, err := tokens.ParseRefresh(pair.RefreshToken); err != nil || userID != 7 {
+		t.Errorf("refresh: user = %d, err = %v", userID, err)
+	}
+
+	// Each token only works for its own purpose
+	if _, err := tokens.ParseAccess(pair.RefreshToken); err == nil || !strings.Contains(err.Error(), `got "refresh" token`) {
+		t.Errorf("refresh token accepted as access token: %v", err)
+	}
+	if _, err := tokens.ParseRefresh(pair.AccessToken); err == nil || !strings.Contains(err.Error(), `got "access" token`) {
+		t.Errorf("access token accepted as refresh token: %v", err)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tokens := NewTokenIssuer(testSecret, 15*time.Minute, time.Hour)
+
+	for _, tc := range []struct {
+		name  string
+		token func(t *testing.T) string
+		err   string
+	}{
+		{
+			name: "other secret",
+			token: func(t *testing.T) string {
+				return signSession(t, jwt.SigningMethodHS256, []byte("another-secret-another-secret-xx"), sessionClaims(TokenTypeAccess))
+			},
+			err: "signature is invalid",
+		},
+		{
+			name: "other hmac method",
+			token: func(t *testing.T) string {
+				return signSession(t, jwt.SigningMethodHS512, testSecret, sessionClaims(TokenTypeAccess))
+			},
+			err: "signing method HS512 is invalid",
+		},
+		{
+			name: "foreign issuer",
+			token: func(t *testing.T) string {
+				claims := sessionClaims(TokenTypeAccess)
+				claims.Issuer = "accounts.google.com"
+				return signSession(t, jwt.SigningMethodHS256, testSecret, claims)
+			},
+			err: "invalid issuer",
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				claims := sessionClaims(TokenTypeAccess)
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return signSession(t, jwt.SigningMethodHS256, testSecret, claims)
+			},
+			err: "token is expired",
+		},
+		{
+			name: "no expiry",
+			token: func(t *testing.T) string {
+				claims := sessionClaims(TokenTypeAccess)
+				claims.ExpiresAt = nil
+				return signSession(t, jwt.SigningMethodHS256, testSecret, claims)
+			},
+			err: "exp claim is required",
+		},
+		{
+			name: "no type",
+			token: func(t *testing.T) string {
+				return signSession(t, jwt.SigningMethodHS256, testSecret, sessionClaims(""))
+			},
+			err: `got "" token`,
+		},
+		{
+			name: "malformed subject",
+			token: func(t *testing.T) string {
+				claims := sessionClaims(TokenTypeAccess)
+				claims.Subject = "alice"
+				return signSession(t, jwt.SigningMethodHS256, testSecret, claims)
+			},
+			err: "malformed subject",
+		},
+		{
+			name:  "garbage",
+			token: func(t *testing.T) string { return "not.a.token" },
+			err:   "invalid access token",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tokens.ParseAccess(tc.token(t)); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("err = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
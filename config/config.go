@@ -0,0 +1,415 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the typed configuration of the service
+type Config struct {
+	Database    DatabaseConfig   `yaml:"database"`
+	Server      ServerConfig     `yaml:"server"`
+	Uploads     UploadConfig     `yaml:"uploads"`
+	Attachments AttachmentConfig `yaml:"attachments"`
+	Trash       TrashConfig      `yaml:"trash"`
+	SLA         SLAConfig        `yaml:"sla"`
+	Notify      NotifyConfig     `yaml:"notify"`
+	Storage     StorageConfig    `yaml:"storage"`
+	Auth        AuthConfig       `yaml:"auth"`
+	CORS        CORSConfig       `yaml:"cors"`
+}
+
+// DatabaseConfig configures the MySQL connection pool
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"`
+	DSNFile         string        `yaml:"dsn_file"` // Read the DSN from this file instead
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	ListenAddr  string `yaml:"listen_addr"`
+	AutoMigrate bool   `yaml:"auto_migrate"` // Apply pending migrations at startup
+}
+
+// UploadConfig configures how large uploaded files may be
+type UploadConfig struct {
+	MaxBytes     int64         `yaml:"max_bytes"`
+	MaxDimension int           `yaml:"max_dimension"` // Maximum image width and height in pixels
+	CacheDir     string        `yaml:"cache_dir"`     // Image sizes resized on demand
+	CacheMaxAge  time.Duration `yaml:"cache_max_age"` // How long clients may reuse a picture before revalidating
+}
+
+// AttachmentConfig sets the quotas on files attached to posts
+type AttachmentConfig struct {
+	MaxBytes      int64 `yaml:"max_bytes"`       // Largest single file
+	MaxPerPost    int   `yaml:"max_per_post"`    // Most files on one post
+	MaxTotalBytes int64 `yaml:"max_total_bytes"` // Combined size of the files on one post
+}
+
+// TrashConfig configures how long deleted posts and users can be restored
+type TrashConfig struct {
+	Retention     time.Duration `yaml:"retention"`      // Trashed items older than this are purged
+	PurgeInterval time.Duration `yaml:"purge_interval"` // How often the purge runs
+}
+
+// SLAConfig configures the scheduler that flags tickets missing a deadline
+type SLAConfig struct {
+	CheckInterval time.Duration `yaml:"check_interval"` // How often deadlines are checked
+	AtRiskWindow  time.Duration `yaml:"at_risk_window"` // Tickets due within this window are at risk
+}
+
+// NotifyConfig configures where SLA notifications are sent
+type NotifyConfig struct {
+	WebhookURL string `yaml:"webhook_url"` // POST each notification as JSON here; logged when empty
+}
+
+// Storage backends
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// StorageConfig configures where uploaded files are kept
+type StorageConfig struct {
+	Backend       string   `yaml:"backend"`         // "local" or "s3"
+	LocalDir      string   `yaml:"local_dir"`       // Root directory of the local backend
+	URLSecret     string   `yaml:"url_secret"`      // Signs download URLs of the local backend
+	URLSecretFile string   `yaml:"url_secret_file"` // Read the URL secret from this file instead
+	S3            S3Config `yaml:"s3"`
+}
+
+// S3Config configures an S3-compatible bucket such as AWS S3, MinIO or GCS
+// in interoperability mode
+type S3Config struct {
+	Endpoint            string `yaml:"endpoint"` // e.g. https://s3.eu-west-1.amazonaws.com
+	Region              string `yaml:"region"`
+	Bucket              string `yaml:"bucket"`
+	AccessKeyID         string `yaml:"access_key_id"`
+	SecretAccessKey     string `yaml:"secret_access_key"`
+	SecretAccessKeyFile string `yaml:"secret_access_key_file"`
+	PathStyle           bool   `yaml:"path_style"` // Address the bucket as /<bucket>/<key>, needed by MinIO
+}
+
+// AuthConfig configures Google sign-in and our session tokens
+type AuthConfig struct {
+	TokenSecret     string        `yaml:"token_secret"`
+	TokenSecretFile string        `yaml:"token_secret_file"` // Read the secret from this file instead
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	GoogleClientIDs []string      `yaml:"google_client_ids"`
+	GoogleJWKSURL   string        `yaml:"google_jwks_url"`
+	GoogleJWKSFile  string        `yaml:"google_jwks_file"`
+}
+
+// CORSConfig lists the browser origins allowed to call the API
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// Default returns the configuration used when nothing else is set
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			DSN:             "root:@tcp(127.0.0.1:3306)/forum_posts",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Server: ServerConfig{
+			ListenAddr: ":3000",
+		},
+		Uploads: UploadConfig{
+			MaxBytes:     5 << 20,
+			MaxDimension: 4096,
+			CacheDir:     "./cache/images",
+			CacheMaxAge:  5 * time.Minute,
+		},
+		Attachments: AttachmentConfig{
+			MaxBytes:      10 << 20,
+			MaxPerPost:    5,
+			MaxTotalBytes: 25 << 20,
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		SLA: SLAConfig{
+			CheckInterval: time.Minute,
+			AtRiskWindow:  time.Hour,
+		},
+		Storage: StorageConfig{
+			Backend:  StorageLocal,
+			LocalDir: "./uploads",
+			S3: S3Config{
+				Region: "us-east-1",
+			},
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+			GoogleJWKSURL:   "https://www.googleapis.com/oauth2/v3/certs", // Keys Google signs ID tokens with
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the YAML file at path
+// (skipped when empty), then environment variables, and validates the result
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.readSecretFiles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings with the environment variables that are set
+func (cfg *Config) applyEnv() error {
+	var errs []error
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = splitList(v)
+		}
+	}
+	integer := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			*dst = n
+		}
+	}
+	integer64 := func(name string, dst *int64) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			*dst = n
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			*dst = b
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			*dst = d
+		}
+	}
+
+	str("DB_DSN", &cfg.Database.DSN)
+	str("DB_DSN_FILE", &cfg.Database.DSNFile)
+	integer("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	integer("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime)
+
+	str("LISTEN_ADDR", &cfg.Server.ListenAddr)
+	boolean("AUTO_MIGRATE", &cfg.Server.AutoMigrate)
+
+	integer64("UPLOAD_MAX_BYTES", &cfg.Uploads.MaxBytes)
+	integer("UPLOAD_MAX_DIMENSION", &cfg.Uploads.MaxDimension)
+	str("UPLOAD_CACHE_DIR", &cfg.Uploads.CacheDir)
+	duration("UPLOAD_CACHE_MAX_AGE", &cfg.Uploads.CacheMaxAge)
+	integer64("ATTACHMENT_MAX_BYTES", &cfg.Attachments.MaxBytes)
+	integer("ATTACHMENT_MAX_PER_POST", &cfg.Attachments.MaxPerPost)
+	integer64("ATTACHMENT_MAX_TOTAL_BYTES", &cfg.Attachments.MaxTotalBytes)
+	duration("TRASH_RETENTION", &cfg.Trash.Retention)
+	duration("TRASH_PURGE_INTERVAL", &cfg.Trash.PurgeInterval)
+	duration("SLA_CHECK_INTERVAL", &cfg.SLA.CheckInterval)
+	duration("SLA_AT_RISK_WINDOW", &cfg.SLA.AtRiskWindow)
+	str("NOTIFY_WEBHOOK_URL", &cfg.Notify.WebhookURL)
+
+	str("STORAGE_BACKEND", &cfg.Storage.Backend)
+	str("STORAGE_LOCAL_DIR", &cfg.Storage.LocalDir)
+	str("STORAGE_URL_SECRET", &cfg.Storage.URLSecret)
+	str("STORAGE_URL_SECRET_FILE", &cfg.Storage.URLSecretFile)
+	str("S3_ENDPOINT", &cfg.Storage.S3.Endpoint)
+	str("S3_REGION", &cfg.Storage.S3.Region)
+	str("S3_BUCKET", &cfg.Storage.S3.Bucket)
+	str("S3_ACCESS_KEY_ID", &cfg.Storage.S3.AccessKeyID)
+	str("S3_SECRET_ACCESS_KEY", &cfg.Storage.S3.SecretAccessKey)
+	str("S3_SECRET_ACCESS_KEY_FILE", &cfg.Storage.S3.SecretAccessKeyFile)
+	boolean("S3_PATH_STYLE", &cfg.Storage.S3.PathStyle)
+
+	str("AUTH_TOKEN_SECRET", &cfg.Auth.TokenSecret)
+	str("AUTH_TOKEN_SECRET_FILE", &cfg.Auth.TokenSecretFile)
+	duration("ACCESS_TOKEN_TTL", &cfg.Auth.AccessTokenTTL)
+	duration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL)
+	list("GOOGLE_CLIENT_IDS", &cfg.Auth.GoogleClientIDs)
+	str("GOOGLE_JWKS_URL", &cfg.Auth.GoogleJWKSURL)
+	str("GOOGLE_JWKS_FILE", &cfg.Auth.GoogleJWKSFile)
+
+	list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+
+	return errors.Join(errs...)
+}
+
+// readSecretFiles replaces secrets with the content of their *_file setting,
+// which works with Docker and Kubernetes secrets mounted as files
+func (cfg *Config) readSecretFiles() error {
+	read := func(path string, dst *string) error {
+		if path == "" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read secret file: %w", err)
+		}
+		*dst = strings.TrimSpace(string(data))
+		return nil
+	}
+
+	return errors.Join(
+		read(cfg.Database.DSNFile, &cfg.Database.DSN),
+		read(cfg.Auth.TokenSecretFile, &cfg.Auth.TokenSecret),
+		read(cfg.Storage.URLSecretFile, &cfg.Storage.URLSecret),
+		read(cfg.Storage.S3.SecretAccessKeyFile, &cfg.Storage.S3.SecretAccessKey),
+	)
+}
+
+// Validate reports every invalid setting at once
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, msg string) {
+		if !ok {
+			errs = append(errs, errors.New(msg))
+		}
+	}
+
+	_, err := mysql.ParseDSN(cfg.Database.DSN)
+	check(cfg.Database.DSN != "" && err == nil, "database.dsn must be a valid MySQL DSN")
+	check(cfg.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(cfg.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(cfg.Database.MaxOpenConns == 0 || cfg.Database.MaxIdleConns <= cfg.Database.MaxOpenConns,
+		"database.max_idle_conns must not exceed database.max_open_conns")
+	check(cfg.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+
+	check(cfg.Server.ListenAddr != "", "server.listen_addr is required")
+
+	check(cfg.Uploads.MaxBytes > 0, "uploads.max_bytes must be positive")
+	check(cfg.Uploads.MaxDimension > 0, "uploads.max_dimension must be positive")
+	check(cfg.Uploads.CacheDir != "", "uploads.cache_dir is required")
+	check(cfg.Uploads.CacheMaxAge >= 0, "uploads.cache_max_age must not be negative")
+	check(cfg.Attachments.MaxBytes > 0, "attachments.max_bytes must be positive")
+	check(cfg.Attachments.MaxPerPost > 0, "attachments.max_per_post must be positive")
+	check(cfg.Attachments.MaxTotalBytes >= cfg.Attachments.MaxBytes, "attachments.max_total_bytes must be at least attachments.max_bytes")
+	check(cfg.Trash.Retention > 0, "trash.retention must be positive")
+	check(cfg.Trash.PurgeInterval > 0, "trash.purge_interval must be positive")
+	check(cfg.SLA.CheckInterval > 0, "sla.check_interval must be positive")
+	check(cfg.SLA.AtRiskWindow > 0, "sla.at_risk_window must be positive")
+	if cfg.Notify.WebhookURL != "" {
+		u, err := url.Parse(cfg.Notify.WebhookURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "notify.webhook_url must be an http or https URL")
+	}
+
+	switch cfg.Storage.Backend {
+	case StorageLocal:
+		check(cfg.Storage.LocalDir != "", "storage.local_dir is required for the local backend")
+	case StorageS3:
+		check(cfg.Storage.S3.Endpoint != "", "storage.s3.endpoint is required for the s3 backend")
+		check(cfg.Storage.S3.Region != "", "storage.s3.region is required for the s3 backend")
+		check(cfg.Storage.S3.Bucket != "", "storage.s3.bucket is required for the s3 backend")
+		check(cfg.Storage.S3.AccessKeyID != "" && cfg.Storage.S3.SecretAccessKey != "", "storage.s3 credentials are required for the s3 backend")
+	default:
+		check(false, "storage.backend must be local or s3")
+	}
+
+	check(cfg.Auth.TokenSecret == "" || len(cfg.Auth.TokenSecret) >= 32, "auth.token_secret must be at least 32 characters")
+	check(cfg.Auth.AccessTokenTTL > 0, "auth.access_token_ttl must be positive")
+	check(cfg.Auth.RefreshTokenTTL > cfg.Auth.AccessTokenTTL, "auth.refresh_token_ttl must be longer than auth.access_token_ttl")
+	check(cfg.Auth.GoogleJWKSURL != "" || cfg.Auth.GoogleJWKSFile != "", "auth.google_jwks_url or auth.google_jwks_file is required")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted renders the configuration as YAML with secrets masked, for logging
+func (cfg *Config) Redacted() string {
+	redacted := *cfg
+	if dsn, err := mysql.ParseDSN(cfg.Database.DSN); err == nil {
+		if dsn.Passwd != "" {
+			dsn.Passwd = "REDACTED"
+		}
+		redacted.Database.DSN = dsn.FormatDSN()
+	} else if cfg.Database.DSN != "" {
+		redacted.Database.DSN = "REDACTED"
+	}
+	if cfg.Auth.TokenSecret != "" {
+		redacted.Auth.TokenSecret = "REDACTED"
+	}
+	if cfg.Storage.URLSecret != "" {
+		redacted.Storage.URLSecret = "REDACTED"
+	}
+	if cfg.Storage.S3.SecretAccessKey != "" {
+		redacted.Storage.S3.SecretAccessKey = "REDACTED"
+	}
+	// Webhook URLs carry their secret token in the path or query, so only
+	// the host is kept
+	if u, err := url.Parse(cfg.Notify.WebhookURL); err == nil && u.Host != "" {
+		redacted.Notify.WebhookURL = u.Scheme + "://" + u.Host + "/REDACTED"
+	} else if cfg.Notify.WebhookURL != "" {
+		redacted.Notify.WebhookURL = "REDACTED"
+	}
+
+	out, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return "<unprintable config: " + err.Error() + ">"
+	}
+	return string(out)
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
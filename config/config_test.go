@@ -0,0 +1,246 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to a file in a temporary directory and returns
+// its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+database:
+  dsn: yaml:secret@tcp(db:3306)/forum
+  max_open_conns: 50
+server:
+  listen_addr: ":8080"
+auth:
+  token_secret: yaml-secret-yaml-secret-yaml-secret
+`)
+
+	for _, tc := range []struct {
+		name       string
+		env        map[string]string
+		dsn        string
+		listenAddr string
+		secret     string
+	}{
+		{
+			name:       "yaml over defaults",
+			dsn:        "yaml:secret@tcp(db:3306)/forum",
+			listenAddr: ":8080",
+			secret:     "yaml-secret-yaml-secret-yaml-secret",
+		},
+		{
+			name:       "env over yaml",
+			env:        map[string]string{"DB_DSN": "env:secret@tcp(db:3306)/forum", "LISTEN_ADDR": ":9090"},
+			dsn:        "env:secret@tcp(db:3306)/forum",
+			listenAddr: ":9090",
+			secret:     "yaml-secret-yaml-secret-yaml-secret",
+		},
+		{
+			name: "file over env",
+			env: map[string]string{
+				"DB_DSN":                 "env:secret@tcp(db:3306)/forum",
+				"DB_DSN_FILE":            writeFile(t, "dsn", "file:secret@tcp(db:3306)/forum\n"),
+				"AUTH_TOKEN_SECRET":      "env-secret-env-secret-env-secret-env",
+				"AUTH_TOKEN_SECRET_FILE": writeFile(t, "token", "file-secret-file-secret-file-secret\n"),
+			},
+			dsn:        "file:secret@tcp(db:3306)/forum",
+			listenAddr: ":8080",
+			secret:     "file-secret-file-secret-file-secret",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Database.DSN != tc.dsn || cfg.Server.ListenAddr != tc.listenAddr || cfg.Auth.TokenSecret != tc.secret {
+				t.Errorf("dsn = %q, listen_addr = %q, token_secret = %q", cfg.Database.DSN, cfg.Server.ListenAddr, cfg.Auth.TokenSecret)
+			}
+			// Settings no layer touches keep their default
+			if cfg.Database.MaxOpenConns != 50 || cfg.Database.MaxIdleConns != 25 || cfg.Auth.AccessTokenTTL != 15*time.Minute {
+				t.Errorf("database = %+v, auth = %+v", cfg.Database, cfg.Auth)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		path string
+		env  map[string]string
+		want string
+	}{
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.yaml"), want: "read config file"},
+		{name: "invalid yaml", path: writeFile(t, "config.yaml", "server: [\n"), want: "parse config file"},
+		{name: "invalid env", env: map[string]string{"DB_MAX_OPEN_CONNS": "many"}, want: "DB_MAX_OPEN_CONNS"},
+		{name: "missing secret file", env: map[string]string{"AUTH_TOKEN_SECRET_FILE": filepath.Join(t.TempDir(), "token")}, want: "read secret file"},
+		{name: "invalid setting", env: map[string]string{"TRASH_RETENTION": "0s"}, want: "trash.retention must be positive"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			if _, err := Load(tc.path); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{name: "defaults", modify: func(cfg *Config) {}},
+		{
+			name:   "invalid dsn",
+			modify: func(cfg *Config) { cfg.Database.DSN = "not a dsn" },
+			want:   []string{"database.dsn must be a valid MySQL DSN"},
+		},
+		{
+			name: "idle above open connections",
+			modify: func(cfg *Config) {
+				cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns = 5, 10
+			},
+			want: []string{"database.max_idle_conns must not exceed database.max_open_conns"},
+		},
+		{
+			name:   "webhook without scheme",
+			modify: func(cfg *Config) { cfg.Notify.WebhookURL = "hooks.example.com/T0/B0" },
+			want:   []string{"notify.webhook_url must be an http or https URL"},
+		},
+		{
+			name:   "unknown storage backend",
+			modify: func(cfg *Config) { cfg.Storage.Backend = "ftp" },
+			want:   []string{"storage.backend must be local or s3"},
+		},
+		{
+			name:   "incomplete s3 backend",
+			modify: func(cfg *Config) { cfg.Storage.Backend = StorageS3 },
+			want: []string{
+				"storage.s3.endpoint is required for the s3 backend",
+				"storage.s3.bucket is required for the s3 backend",
+				"storage.s3 credentials are required for the s3 backend",
+			},
+		},
+		{
+			name: "every error at once",
+			modify: func(cfg *Config) {
+				cfg.Auth.TokenSecret = "short"
+				cfg.Auth.RefreshTokenTTL = cfg.Auth.AccessTokenTTL
+				cfg.Attachments.MaxTotalBytes = cfg.Attachments.MaxBytes - 1
+			},
+			want: []string{
+				"auth.token_secret must be at least 32 characters",
+				"auth.refresh_token_ttl must be longer than auth.access_token_ttl",
+				"attachments.max_total_bytes must be at least attachments.max_bytes",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default()
+			tc.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("err = nil, want %q", tc.want)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("err = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(cfg *Config)
+		hidden []string
+		shown  []string
+	}{
+		{
+			name:   "dsn password",
+			modify: func(cfg *Config) { cfg.Database.DSN = "forum:hunter2@tcp(db:3306)/forum" },
+			hidden: []string{"hunter2"},
+			shown:  []string{"forum:REDACTED@tcp(db:3306)/forum"},
+		},
+		{
+			name:   "unparsable dsn",
+			modify: func(cfg *Config) { cfg.Database.DSN = "forum:hunter2" },
+			hidden: []string{"hunter2"},
+		},
+		{
+			name: "secrets",
+			modify: func(cfg *Config) {
+				cfg.Auth.TokenSecret = "token-secret-token-secret-token-secret"
+				cfg.Storage.URLSecret = "url-secret"
+				cfg.Storage.S3.AccessKeyID = "AKIAEXAMPLE"
+				cfg.Storage.S3.SecretAccessKey = "s3-secret"
+			},
+			hidden: []string{"token-secret", "url-secret", "s3-secret"},
+			shown:  []string{"AKIAEXAMPLE"},
+		},
+		{
+			name: "webhook url",
+			modify: func(cfg *Config) {
+				cfg.Notify.WebhookURL = "https://hooks.example.com/services/T0/B0/webhook-token?key=query-token"
+			},
+			hidden: []string{"webhook-token", "query-token"},
+			shown:  []string{"https://hooks.example.com/REDACTED"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default()
+			tc.modify(cfg)
+			before := *cfg
+
+			out := cfg.Redacted()
+			for _, secret := range tc.hidden {
+				if strings.Contains(out, secret) {
+					t.Errorf("%q leaked:\n%s", secret, out)
+				}
+			}
+			for _, value := range tc.shown {
+				if !strings.Contains(out, value) {
+					t.Errorf("%q missing:\n%s", value, out)
+				}
+			}
+			// Redacting must not touch the configuration in use
+			if cfg.Database.DSN != before.Database.DSN || cfg.Notify.WebhookURL != before.Notify.WebhookURL || cfg.Storage.S3 != before.Storage.S3 {
+				t.Errorf("config changed to %+v", cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/imaging"
+	"backend-nagaricare/middleware"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"backend-nagaricare/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// attachmentURLExpiry is how long the signed URLs in attachment listings stay valid
+const attachmentURLExpiry = 15 * time.Minute
+
+// Attachment quota error codes, next to the imaging ones
+const (
+	codeTooManyAttachments = "too_many_attachments"
+	codeQuotaExceeded      = "attachment_quota_exceeded"
+)
+
+// errAttachmentQuota aborts an upload breaking the quota of its post
+var errAttachmentQuota = errors.New("attachment quota exceeded")
+
+// attachmentQuota returns the error response for a new file of the given
+// size on top of the existing ones, nil when it fits in the quota of the post
+func (h *Handler) attachmentQuota(existing []models.Attachment, size int64) fiber.Map {
+	if len(existing) >= h.AttachmentLimits.MaxPerPost {
+		return fiber.Map{
+			"error": fmt.Sprintf("A post can have at most %d attachments", h.AttachmentLimits.MaxPerPost),
+			"code":  codeTooManyAttachments,
+		}
+	}
+	total := size
+	for _, attachment := range existing {
+		total += attachment.Size
+	}
+	if total > h.AttachmentLimits.MaxTotalBytes {
+		return fiber.Map{
+			"error": fmt.Sprintf("The files on a post must not exceed %d bytes together", h.AttachmentLimits.MaxTotalBytes),
+			"code":  codeQuotaExceeded,
+		}
+	}
+	return nil
+}
+
+// attachmentFileName derives a safe display name from the uploaded name,
+// with the extension of the detected type
+func attachmentFileName(uploaded, ext string) string {
+	name := path.Base(strings.ReplaceAll(uploaded, "\\", "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	if name == "" || name == "." {
+		name = "attachment"
+	}
+	return name + ext
+}
+
+// prepareAttachment validates the file's bytes and returns what to store:
+// images are re-encoded like profile pictures, PDFs are kept as uploaded
+func (h *Handler) prepareAttachment(data []byte) (body []byte, contentType, ext string, err error) {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return data, "application/pdf", ".pdf", nil
+	}
+	if imaging.Sniff(data) == "" {
+		return nil, "", "", &imaging.Error{Code: imaging.CodeUnsupportedType, Message: "Only JPEG, PNG and WebP images and PDF files can be attached"}
+	}
+
+	img, err := imaging.Process(bytes.NewReader(data), imaging.Limits{MaxBytes: h.AttachmentLimits.MaxBytes, MaxDimension: h.Uploads.MaxDimension})
+	if err != nil {
+		return nil, "", "", err
+	}
+	return img.Data, img.ContentType, img.Ext, nil
+}
+
+// canEditPost reports whether the authenticated caller may change the post
+func canEditPost(c *fiber.Ctx, post *models.Post) bool {
+	return middleware.CurrentUser(c).ID_user == post.ID_user || middleware.Can(c, auth.PermPostUpdateAny)
+}
+
+// loadAttachment fetches the attachment named by the :id_attachment
+// parameter on the given post, writing the error response itself when it
+// returns nil
+func (h *Handler) loadAttachment(c *fiber.Ctx, post *models.Post) (*models.Attachment, error) {
+	id, err := c.ParamsInt("id_attachment")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid attachment id"})
+	}
+
+	attachment, err := h.Attachments.GetByID(c.UserContext(), post.ID_Posts, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attachment not found"})
+	} else if err != nil {
+		log.Println("Error querying attachment from database:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return attachment, nil
+}
+
+// withURL fills the signed download URL of the attachment
+func (h *Handler) withURL(ctx context.Context, attachment *models.Attachment) {
+	url, err := h.Storage.SignedURL(ctx, attachment.StorageKey, attachmentURLExpiry)
+	if err != nil {
+		log.Println("Error signing attachment URL:", err)
+		return
+	}
+	attachment.URL = url
+}
+
+// UploadAttachment attaches an image or PDF, sent as the multipart "file"
+// field, to a post of the authenticated user
+func (h *Handler) UploadAttachment(c *fiber.Ctx) error {
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	// Only the author or staff allowed to edit any post may attach files
+	if !canEditPost(c, post) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only attach files to your own posts"})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A file is required"})
+	}
+	if file.Size > h.AttachmentLimits.MaxBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("File must not exceed %d bytes", h.AttachmentLimits.MaxBytes),
+			"code":  imaging.CodeTooLarge,
+		})
+	}
+
+	// Enforce the per-post file count before reading the upload
+	existing, err := h.Attachments.ListByPost(c.UserContext(), post.ID_Posts)
+	if err != nil {
+		log.Println("Error querying attachments from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if exceeded := h.attachmentQuota(existing, 0); exceeded != nil {
+		return c.Status(fiber.StatusConflict).JSON(exceeded)
+	}
+
+	// Validate the content; the type comes from the bytes, never the client
+	src, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File upload failed"})
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, h.AttachmentLimits.MaxBytes+1))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File upload failed"})
+	}
+	body, contentType, ext, err := h.prepareAttachment(data)
+	if err != nil {
+		return imageError(c, err)
+	}
+
+	// Enforce the combined size of the post's files
+	if exceeded := h.attachmentQuota(existing, int64(len(body))); exceeded != nil {
+		return c.Status(fiber.StatusConflict).JSON(exceeded)
+	}
+
+	attachment := models.Attachment{
+		ID_post:     post.ID_Posts,
+		ID_user:     middleware.CurrentUser(c).ID_user,
+		FileName:    attachmentFileName(file.Filename, ext),
+		ContentType: contentType,
+		Size:        int64(len(body)),
+		StorageKey:  fmt.Sprintf("attachments/%d/%d%s", post.ID_Posts, time.Now().UnixNano(), ext),
+	}
+
+	// Store the file, then record it
+	if err := h.Storage.Put(c.UserContext(), attachment.StorageKey, bytes.NewReader(body), contentType); err != nil {
+		log.Println("Error saving attachment:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save file"})
+	}
+	var exceeded fiber.Map
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		// Check the quota again with the post locked, since concurrent
+		// uploads may have added files while this one was processed
+		existing, err := h.Attachments.ListByPostLocked(ctx, post.ID_Posts)
+		if err != nil {
+			return err
+		}
+		if exceeded = h.attachmentQuota(existing, attachment.Size); exceeded != nil {
+			return errAttachmentQuota
+		}
+		if err := h.Attachments.Create(ctx, &attachment); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditAttachmentCreate, models.AuditTargetAttachment, attachment.ID_attachment, nil, &attachment)
+	})
+	if err != nil {
+		h.removeAttachmentFiles(c.UserContext(), attachment)
+	}
+	if errors.Is(err, errAttachmentQuota) {
+		return c.Status(fiber.StatusConflict).JSON(exceeded)
+	} else if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error inserting attachment into database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not save attachment"})
+	}
+
+	h.withURL(c.UserContext(), &attachment)
+	return c.Status(fiber.StatusCreated).JSON(&attachment)
+}
+
+// GetAttachments lists the files attached to a post with signed download URLs
+func (h *Handler) GetAttachments(c *fiber.Ctx) error {
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	attachments, err := h.Attachments.ListByPost(c.UserContext(), post.ID_Posts)
+	if err != nil {
+		log.Println("Error querying attachments from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	for i := range attachments {
+		h.withURL(c.UserContext(), &attachments[i])
+	}
+
+	return c.JSON(attachments)
+}
+
+// DownloadAttachment streams an attached file; images are shown inline and
+// PDFs are downloaded
+func (h *Handler) DownloadAttachment(c *fiber.Ctx) error {
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+	attachment, err := h.loadAttachment(c, post)
+	if attachment == nil {
+		return err
+	}
+
+	obj, err := h.Storage.Get(c.UserContext(), attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Println("Attachment missing from storage:", attachment.StorageKey)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attachment not found"})
+	} else if err != nil {
+		log.Println("Error opening attachment:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open attachment"})
+	}
+
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	// A stored file never changes; deleting it gets it a new ID. Files may
+	// be private, so only the client keeps a copy.
+	c.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+	return sendObject(c, obj, attachment.ContentType)
+}
+
+// DeleteAttachment removes a file from a post
+func (h *Handler) DeleteAttachment(c *fiber.Ctx) error {
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+	attachment, err := h.loadAttachment(c, post)
+	if attachment == nil {
+		return err
+	}
+
+	// Only the author or staff allowed to edit any post may remove files
+	if !canEditPost(c, post) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only remove files from your own posts"})
+	}
+
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Attachments.Delete(ctx, attachment.ID_attachment); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditAttachmentDelete, models.AuditTargetAttachment, attachment.ID_attachment, attachment, nil)
+	})
+	if err != nil {
+		log.Println("Error deleting attachment from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete attachment"})
+	}
+	h.removeAttachmentFiles(c.UserContext(), *attachment)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Attachment deleted successfully"})
+}
+
+// removeAttachmentFiles deletes the stored files of attachments whose rows
+// are gone; failures are only logged
+func (h *Handler) removeAttachmentFiles(ctx context.Context, attachments ...models.Attachment) {
+	for _, attachment := range attachments {
+		if err := h.Storage.Delete(ctx, attachment.StorageKey); err != nil {
+			log.Printf("Failed to delete attachment %s: %s", attachment.StorageKey, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"backend-nagaricare/middleware"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// newAuditEntry describes a change to a target; before and after are
+// stored as JSON, nil meaning the target did not exist
+func newAuditEntry(action, targetType string, targetID int, before, after any) (*models.AuditEntry, error) {
+	entry := &models.AuditEntry{Action: action, TargetType: targetType, TargetID: targetID}
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return nil, fmt.Errorf("marshal audit before: %w", err)
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			return nil, fmt.Errorf("marshal audit after: %w", err)
+		}
+	}
+	return entry, nil
+}
+
+// audit records a change made by the request's caller; call it with the
+// context of the transaction making the change so both commit together
+func (h *Handler) audit(ctx context.Context, c *fiber.Ctx, action, targetType string, targetID int, before, after any) error {
+	entry, err := newAuditEntry(action, targetType, targetID, before, after)
+	if err != nil {
+		return err
+	}
+	if user := middleware.CurrentUser(c); user != nil {
+		actor := user.ID_user
+		entry.ID_actor = &actor
+	}
+	entry.IP = c.IP()
+	// user_agent holds 255 characters of valid UTF-8; a header MySQL rejects
+	// would roll back the change being audited
+	entry.UserAgent = strings.ToValidUTF8(c.Get(fiber.HeaderUserAgent), "\uFFFD")
+	if runes := []rune(entry.UserAgent); len(runes) > 255 {
+		entry.UserAgent = string(runes[:255])
+	}
+	return h.Audit.Record(ctx, entry)
+}
+
+// parseAuditFilter reads the audit log options from the query string
+//
+// Query parameters: limit, offset, actor (id_user), action, target_type,
+// target_id, from and to (YYYY-MM-DD or RFC 3339; a bare "to" date is inclusive)
+func parseAuditFilter(c *fiber.Ctx) (*repository.AuditFilter, error) {
+	q := &repository.AuditFilter{
+		Limit:      c.QueryInt("limit", defaultAuditLimit),
+		Offset:     c.QueryInt("offset", 0),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+	}
+
+	if q.Limit < 1 || q.Limit > maxAuditLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit)
+	}
+	if q.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if actor := c.Query("actor"); actor != "" {
+		var err error
+		if q.ActorID, err = strconv.Atoi(actor); err != nil || q.ActorID < 1 {
+			return nil, errors.New("actor must be a numeric user id")
+		}
+	}
+	if target := c.Query("target_id"); target != "" {
+		var err error
+		if q.TargetID, err = strconv.Atoi(target); err != nil || q.TargetID < 1 {
+			return nil, errors.New("target_id must be a numeric id")
+		}
+	}
+
+	var err error
+	if q.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		return nil, errors.New("from must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+	}
+	if q.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		return nil, errors.New("to must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+	}
+
+	return q, nil
+}
+
+// auditPageResponse is the response envelope of the audit log
+type auditPageResponse struct {
+	Data       []models.AuditEntry `json:"data"`
+	Total      int                 `json:"total"`
+	Limit      int                 `json:"limit"`
+	NextOffset *int                `json:"next_offset"`
+}
+
+// GetAuditLog lists audit entries, newest first
+func (h *Handler) GetAuditLog(c *fiber.Ctx) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	entries, total, err := h.Audit.List(c.UserContext(), *filter)
+	if err != nil {
+		log.Println("Error querying audit log from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying audit log"})
+	}
+
+	resp := auditPageResponse{Data: entries, Total: total, Limit: filter.Limit}
+	if next := filter.Offset + len(entries); next < total {
+		resp.NextOffset = &next
+	}
+	return c.JSON(resp)
+}
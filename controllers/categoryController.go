@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/middleware"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// categoryRequest is the body of the category create and update routes
+type categoryRequest struct {
+	ID_parent   *int   `json:"id_parent"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// validate checks the fields that need no database lookup
+func (req *categoryRequest) validate() error {
+	req.Slug, req.Name = strings.TrimSpace(req.Slug), strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if !models.ValidSlug(req.Slug) {
+		return errors.New("slug must be lowercase letters, digits and single hyphens, at most 64 characters")
+	}
+	return nil
+}
+
+// GetCategories lists every category as a tree with the number of posts in
+// each category and in its subtree
+func (h *Handler) GetCategories(c *fiber.Ctx) error {
+	categories, err := h.Categories.List(c.UserContext())
+	if err != nil {
+		log.Println("Error querying categories from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(models.CategoryTree(categories))
+}
+
+// GetCategory returns a category with its subcategories
+func (h *Handler) GetCategory(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id_category")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category id"})
+	}
+
+	categories, err := h.Categories.List(c.UserContext())
+	if err != nil {
+		log.Println("Error querying categories from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	// Search the tree so the subtree comes along
+	pending := models.CategoryTree(categories)
+	for len(pending) > 0 {
+		category := pending[0]
+		pending = append(pending[1:], category.Children...)
+		if category.ID_category == id {
+			return c.JSON(category)
+		}
+	}
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+}
+
+// checkParent checks that the category with the id, 0 for a new one, can
+// sit under the parent, writing the error response itself when it returns
+// false; a category cannot sit under itself or one of its descendants
+func (h *Handler) checkParent(c *fiber.Ctx, id int, parentID *int) (bool, error) {
+	for next := parentID; next != nil; {
+		if *next == id {
+			return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A category cannot be moved under itself or one of its subcategories"})
+		}
+		parent, err := h.Categories.GetByID(c.UserContext(), *next)
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Parent category not found"})
+		} else if err != nil {
+			log.Println("Error querying parent category from database:", err)
+			return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+		next = parent.ID_parent
+	}
+	return true, nil
+}
+
+// saveCategoryError answers a failed category create or update
+func saveCategoryError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, repository.ErrConflict) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Slug already in use"})
+	} else if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	}
+	log.Println("Error saving category in database:", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not save category"})
+}
+
+// CreateCategory adds a category, at the top level or under a parent
+func (h *Handler) CreateCategory(c *fiber.Ctx) error {
+	var req categoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if ok, err := h.checkParent(c, 0, req.ID_parent); !ok {
+		return err
+	}
+
+	category := models.Category{ID_parent: req.ID_parent, Slug: req.Slug, Name: req.Name, Description: req.Description, SortOrder: req.SortOrder}
+	err := h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Categories.Create(ctx, &category); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditCategoryCreate, models.AuditTargetCategory, category.ID_category, nil, &category)
+	})
+	if err != nil {
+		return saveCategoryError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&category)
+}
+
+// UpdateCategory replaces the parent, slug, name, description and order of
+// a category
+func (h *Handler) UpdateCategory(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id_category")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category id"})
+	}
+	var req categoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := req.validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	before, err := h.Categories.GetByID(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	} else if err != nil {
+		log.Println("Error querying category from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if ok, err := h.checkParent(c, id, req.ID_parent); !ok {
+		return err
+	}
+
+	category := *before
+	category.ID_parent, category.Slug, category.Name = req.ID_parent, req.Slug, req.Name
+	category.Description, category.SortOrder = req.Description, req.SortOrder
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Categories.Update(ctx, &category); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditCategoryUpdate, models.AuditTargetCategory, id, before, &category)
+	})
+	if err != nil {
+		return saveCategoryError(c, err)
+	}
+
+	return c.JSON(&category)
+}
+
+// DeleteCategory removes a category that has no subcategories or posts
+func (h *Handler) DeleteCategory(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id_category")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category id"})
+	}
+
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		before, err := h.Categories.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := h.Categories.Delete(ctx, id); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditCategoryDelete, models.AuditTargetCategory, id, before, nil)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	} else if errors.Is(err, repository.ErrInUse) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Move the subcategories and posts of this category, including trashed posts, before deleting it"})
+	} else if err != nil {
+		log.Println("Error deleting category from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete category"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Category deleted successfully"})
+}
+
+// requireCategory checks that a post can be filed under the category,
+// writing the error response itself when it returns false
+func (h *Handler) requireCategory(c *fiber.Ctx, id int) (bool, error) {
+	if id == 0 {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "category_id is required"})
+	}
+	_, err := h.Categories.GetByID(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Category not found"})
+	} else if err != nil {
+		log.Println("Error querying category from database:", err)
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return true, nil
+}
+
+// SetPostCategory files a post under another category and moves its SLA
+// deadlines to the policy of that category
+func (h *Handler) SetPostCategory(c *fiber.Ctx) error {
+	var req struct {
+		ID_category int `json:"category_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	// Authors may refile their own post; staff may refile any
+	if current := middleware.CurrentUser(c); current.ID_user != post.ID_user && !middleware.Can(c, auth.PermTicketWork) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only change the category of your own posts"})
+	}
+	if ok, err := h.requireCategory(c, req.ID_category); !ok {
+		return err
+	}
+
+	before := *post
+	post.ID_category = req.ID_category
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Posts.SetCategory(ctx, post.ID_Posts, req.ID_category); err != nil {
+			return err
+		}
+		if err := h.applySLA(ctx, post, post.Priority); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostCategory, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error updating post category in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update post category"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post category updated successfully", "category_id": post.ID_category, "sla": &post.SLA})
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/middleware"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateComment adds a comment to a post, or a reply to another comment when parent_id is set
+func (h *Handler) CreateComment(c *fiber.Ctx) error {
+	var req struct {
+		Content  string `json:"content"`
+		ParentID *int   `json:"parent_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Content is required"})
+	}
+
+	// Check if the post exists
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	// A reply must point to a comment on the same post
+	if req.ParentID != nil {
+		_, err := h.Comments.GetByID(c.UserContext(), post.ID_Posts, *req.ParentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Parent comment not found on this post"})
+		} else if err != nil {
+			log.Println("Error querying parent comment from database:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+	}
+
+	// Insert the comment as the authenticated user
+	comment := models.Comment{
+		ID_post:  post.ID_Posts,
+		ID_user:  middleware.CurrentUser(c).ID_user,
+		ParentID: req.ParentID,
+		Content:  req.Content,
+	}
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Comments.Create(ctx, &comment); err != nil {
+			return err
+		}
+		// Staff answering someone else's ticket meets its first response deadline
+		if middleware.Can(c, auth.PermTicketWork) && comment.ID_user != post.ID_user {
+			if err := h.Posts.MarkFirstResponse(ctx, post.ID_Posts, comment.CreatedAt); err != nil {
+				return err
+			}
+		}
+		return h.audit(ctx, c, models.AuditCommentCreate, models.AuditTargetComment, comment.ID_comment, nil, &comment)
+	})
+	if err != nil {
+		log.Println("Error inserting comment into database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create comment"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Comment created successfully", "id_comment": comment.ID_comment})
+}
+
+// GetComments lists the comments of a post
+//
+// Query parameters:
+//   - view: "tree" (default) nests replies under their parent, "flat" returns comments chronologically
+//   - page, limit: pagination; in tree view only top-level comments count towards the limit
+func (h *Handler) GetComments(c *fiber.Ctx) error {
+	filter := repository.CommentFilter{
+		View:  c.Query("view", repository.CommentViewTree),
+		Page:  c.QueryInt("page", 1),
+		Limit: c.QueryInt("limit", 20),
+	}
+	if filter.Page < 1 || filter.Limit < 1 || filter.Limit > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "page must be >= 1 and limit between 1 and 100"})
+	}
+	if filter.View != repository.CommentViewTree && filter.View != repository.CommentViewFlat {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "view must be tree or flat"})
+	}
+
+	// Check if the post exists
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	comments, total, err := h.Comments.List(c.UserContext(), post.ID_Posts, filter)
+	if err != nil {
+		log.Println("Error querying comments from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying comments"})
+	}
+
+	if filter.View == repository.CommentViewTree {
+		comments = buildCommentTree(comments)
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  comments,
+		"page":  filter.Page,
+		"limit": filter.Limit,
+		"total": total,
+	})
+}
+
+// buildCommentTree nests replies under their parents; comments must be ordered
+// so that every parent comes before its replies
+func buildCommentTree(comments []*models.Comment) []*models.Comment {
+	byID := make(map[int]*models.Comment, len(comments))
+	roots := []*models.Comment{}
+	for _, comment := range comments {
+		byID[comment.ID_comment] = comment
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		} else if parent, ok := byID[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+	return roots
+}
+
+// loadComment fetches the comment named by the :id_post and :id_comment
+// parameters on a post outside the trash, writing the error response itself
+// when it returns nil
+func (h *Handler) loadComment(c *fiber.Ctx) (*models.Comment, error) {
+	post, err := h.loadPost(c)
+	if post == nil {
+		return nil, err
+	}
+	commentID, err := c.ParamsInt("id_comment")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid comment id"})
+	}
+
+	comment, err := h.Comments.GetByID(c.UserContext(), post.ID_Posts, commentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Comment not found"})
+	} else if err != nil {
+		log.Println("Error querying comment from database:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return comment, nil
+}
+
+// UpdateComment edits the content of a comment
+func (h *Handler) UpdateComment(c *fiber.Ctx) error {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Content is required"})
+	}
+
+	// Check if the comment exists and who owns it
+	comment, err := h.loadComment(c)
+	if comment == nil {
+		return err
+	}
+
+	// Only the author or staff allowed to edit any comment may edit it
+	if current := middleware.CurrentUser(c); current.ID_user != comment.ID_user && !middleware.Can(c, auth.PermCommentUpdateAny) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only edit your own comments"})
+	}
+
+	// Update comment
+	after := *comment
+	after.Content = req.Content
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Comments.UpdateContent(ctx, comment.ID_comment, req.Content); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditCommentUpdate, models.AuditTargetComment, comment.ID_comment, comment, &after)
+	})
+	if err != nil {
+		log.Println("Error updating comment in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update comment"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Comment updated successfully"})
+}
+
+// DeleteComment deletes a comment together with its replies
+func (h *Handler) DeleteComment(c *fiber.Ctx) error {
+	// Check if the comment exists and who owns it
+	comment, err := h.loadComment(c)
+	if comment == nil {
+		return err
+	}
+
+	// Only the author or staff allowed to delete any comment may delete it
+	if current := middleware.CurrentUser(c); current.ID_user != comment.ID_user && !middleware.Can(c, auth.PermCommentDeleteAny) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only delete your own comments"})
+	}
+
+	// Delete comment
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Comments.Delete(ctx, comment.ID_comment); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditCommentDelete, models.AuditTargetComment, comment.ID_comment, comment, nil)
+	})
+	if err != nil {
+		log.Println("Error deleting comment from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete comment"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Comment deleted successfully"})
+}
+
+// SetAcceptedAnswer marks a comment as the answer that solved the post, or
+// unmarks the current one when id_comment is null
+func (h *Handler) SetAcceptedAnswer(c *fiber.Ctx) error {
+	var req struct {
+		ID_comment *int `json:"id_comment"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	// Authors accept answers to their own post; staff may on any
+	if current := middleware.CurrentUser(c); current.ID_user != post.ID_user && !middleware.Can(c, auth.PermTicketWork) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only accept answers to your own posts"})
+	}
+	if req.ID_comment != nil {
+		_, err := h.Comments.GetByID(c.UserContext(), post.ID_Posts, *req.ID_comment)
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Comment not found on this post"})
+		} else if err != nil {
+			log.Println("Error querying comment from database:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+	}
+
+	before := *post
+	post.ID_accepted, post.Solved = req.ID_comment, req.ID_comment != nil
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Comments.SetAccepted(ctx, post.ID_Posts, req.ID_comment); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostAcceptAnswer, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error updating accepted answer in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update accepted answer"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Accepted answer updated successfully", "id_accepted_comment": post.ID_accepted, "solved": post.Solved})
+}
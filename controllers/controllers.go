@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/config"
+	"backend-nagaricare/notify"
+	"backend-nagaricare/repository"
+	"backend-nagaricare/search"
+	"backend-nagaricare/storage"
+)
+
+// Handler holds the dependencies of the HTTP handlers; main wires the MySQL
+// implementations and tests wire the in-memory ones
+type Handler struct {
+	Users            repository.UserRepository
+	Posts            repository.PostRepository
+	Comments         repository.CommentRepository
+	Attachments      repository.AttachmentRepository
+	Audit            repository.AuditRepository
+	Categories       repository.CategoryRepository
+	Tags             repository.TagRepository
+	SLAPolicies      repository.SLAPolicyRepository
+	Tx               repository.Transactor // Spans the repositories above
+	Search           search.Searcher
+	Google           *auth.GoogleVerifier
+	Tokens           *auth.TokenIssuer
+	Uploads          config.UploadConfig
+	AttachmentLimits config.AttachmentConfig
+	Storage          storage.Storage
+	Trash            config.TrashConfig
+	SLA              config.SLAConfig
+	Notify           notify.Notifier // Tells agents and supervisors about SLA breaches
+}
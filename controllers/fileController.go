@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"backend-nagaricare/storage"
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ServeSignedFile serves a file from local storage to the holder of a signed
+// URL; S3 signed URLs point at the bucket instead
+func (h *Handler) ServeSignedFile(c *fiber.Ctx) error {
+	local, ok := h.Storage.(*storage.Local)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+	}
+
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid file path"})
+	}
+
+	// Check the signature and expiry before touching the disk
+	if err := local.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Invalid or expired link"})
+	}
+
+	obj, err := local.Get(c.UserContext(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+	} else if err != nil {
+		log.Println("Error opening stored file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+	}
+
+	return sendObject(c, obj, obj.ContentType)
+}
+
+// readCloser reads through a buffer while closing the underlying body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// sendObject streams a stored object with its ETag and Last-Modified
+// validators and answers matching conditional requests with 304. An empty
+// contentType is detected from the first bytes of the object.
+func sendObject(c *fiber.Ctx, obj *storage.Object, contentType string) error {
+	modTime := obj.ModTime.UTC().Truncate(time.Second)
+	if obj.ETag != "" {
+		c.Set(fiber.HeaderETag, obj.ETag)
+	}
+	if !modTime.IsZero() {
+		c.Set(fiber.HeaderLastModified, modTime.Format(http.TimeFormat))
+	}
+
+	if notModified(c, obj.ETag, modTime) {
+		obj.Body.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// Sniff the type without buffering more than the first 512 bytes
+	body := bufio.NewReaderSize(obj.Body, 512)
+	if contentType == "" {
+		head, err := body.Peek(512)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			obj.Body.Close()
+			log.Println("Error reading stored file:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read file"})
+		}
+		contentType = http.DetectContentType(head)
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+
+	// Fiber closes the body once the response is written
+	return c.SendStream(readCloser{body, obj.Body}, int(obj.Size))
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no entity
+// tags were sent, against the object's validators
+func notModified(c *fiber.Ctx, etag string, modTime time.Time) bool {
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := c.Get(fiber.HeaderIfModifiedSince); since != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(since)
+		return err == nil && !modTime.After(t)
+	}
+	return false
+}
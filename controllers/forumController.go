@@ -1,182 +1,244 @@
 package controllers
 
 import (
-	"backend-nagaricare/database"
+	"backend-nagaricare/auth"
+	"backend-nagaricare/middleware"
 	"backend-nagaricare/models"
-	"database/sql"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
 	"log"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// GetAllPosts retrieves all posts from the database
-func GetAllPosts(c *fiber.Ctx) error {
-	// Query the database for all posts
-	rows, err := database.DB.Query("SELECT id_posts, title, content, id_user, created_at FROM posts")
+// GetAllPosts retrieves a page of posts, see parsePostFilter for the options
+func (h *Handler) GetAllPosts(c *fiber.Ctx) error {
+	filter, err := parsePostFilter(c)
 	if err != nil {
-		log.Println("Error querying posts from database:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying posts"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	defer rows.Close()
-
-	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		var createdAtStr string // Temporarily hold created_at as string
-
-		// Scan the data into the Post struct fields, created_at goes into createdAtStr
-		if err := rows.Scan(&post.ID_Posts, &post.Title, &post.Content, &post.ID_user, &createdAtStr); err != nil {
-			log.Println("Error scanning post:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error scanning post"})
-		}
 
-		// Convert the string to time.Time
-		post.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAtStr)
-		if err != nil {
-			log.Println("Error parsing created_at:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error parsing created_at"})
-		}
-
-		posts = append(posts, post)
+	// Query the database for the requested page
+	page, err := h.Posts.List(c.UserContext(), *filter)
+	if err != nil {
+		log.Println("Error querying posts from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying posts"})
 	}
 
-	// Return the list of posts as JSON
-	return c.JSON(posts)
+	// Return the page of posts as JSON
+	return c.JSON(newPostPageResponse(filter, page))
 }
 
 // GetPostByID retrieves a specific post by its ID
-func GetPostByID(c *fiber.Ctx) error {
-	id := c.Params("id_post") // Post ID from URL parameters
-
-	var post models.Post
-	var createdAtStr string // Hold created_at as a string
+func (h *Handler) GetPostByID(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id_post") // Post ID from URL parameters
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid post id"})
+	}
 
 	// Query the database to get the post by its ID
-	err := database.DB.QueryRow("SELECT id_posts, title, content, id_user, created_at FROM posts WHERE id_posts = ?", id).Scan(
-		&post.ID_Posts, &post.Title, &post.Content, &post.ID_user, &createdAtStr,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
-		}
+	post, err := h.Posts.GetByID(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
 		log.Println("Error querying post by ID:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
 
-	// Convert created_at to time.Time
-	createdAt, err := time.Parse("2006-01-02 15:04:05", createdAtStr)
-	if err != nil {
-		log.Println("Error parsing created_at:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Invalid created_at format"})
+	// Put the accepted answer up front so readers need not page through the comments
+	if post.ID_accepted != nil {
+		post.AcceptedAnswer, err = h.Comments.GetByID(c.UserContext(), post.ID_Posts, *post.ID_accepted)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			log.Println("Error querying accepted answer:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
 	}
-	post.CreatedAt = createdAt
 
-	// Return the post as JSON
+	// Return the post as JSON, tagged with its version for later updates
+	setETag(c, post.Version)
 	return c.JSON(post)
 }
 
-// GetPostByUserID retrieves posts for a specific user based on their ID_user
-func GetPostByUserID(c *fiber.Ctx) error {
-	ID_user := c.Params("id_user") // Retrieve user ID from URL parameters
+// GetPostByUserID retrieves a page of posts for a specific user based on their ID_user
+func (h *Handler) GetPostByUserID(c *fiber.Ctx) error {
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	// Retrieve user ID from URL parameters
+	if filter.UserID, err = c.ParamsInt("id_user"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user id"})
+	}
 
-	var posts []models.Post
-	rows, err := database.DB.Query("SELECT id_posts, title, content, id_user, created_at FROM posts WHERE id_user = ?", ID_user)
+	page, err := h.Posts.List(c.UserContext(), *filter)
 	if err != nil {
 		log.Println("Error querying posts by user ID:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var post models.Post
-		var createdAtStr string
-		if err := rows.Scan(&post.ID_Posts, &post.Title, &post.Content, &post.ID_user, &createdAtStr); err != nil {
-			log.Println("Error scanning post:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
-		}
-
-		// Parse the created_at string into a time.Time object
-		post.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAtStr)
-		if err != nil {
-			log.Println("Error parsing created_at:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Invalid created_at format"})
-		}
-
-		// Append the post to the posts slice
-		posts = append(posts, post)
-	}
 
 	// Check if no posts were found
-	if len(posts) == 0 {
+	if page.Total == 0 {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No posts found for this user"})
 	}
 
 	// Return the posts as JSON
-	return c.JSON(posts)
+	return c.JSON(newPostPageResponse(filter, page))
 }
 
-// CreatePost inserts a new post into the database
-func CreatePost(c *fiber.Ctx) error {
+// CreatePost inserts a new post into the database on behalf of the authenticated user
+func (h *Handler) CreatePost(c *fiber.Ctx) error {
 	var req models.Post
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Insert new post into the database
-	_, err := database.DB.Exec("INSERT INTO posts (title, content, created_at, id_user) VALUES (?, ?, NOW(), ?)", req.Title, req.Content, req.ID_user)
+	// Every post is filed under an existing category
+	if ok, err := h.requireCategory(c, req.ID_category); !ok {
+		return err
+	}
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// The author always comes from the session, never from the request body
+	post := models.Post{
+		Title:       req.Title,
+		Content:     req.Content,
+		ID_user:     middleware.CurrentUser(c).ID_user,
+		ID_category: req.ID_category,
+	}
+
+	// Insert new post into the database with its tags and SLA deadlines,
+	// together with its audit entry
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Posts.Create(ctx, &post); err != nil {
+			return err
+		}
+		if err := h.Tags.SetPostTags(ctx, post.ID_Posts, tags); err != nil {
+			return err
+		}
+		post.Tags = tags
+		if err := h.applySLA(ctx, &post, models.PriorityNormal); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostCreate, models.AuditTargetPost, post.ID_Posts, nil, &post)
+	})
 	if err != nil {
 		log.Println("Error inserting post into database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create post"})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Post created successfully"})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Post created successfully", "id_posts": post.ID_Posts})
+}
+
+// loadPost fetches the post named by the :id_post parameter, writing the
+// error response itself when it returns nil
+func (h *Handler) loadPost(c *fiber.Ctx) (*models.Post, error) {
+	id, err := c.ParamsInt("id_post")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid post id"})
+	}
+
+	post, err := h.Posts.GetByID(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error querying post from database:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return post, nil
 }
 
 // UpdatePost updates an existing post
-func UpdatePost(c *fiber.Ctx) error {
-	id := c.Params("id_post")
+func (h *Handler) UpdatePost(c *fiber.Ctx) error {
 	var req models.Post
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Check if post exists
-	var existingPost string
-	err := database.DB.QueryRow("SELECT id_posts FROM posts WHERE id_posts = ?", id).Scan(&existingPost)
-	if err == sql.ErrNoRows {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
-	} else if err != nil {
-		log.Println("Error querying post from database:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	// Check if post exists and who owns it
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
 	}
 
-	// Update post
-	_, err = database.DB.Exec("UPDATE posts SET title = ?, content = ? WHERE id_posts = ?", req.Title, req.Content, id)
-	if err != nil {
+	// Only the author or staff allowed to edit any post may edit it
+	if current := middleware.CurrentUser(c); current.ID_user != post.ID_user && !middleware.Can(c, auth.PermPostUpdateAny) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only edit your own posts"})
+	}
+
+	// The edit must be based on the current version of the post
+	version, err := checkVersion(c, req.Version)
+	if version == 0 {
+		return err
+	}
+	if version != post.Version {
+		return conflict(c, post.Version, post)
+	}
+
+	// An edit that changes nothing is not recorded as a revision
+	if req.Title == post.Title && req.Content == post.Content {
+		setETag(c, post.Version)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post is unchanged", "version": post.Version})
+	}
+
+	// Update post, recording the edit as a new revision
+	before := *post
+	post.Title, post.Content = req.Title, req.Content
+	var revision *models.PostRevision
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		var err error
+		if revision, err = h.Posts.Update(ctx, post, middleware.CurrentUser(c).ID_user); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostUpdate, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrStale) {
+		return h.postConflict(c, post.ID_Posts)
+	} else if err != nil {
 		log.Println("Error updating post in database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update post"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post updated successfully"})
+	setETag(c, post.Version)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post updated successfully", "revision": revision.Revision, "version": post.Version})
 }
 
-// DeletePost deletes a post from the database
-func DeletePost(c *fiber.Ctx) error {
-	id := c.Params("id_post")
-
-	// Check if post exists
-	var existingPost string
-	err := database.DB.QueryRow("SELECT id_posts FROM posts WHERE id_posts = ?", id).Scan(&existingPost)
-	if err == sql.ErrNoRows {
+// postConflict answers an edit that lost a race with another one with the
+// post as it is now
+func (h *Handler) postConflict(c *fiber.Ctx, id int) error {
+	current, err := h.Posts.GetByID(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
 	} else if err != nil {
 		log.Println("Error querying post from database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
+	return conflict(c, current.Version, current)
+}
+
+// DeletePost moves a post to the trash
+func (h *Handler) DeletePost(c *fiber.Ctx) error {
+	// Check if post exists and who owns it
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	// Only the author or staff allowed to delete any post may delete it
+	if current := middleware.CurrentUser(c); current.ID_user != post.ID_user && !middleware.Can(c, auth.PermPostDeleteAny) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only delete your own posts"})
+	}
 
-	// Delete post
-	_, err = database.DB.Exec("DELETE FROM posts WHERE id_posts = ?", id)
+	// Move the post to the trash; its comments and files stay until it is purged
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Posts.Delete(ctx, post.ID_Posts); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostDelete, models.AuditTargetPost, post.ID_Posts, post, nil)
+	})
 	if err != nil {
 		log.Println("Error deleting post from database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete post"})
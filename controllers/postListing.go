@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPostLimit = 20
+	maxPostLimit     = 100
+)
+
+// encodePostCursor turns a cursor into the opaque string handed to clients
+func encodePostCursor(pc repository.PostCursor) string {
+	raw := pc.CreatedAt.Format("2006-01-02 15:04:05") + "|" + strconv.Itoa(pc.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodePostCursor parses a cursor produced by encodePostCursor
+func decodePostCursor(s string) (*repository.PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	createdAtStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, errors.New("invalid cursor")
+	}
+	createdAt, err := time.Parse("2006-01-02 15:04:05", createdAtStr)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &repository.PostCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// parsePostFilter reads the listing options from the query string
+//
+// Query parameters: limit, offset, cursor, sort (newest, oldest, most_commented),
+// user (id_user), status (comma-separated ticket statuses), assignee (id_user
+// or "none"), category (id or slug, subcategories included), tag, solved
+// (true or false), from and to (YYYY-MM-DD or RFC 3339; a bare "to" date is
+// inclusive)
+func parsePostFilter(c *fiber.Ctx) (*repository.PostFilter, error) {
+	q := &repository.PostFilter{
+		Limit:  c.QueryInt("limit", defaultPostLimit),
+		Offset: c.QueryInt("offset", 0),
+		Sort:   c.Query("sort", repository.SortNewest),
+	}
+
+	if q.Limit < 1 || q.Limit > maxPostLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxPostLimit)
+	}
+	if q.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	switch q.Sort {
+	case repository.SortNewest, repository.SortOldest, repository.SortMostCommented:
+	default:
+		return nil, errors.New("sort must be newest, oldest or most_commented")
+	}
+	if user := c.Query("user"); user != "" {
+		var err error
+		if q.UserID, err = strconv.Atoi(user); err != nil || q.UserID < 1 {
+			return nil, errors.New("user must be a numeric user id")
+		}
+	}
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			if !slices.Contains(models.Statuses, s) {
+				return nil, fmt.Errorf("status must be one of %s", strings.Join(models.Statuses, ", "))
+			}
+			q.Statuses = append(q.Statuses, s)
+		}
+	}
+	if assignee := c.Query("assignee"); assignee == "none" {
+		q.Unassigned = true
+	} else if assignee != "" {
+		var err error
+		if q.AssigneeID, err = strconv.Atoi(assignee); err != nil || q.AssigneeID < 1 {
+			return nil, errors.New("assignee must be a numeric user id or none")
+		}
+	}
+
+	if category := c.Query("category"); category != "" {
+		if id, err := strconv.Atoi(category); err == nil && id > 0 {
+			q.CategoryID = id
+		} else if models.ValidSlug(category) {
+			q.CategorySlug = category
+		} else {
+			return nil, errors.New("category must be a category id or slug")
+		}
+	}
+	if tag := c.Query("tag"); tag != "" {
+		var ok bool
+		if q.Tag, ok = models.NormalizeTag(tag); !ok {
+			return nil, errors.New("tag must be letters, digits and single hyphens")
+		}
+	}
+	if solved := c.Query("solved"); solved != "" {
+		b, err := strconv.ParseBool(solved)
+		if err != nil {
+			return nil, errors.New("solved must be true or false")
+		}
+		q.Solved = &b
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if q.Sort == repository.SortMostCommented {
+			return nil, errors.New("cursor is not supported with sort=most_commented, use offset")
+		}
+		var err error
+		if q.Cursor, err = decodePostCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if q.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		return nil, errors.New("from must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+	}
+	if q.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		return nil, errors.New("to must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+	}
+
+	return q, nil
+}
+
+// parseDateParam parses a date filter; bare end dates are moved to the next
+// midnight so the whole day is included
+func parseDateParam(s string, end bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// postPageResponse is the response envelope of the post listing endpoints
+type postPageResponse struct {
+	Data       []models.Post `json:"data"`
+	Total      int           `json:"total"`
+	Limit      int           `json:"limit"`
+	NextCursor *string       `json:"next_cursor"` // Only for the newest and oldest sorts
+	NextOffset *int          `json:"next_offset"` // Only when paginating by offset
+}
+
+// newPostPageResponse wraps a page of posts with the links to the next page
+func newPostPageResponse(filter *repository.PostFilter, page *repository.PostPage) *postPageResponse {
+	resp := &postPageResponse{Data: page.Posts, Total: page.Total, Limit: filter.Limit}
+	if !page.HasMore || len(page.Posts) == 0 {
+		return resp
+	}
+
+	last := page.Posts[len(page.Posts)-1]
+	if filter.Sort != repository.SortMostCommented {
+		cursor := encodePostCursor(repository.PostCursor{CreatedAt: last.CreatedAt, ID: last.ID_Posts})
+		resp.NextCursor = &cursor
+	}
+	if filter.Cursor == nil {
+		nextOffset := filter.Offset + filter.Limit
+		resp.NextOffset = &nextOffset
+	}
+	return resp
+}
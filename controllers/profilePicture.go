@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"backend-nagaricare/imaging"
+	"backend-nagaricare/models"
+	"backend-nagaricare/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// profilePictureKey maps a stored "/userProfile/<file>" path to its storage key
+func profilePictureKey(storedPath string) string {
+	return "userProfile/" + path.Base(storedPath)
+}
+
+// variantName names the size x size variant of a stored picture, e.g. "123_128.png"
+func variantName(storedPath string, size int) string {
+	base := path.Base(storedPath)
+	ext := path.Ext(base)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(base, ext), size, ext)
+}
+
+// imageCache holds the sizes resized on demand; it always lives on local disk
+func (h *Handler) imageCache() *storage.Local {
+	return storage.NewLocal(h.Uploads.CacheDir, "", nil)
+}
+
+// pictureCacheControl is the Cache-Control policy for profile pictures
+func (h *Handler) pictureCacheControl() string {
+	if h.Uploads.CacheMaxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", int(h.Uploads.CacheMaxAge.Seconds()))
+}
+
+// sendAvatar generates the avatar of a user without a profile picture as a
+// PNG, or as an SVG when ?format=svg is given
+func (h *Handler) sendAvatar(c *fiber.Ctx, user *models.User, size int) error {
+	if size == 0 {
+		size = imaging.DefaultAvatarSize
+	}
+
+	name := user.Name
+	if strings.TrimSpace(name) == "" {
+		name, _, _ = strings.Cut(user.Email, "@")
+	}
+	avatar := imaging.NewAvatar(name, user.ID_user)
+
+	var data []byte
+	var contentType string
+	switch c.Query("format", "png") {
+	case "png":
+		var err error
+		if data, err = avatar.PNG(size); err != nil {
+			log.Println("Error drawing avatar:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate avatar",
+			})
+		}
+		contentType = "image/png"
+	case "svg":
+		data = avatar.SVG(size)
+		contentType = "image/svg+xml"
+		// Never let the document run anything if opened directly
+		c.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'")
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be png or svg",
+		})
+	}
+
+	// The avatar only changes with the name, so its hash is a stable ETag
+	sum := sha256.Sum256(data)
+	c.Set(fiber.HeaderCacheControl, h.pictureCacheControl())
+	return sendObject(c, &storage.Object{
+		Body: io.NopCloser(bytes.NewReader(data)),
+		Size: int64(len(data)),
+		ETag: fmt.Sprintf(`"%x"`, sum[:16]),
+	}, contentType)
+}
+
+// saveProfilePicture stores a new upload together with its standard thumbnails
+func (h *Handler) saveProfilePicture(ctx context.Context, storedPath string, img *imaging.Image) error {
+	if err := h.Storage.Put(ctx, profilePictureKey(storedPath), bytes.NewReader(img.Data), img.ContentType); err != nil {
+		return err
+	}
+	for _, size := range imaging.ThumbnailSizes {
+		data, _, err := imaging.Encode(imaging.Thumbnail(img.Pixels, size), img.Format)
+		if err != nil {
+			return err
+		}
+		if err := h.Storage.Put(ctx, "userProfile/"+variantName(storedPath, size), bytes.NewReader(data), img.ContentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openProfilePicture opens a stored picture, or its size x size variant when
+// size is non-zero, resizing the original into the cache when needed
+func (h *Handler) openProfilePicture(ctx context.Context, storedPath string, size int) (*storage.Object, error) {
+	if size == 0 {
+		return h.Storage.Get(ctx, profilePictureKey(storedPath))
+	}
+
+	if slices.Contains(imaging.ThumbnailSizes, size) {
+		obj, err := h.Storage.Get(ctx, "userProfile/"+variantName(storedPath, size))
+		if !errors.Is(err, storage.ErrNotFound) {
+			return obj, err
+		}
+	}
+
+	cache := h.imageCache()
+	obj, err := cache.Get(ctx, variantName(storedPath, size))
+	if !errors.Is(err, storage.ErrNotFound) {
+		return obj, err
+	}
+
+	// Resize the original
+	original, err := h.Storage.Get(ctx, profilePictureKey(storedPath))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(original.Body)
+	original.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	resized, _, err := imaging.Encode(imaging.Thumbnail(img, size), imaging.Sniff(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Put(ctx, variantName(storedPath, size), bytes.NewReader(resized), ""); err != nil {
+		return nil, err
+	}
+	return cache.Get(ctx, variantName(storedPath, size))
+}
+
+// removeProfilePicture deletes a stored picture together with its variants
+// and cached sizes; failures are only logged
+func (h *Handler) removeProfilePicture(ctx context.Context, storedPath string) {
+	keys := []string{profilePictureKey(storedPath)}
+	for _, size := range imaging.ThumbnailSizes {
+		keys = append(keys, "userProfile/"+variantName(storedPath, size))
+	}
+	for _, key := range keys {
+		if err := h.Storage.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete old profile picture: %s", err)
+		}
+	}
+
+	base := path.Base(storedPath)
+	cached, _ := filepath.Glob(filepath.Join(h.Uploads.CacheDir, strings.TrimSuffix(base, path.Ext(base))+"_*"))
+	for _, file := range cached {
+		if err := os.Remove(file); err != nil {
+			log.Printf("Failed to delete cached profile picture: %s", err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"backend-nagaricare/diff"
+	"backend-nagaricare/middleware"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// revisionDiff is the response of GetRevisionDiff
+type revisionDiff struct {
+	From    int          `json:"from"`
+	To      int          `json:"to"`
+	Title   []diff.Chunk `json:"title"`
+	Content []diff.Chunk `json:"content"`
+}
+
+// loadRevision fetches revision number n of the post, writing the error
+// response itself when it returns nil
+func (h *Handler) loadRevision(c *fiber.Ctx, post *models.Post, n int) (*models.PostRevision, error) {
+	revision, err := h.Posts.GetRevision(c.UserContext(), post.ID_Posts, n)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Revision not found"})
+	} else if err != nil {
+		log.Println("Error querying revision from database:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return revision, nil
+}
+
+// GetPostRevisions lists every saved version of a post, oldest first
+func (h *Handler) GetPostRevisions(c *fiber.Ctx) error {
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	revisions, err := h.Posts.ListRevisions(c.UserContext(), post.ID_Posts)
+	if err != nil {
+		log.Println("Error querying revisions from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	return c.JSON(revisions)
+}
+
+// GetRevisionDiff compares the title and content of two revisions, given
+// as the from and to query parameters, line by line
+func (h *Handler) GetRevisionDiff(c *fiber.Ctx) error {
+	from, to := c.QueryInt("from"), c.QueryInt("to")
+	if from < 1 || to < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from and to must be revision numbers"})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+	older, err := h.loadRevision(c, post, from)
+	if older == nil {
+		return err
+	}
+	newer, err := h.loadRevision(c, post, to)
+	if newer == nil {
+		return err
+	}
+
+	return c.JSON(revisionDiff{
+		From:    from,
+		To:      to,
+		Title:   diff.Lines(older.Title, newer.Title),
+		Content: diff.Lines(older.Content, newer.Content),
+	})
+}
+
+// RollbackPost restores the title and content of an earlier revision; the
+// rollback is recorded as a new revision
+func (h *Handler) RollbackPost(c *fiber.Ctx) error {
+	n, err := c.ParamsInt("revision")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid revision"})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+	target, err := h.loadRevision(c, post, n)
+	if target == nil {
+		return err
+	}
+
+	// If-Match is optional here: without it, or with "*", the rollback applies
+	// to the post as loaded
+	if match := strings.TrimSpace(c.Get(fiber.HeaderIfMatch)); match != "" && match != "*" {
+		version, err := checkVersion(c, 0)
+		if version == 0 {
+			return err
+		}
+		if version != post.Version {
+			return conflict(c, post.Version, post)
+		}
+	}
+
+	if target.Title == post.Title && target.Content == post.Content {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post already matches the revision"})
+	}
+
+	before := *post
+	post.Title, post.Content = target.Title, target.Content
+	var revision *models.PostRevision
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		var err error
+		if revision, err = h.Posts.Update(ctx, post, middleware.CurrentUser(c).ID_user); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostRollback, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrStale) {
+		return h.postConflict(c, post.ID_Posts)
+	} else if err != nil {
+		log.Println("Error rolling back post in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not roll back post"})
+	}
+
+	setETag(c, post.Version)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post rolled back successfully", "revision": revision.Revision, "version": post.Version})
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"backend-nagaricare/search"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchPosts runs a relevance-ranked full-text search over posts and comments
+//
+// Query parameters: q (required), plus limit, offset, user, from, to, status,
+// assignee, category, tag and solved as for GetAllPosts
+func (h *Handler) SearchPosts(c *fiber.Ctx) error {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "q is required"})
+	}
+	if c.Query("cursor") != "" || c.Query("sort") != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Search results are ordered by relevance and paginated with offset"})
+	}
+
+	q, err := parsePostFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := h.Search.Search(c.UserContext(), search.Query{
+		Text:       text,
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+		UserID:     q.UserID,
+		From:       q.From,
+		To:         q.To,
+		Statuses:   q.Statuses,
+		AssigneeID: q.AssigneeID,
+		Unassigned: q.Unassigned,
+
+		CategoryID:   q.CategoryID,
+		CategorySlug: q.CategorySlug,
+		Tag:          q.Tag,
+		Solved:       q.Solved,
+	})
+	if err != nil {
+		log.Println("Error searching posts:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error searching posts"})
+	}
+
+	// Offer the next offset while more hits remain
+	var nextOffset *int
+	if q.Offset+len(result.Hits) < result.Total {
+		next := q.Offset + len(result.Hits)
+		nextOffset = &next
+	}
+
+	return c.JSON(fiber.Map{
+		"data":        result.Hits,
+		"total":       result.Total,
+		"limit":       q.Limit,
+		"next_offset": nextOffset,
+	})
+}
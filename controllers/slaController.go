@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/models"
+	"backend-nagaricare/notify"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// applySLA sets the priority of a post and its deadlines under the policy of
+// that priority in its category, counted from when the post was opened
+func (h *Handler) applySLA(ctx context.Context, post *models.Post, priority string) error {
+	policy, err := h.SLAPolicies.Get(ctx, post.ID_category, priority)
+	if err != nil {
+		return fmt.Errorf("get SLA policy %s: %w", priority, err)
+	}
+	firstResponse, resolution := policy.Deadlines(post.CreatedAt)
+	if err := h.Posts.SetPriority(ctx, post.ID_Posts, priority, firstResponse, resolution); err != nil {
+		return err
+	}
+	post.Priority = priority
+	post.SLA.FirstResponseDue, post.SLA.ResolutionDue = &firstResponse, &resolution
+	post.SLA.FirstResponseBreachedAt, post.SLA.ResolutionBreachedAt = nil, nil
+	return nil
+}
+
+// SetPostPriority changes the priority of a post, moving its SLA deadlines
+func (h *Handler) SetPostPriority(c *fiber.Ctx) error {
+	var req struct {
+		Priority string `json:"priority"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if !slices.Contains(models.Priorities, req.Priority) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid priority", "priorities": models.Priorities})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	before := *post
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.applySLA(ctx, post, req.Priority); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostPriority, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error updating post priority in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update ticket priority"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Ticket priority updated successfully", "priority": post.Priority, "sla": &post.SLA})
+}
+
+// slaCategory reads the category query parameter of the SLA policy routes,
+// 0 meaning the defaults
+func slaCategory(c *fiber.Ctx) (int, error) {
+	category := c.Query("category")
+	if category == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(category)
+	if err != nil || id < 1 {
+		return 0, errors.New("category must be a numeric category id")
+	}
+	return id, nil
+}
+
+// GetSLAPolicies lists the SLA policy of every priority, as in effect in a
+// category when one is given
+func (h *Handler) GetSLAPolicies(c *fiber.Ctx) error {
+	categoryID, err := slaCategory(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	policies, err := h.SLAPolicies.List(c.UserContext(), categoryID)
+	if err != nil {
+		log.Println("Error querying SLA policies from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(policies)
+}
+
+// UpdateSLAPolicy changes the default targets of a priority, or overrides
+// them in a category; tickets opened before keep their deadlines until their
+// priority or category is set again
+func (h *Handler) UpdateSLAPolicy(c *fiber.Ctx) error {
+	var req models.SLAPolicy
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	categoryID, err := slaCategory(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.Priority, req.ID_category = c.Params("priority"), nil
+	if categoryID != 0 {
+		req.ID_category = &categoryID
+	}
+	if !slices.Contains(models.Priorities, req.Priority) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown priority", "priorities": models.Priorities})
+	}
+	if req.FirstResponseMinutes <= 0 || req.ResolutionMinutes <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Targets must be a positive number of minutes"})
+	}
+	if req.FirstResponseMinutes > req.ResolutionMinutes {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The first response target cannot be later than the resolution target"})
+	}
+
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		before, err := h.SLAPolicies.Get(ctx, categoryID, req.Priority)
+		if err != nil {
+			return err
+		}
+		if err := h.SLAPolicies.Update(ctx, &req); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditSLAPolicyUpdate, models.AuditTargetSLAPolicy, categoryID, before, &req)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	} else if err != nil {
+		log.Println("Error updating SLA policy in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update SLA policy"})
+	}
+
+	return c.JSON(req)
+}
+
+// DeleteSLAPolicyOverride returns a priority in a category to the default targets
+func (h *Handler) DeleteSLAPolicyOverride(c *fiber.Ctx) error {
+	categoryID, err := slaCategory(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if categoryID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "category is required, the default policies cannot be removed"})
+	}
+
+	priority := c.Params("priority")
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		before, err := h.SLAPolicies.Get(ctx, categoryID, priority)
+		if err != nil {
+			return err
+		}
+		if err := h.SLAPolicies.DeleteOverride(ctx, categoryID, priority); err != nil {
+			return err
+		}
+		// The category falls back to the default of the priority
+		after, err := h.SLAPolicies.Get(ctx, categoryID, priority)
+		if err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditSLAPolicyDelete, models.AuditTargetSLAPolicy, categoryID, before, after)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "The category does not override this priority"})
+	} else if err != nil {
+		log.Println("Error deleting SLA policy override in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete SLA policy"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "SLA policy override deleted successfully"})
+}
+
+// GetBreachedTickets lists the unresolved tickets with a missed deadline
+func (h *Handler) GetBreachedTickets(c *fiber.Ctx) error {
+	return h.listSLATickets(c, repository.SLABreached)
+}
+
+// GetAtRiskTickets lists the unresolved tickets with a deadline passing
+// within SLA.AtRiskWindow
+func (h *Handler) GetAtRiskTickets(c *fiber.Ctx) error {
+	return h.listSLATickets(c, repository.SLAAtRisk)
+}
+
+// listSLATickets lists the posts in an SLA state, oldest first unless
+// another sort is asked for
+func (h *Handler) listSLATickets(c *fiber.Ctx, state string) error {
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter.SLA, filter.AtRiskWindow = state, h.SLA.AtRiskWindow
+	if c.Query("sort") == "" {
+		filter.Sort = repository.SortOldest
+	}
+
+	page, err := h.Posts.List(c.UserContext(), *filter)
+	if err != nil {
+		log.Println("Error querying SLA tickets from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying posts"})
+	}
+
+	return c.JSON(newPostPageResponse(filter, page))
+}
+
+// CheckSLA flags every deadline missed by now and notifies who must act on
+// it, returning how many breaches were flagged
+func (h *Handler) CheckSLA(ctx context.Context, now time.Time) (int, error) {
+	breaches, err := h.Posts.ListSLABreaches(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	flagged := 0
+	for _, breach := range breaches {
+		err := h.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := h.Posts.FlagSLABreach(ctx, breach.ID_post, breach.Kind, now); err != nil {
+				return err
+			}
+			return h.recordSystemAudit(ctx, models.AuditPostSLABreach, models.AuditTargetPost, breach.ID_post, breach)
+		})
+		if errors.Is(err, repository.ErrStale) {
+			// Another instance flagged it first and notifies for it
+			continue
+		} else if err != nil {
+			return flagged, err
+		}
+		flagged++
+		h.notifyBreach(ctx, breach)
+	}
+	return flagged, nil
+}
+
+// notifyBreach tells the assignee about a missed deadline and escalates to
+// supervisors when nobody is assigned or the resolution deadline was missed.
+// Failures are only logged since the breach stays flagged and listed.
+func (h *Handler) notifyBreach(ctx context.Context, breach models.SLABreach) {
+	deadline := strings.ReplaceAll(breach.Kind, "_", " ")
+	subject := fmt.Sprintf("Ticket #%d missed its %s deadline", breach.ID_post, deadline)
+	body := fmt.Sprintf("The %s deadline of ticket #%d passed at %s.", deadline, breach.ID_post, breach.Due.Format("2006-01-02 15:04:05"))
+
+	var recipients []models.User
+	if breach.ID_assignee != nil {
+		assignee, err := h.Users.GetByID(ctx, *breach.ID_assignee)
+		if err != nil {
+			log.Println("Error querying assignee for SLA notification:", err)
+		} else {
+			recipients = append(recipients, *assignee)
+		}
+	}
+	if breach.ID_assignee == nil || breach.Kind == models.SLAResolution {
+		if breach.ID_assignee == nil {
+			body += " Nobody is assigned to it."
+		}
+		users, err := h.Users.List(ctx)
+		if err != nil {
+			log.Println("Error querying supervisors for SLA notification:", err)
+		}
+		for _, user := range users {
+			if auth.HasPermission(user.Role, auth.PermTicketAssign) && !slices.ContainsFunc(recipients, func(r models.User) bool { return r.ID_user == user.ID_user }) {
+				recipients = append(recipients, user)
+			}
+		}
+	}
+
+	for _, user := range recipients {
+		msg := notify.Message{ID_user: user.ID_user, Email: user.Email, Subject: subject, Body: body}
+		if err := h.Notify.Notify(ctx, msg); err != nil {
+			log.Printf("Error notifying user %d of SLA breach: %v", user.ID_user, err)
+		}
+	}
+}
+
+// RunSLA checks the SLA deadlines every SLA.CheckInterval until ctx is done
+func (h *Handler) RunSLA(ctx context.Context) {
+	ticker := time.NewTicker(h.SLA.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		flagged, err := h.CheckSLA(ctx, time.Now())
+		if err != nil {
+			log.Println("Error checking SLA deadlines:", err)
+		} else if flagged > 0 {
+			log.Printf("Flagged %d SLA breaches", flagged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
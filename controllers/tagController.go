@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/middleware"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultTagLimit = 10
+	maxTagLimit     = 50
+)
+
+// GetTags suggests the tags starting with the prefix query parameter, the
+// most used first
+func (h *Handler) GetTags(c *fiber.Ctx) error {
+	prefix := strings.ToLower(strings.TrimSpace(c.Query("prefix")))
+	limit := c.QueryInt("limit", defaultTagLimit)
+	if limit < 1 || limit > maxTagLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "limit must be between 1 and 50"})
+	}
+
+	tags, err := h.Tags.List(c.UserContext(), prefix, limit)
+	if err != nil {
+		log.Println("Error querying tags from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(tags)
+}
+
+// GetTagPosts lists the posts carrying a tag; it takes the same query
+// parameters as GetAllPosts
+func (h *Handler) GetTagPosts(c *fiber.Ctx) error {
+	tag, ok := models.NormalizeTag(c.Params("tag"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid tag"})
+	}
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter.Tag = tag
+
+	page, err := h.Posts.List(c.UserContext(), *filter)
+	if err != nil {
+		log.Println("Error querying tagged posts from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying posts"})
+	}
+
+	return c.JSON(newPostPageResponse(filter, page))
+}
+
+// SetPostTags replaces the tags of a post
+func (h *Handler) SetPostTags(c *fiber.Ctx) error {
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	// Authors may tag their own post; staff may tag any
+	if current := middleware.CurrentUser(c); current.ID_user != post.ID_user && !middleware.Can(c, auth.PermTicketWork) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only change the tags of your own posts"})
+	}
+
+	before := *post
+	post.Tags = tags
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Tags.SetPostTags(ctx, post.ID_Posts, tags); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostTags, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error updating post tags in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update post tags"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post tags updated successfully", "tags": post.Tags})
+}
+
+// MergeTag replaces a tag with another on every post, renaming it when the
+// other tag does not exist yet
+func (h *Handler) MergeTag(c *fiber.Ctx) error {
+	var req struct {
+		Into string `json:"into"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	from, ok := models.NormalizeTag(c.Params("tag"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid tag"})
+	}
+	into, ok := models.NormalizeTag(req.Into)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "into must be letters, digits and single hyphens, at most 32 characters"})
+	}
+	if into == from {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A tag cannot be merged into itself"})
+	}
+
+	var merged *models.Tag
+	var moved int
+	err := h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		before, err := h.Tags.GetByName(ctx, from)
+		if err != nil {
+			return err
+		}
+		if moved, err = h.Tags.Merge(ctx, from, into); err != nil {
+			return err
+		}
+		if merged, err = h.Tags.GetByName(ctx, into); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditTagMerge, models.AuditTargetTag, before.ID_tag, before, merged)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	} else if err != nil {
+		log.Println("Error merging tags in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not merge tags"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Tag merged successfully", "tag": merged, "posts": moved})
+}
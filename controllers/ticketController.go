@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/middleware"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// canMoveTicket reports whether the caller may move the post to the status;
+// besides agents, authors may close their ticket or reopen it once resolved
+func canMoveTicket(c *fiber.Ctx, post *models.Post, to string) bool {
+	if middleware.Can(c, auth.PermTicketWork) {
+		return true
+	}
+	if middleware.CurrentUser(c).ID_user != post.ID_user {
+		return false
+	}
+	return to == models.StatusClosed || (post.Status == models.StatusResolved && to == models.StatusOpen)
+}
+
+// SetPostStatus moves a post to another ticket status
+func (h *Handler) SetPostStatus(c *fiber.Ctx) error {
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if !slices.Contains(models.Statuses, req.Status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status", "statuses": models.Statuses})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+	if !canMoveTicket(c, post, req.Status) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only agents can move this ticket to that status"})
+	}
+	if !models.CanTransition(post.Status, req.Status) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   fmt.Sprintf("A ticket cannot move from %s to %s", post.Status, req.Status),
+			"status":  post.Status,
+			"allowed": models.NextStatuses(post.Status),
+		})
+	}
+
+	// Move the post, guarded against a concurrent move from the same status
+	before := *post
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		change, err := h.Posts.SetStatus(ctx, post.ID_Posts, post.Status, req.Status, middleware.CurrentUser(c).ID_user)
+		if err != nil {
+			return err
+		}
+		post.Status, post.StatusChangedAt = change.ToStatus, change.CreatedAt
+		return h.audit(ctx, c, models.AuditPostStatus, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrStale) {
+		return h.postConflict(c, post.ID_Posts)
+	} else if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error updating post status in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update ticket status"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":           "Ticket status updated successfully",
+		"status":            post.Status,
+		"status_changed_at": post.StatusChangedAt.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetPostStatusHistory lists every status change of a post, oldest first
+func (h *Handler) GetPostStatusHistory(c *fiber.Ctx) error {
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	changes, err := h.Posts.ListStatusChanges(c.UserContext(), post.ID_Posts)
+	if err != nil {
+		log.Println("Error querying status changes from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	return c.JSON(changes)
+}
+
+// AssignPost sets or clears the agent working on a post. Agents may take
+// unassigned tickets and give back their own; assigning others needs
+// PermTicketAssign.
+func (h *Handler) AssignPost(c *fiber.Ctx) error {
+	var req struct {
+		ID_assignee *int `json:"id_assignee"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	post, err := h.loadPost(c)
+	if post == nil {
+		return err
+	}
+
+	if current := middleware.CurrentUser(c); !middleware.Can(c, auth.PermTicketAssign) {
+		if post.ID_assignee != nil && *post.ID_assignee != current.ID_user {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This ticket is assigned to another agent"})
+		}
+		if req.ID_assignee != nil && *req.ID_assignee != current.ID_user {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only assign tickets to yourself"})
+		}
+	}
+
+	// Only users who can work tickets may be assigned one
+	if req.ID_assignee != nil {
+		assignee, err := h.Users.GetByID(c.UserContext(), *req.ID_assignee)
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Assignee not found"})
+		} else if err != nil {
+			log.Println("Error querying assignee from database:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+		if !auth.HasPermission(assignee.Role, auth.PermTicketWork) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Tickets can only be assigned to agents"})
+		}
+	}
+
+	before := *post
+	post.ID_assignee = req.ID_assignee
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Posts.Assign(ctx, post.ID_Posts, req.ID_assignee); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostAssign, models.AuditTargetPost, post.ID_Posts, &before, post)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found"})
+	} else if err != nil {
+		log.Println("Error assigning post in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not assign ticket"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Ticket assigned successfully", "id_assignee": req.ID_assignee})
+}
+
+// GetMyQueue lists the tickets assigned to the caller, by default the ones
+// still waiting for work with the oldest first
+func (h *Handler) GetMyQueue(c *fiber.Ctx) error {
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter.AssigneeID, filter.Unassigned = middleware.CurrentUser(c).ID_user, false
+	if c.Query("status") == "" {
+		filter.Statuses = models.ActiveStatuses
+	}
+	if c.Query("sort") == "" {
+		filter.Sort = repository.SortOldest
+	}
+
+	page, err := h.Posts.List(c.UserContext(), *filter)
+	if err != nil {
+		log.Println("Error querying ticket queue from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying posts"})
+	}
+
+	return c.JSON(newPostPageResponse(filter, page))
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetPostTrash lists the trashed posts with the same paging, sorting and
+// filters as the post listing
+func (h *Handler) GetPostTrash(c *fiber.Ctx) error {
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter.Trash = true
+
+	page, err := h.Posts.List(c.UserContext(), *filter)
+	if err != nil {
+		log.Println("Error querying trashed posts from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying posts"})
+	}
+
+	return c.JSON(newPostPageResponse(filter, page))
+}
+
+// RestorePost takes a post out of the trash
+func (h *Handler) RestorePost(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id_post")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid post id"})
+	}
+
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Posts.Restore(ctx, id); err != nil {
+			return err
+		}
+		post, err := h.Posts.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditPostRestore, models.AuditTargetPost, id, nil, post)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Post not found in trash"})
+	} else if err != nil {
+		log.Println("Error restoring post in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not restore post"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Post restored successfully"})
+}
+
+// GetUserTrash lists the trashed users, most recently deleted first
+func (h *Handler) GetUserTrash(c *fiber.Ctx) error {
+	users, err := h.Users.ListDeleted(c.UserContext())
+	if err != nil {
+		log.Println("Error querying trashed users from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying users"})
+	}
+
+	return c.JSON(users)
+}
+
+// RestoreUser takes a user out of the trash
+func (h *Handler) RestoreUser(c *fiber.Ctx) error {
+	ID_user, err := c.ParamsInt("id_user")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user id"})
+	}
+
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Users.Restore(ctx, ID_user); err != nil {
+			return err
+		}
+		user, err := h.Users.GetByID(ctx, ID_user)
+		if err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditUserRestore, models.AuditTargetUser, ID_user, nil, user)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found in trash"})
+	} else if err != nil {
+		log.Println("Error restoring user in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not restore user"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User restored successfully"})
+}
+
+// PurgeTrash permanently removes the posts and users trashed before the
+// cutoff, together with their stored files, and returns how many of each
+// were removed
+func (h *Handler) PurgeTrash(ctx context.Context, cutoff time.Time) (posts, users int, err error) {
+	postIDs, err := h.Posts.ListDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, id := range postIDs {
+		// Note the attached files before their rows go with the post
+		attachments, err := h.Attachments.ListByPost(ctx, id)
+		if err != nil {
+			return posts, users, err
+		}
+		err = h.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := h.Posts.Purge(ctx, id); err != nil {
+				return err
+			}
+			return h.recordSystemAudit(ctx, models.AuditPostPurge, models.AuditTargetPost, id, nil)
+		})
+		if err != nil {
+			return posts, users, err
+		}
+		h.removeAttachmentFiles(ctx, attachments...)
+		posts++
+	}
+
+	trashed, err := h.Users.ListDeleted(ctx)
+	if err != nil {
+		return posts, users, err
+	}
+	for _, user := range trashed {
+		if user.DeletedAt == nil || !user.DeletedAt.Before(cutoff) {
+			continue
+		}
+		attachments, err := h.Attachments.ListOwnedBy(ctx, user.ID_user)
+		if err != nil {
+			return posts, users, err
+		}
+		err = h.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := h.Users.Purge(ctx, user.ID_user); err != nil {
+				return err
+			}
+			return h.recordSystemAudit(ctx, models.AuditUserPurge, models.AuditTargetUser, user.ID_user, nil)
+		})
+		if err != nil {
+			return posts, users, err
+		}
+		h.removeAttachmentFiles(ctx, attachments...)
+		if user.Picture != nil && *user.Picture != "" {
+			h.removeProfilePicture(ctx, *user.Picture)
+		}
+		users++
+	}
+
+	return posts, users, nil
+}
+
+// recordSystemAudit records a change made by the server itself rather than
+// an API caller, with what it found as the after state when not nil
+func (h *Handler) recordSystemAudit(ctx context.Context, action, targetType string, targetID int, after any) error {
+	entry, err := newAuditEntry(action, targetType, targetID, nil, after)
+	if err != nil {
+		return err
+	}
+	return h.Audit.Record(ctx, entry)
+}
+
+// RunPurge purges the trash every Trash.PurgeInterval, removing what was
+// deleted more than Trash.Retention ago, until ctx is done
+func (h *Handler) RunPurge(ctx context.Context) {
+	ticker := time.NewTicker(h.Trash.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		posts, users, err := h.PurgeTrash(ctx, time.Now().Add(-h.Trash.Retention))
+		if err != nil {
+			log.Println("Error purging trash:", err)
+		} else if posts > 0 || users > 0 {
+			log.Printf("Purged %d posts and %d users from the trash", posts, users)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,143 +1,129 @@
 package controllers
 
 import (
-	"backend-nagaricare/database"
+	"backend-nagaricare/auth"
+	"backend-nagaricare/imaging"
+	"backend-nagaricare/middleware"
 	"backend-nagaricare/models"
-	"database/sql"
+	"backend-nagaricare/repository"
+	"backend-nagaricare/storage"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"os"
-	"path/filepath"
-	"strings"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // CreateUser handles user sign-up and inserts user details into the database
-func CreateUser(c *fiber.Ctx) error {
+func (h *Handler) CreateUser(c *fiber.Ctx) error {
 	// Parse the request body into the User struct
 	var req models.User
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Check if the email is already registered
-	var existingUser string
-	err := database.DB.QueryRow("SELECT email FROM users WHERE id_user = ?", req.ID_user).Scan(&existingUser)
-	if err == nil {
-		// Email already exists
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email already registered"})
-	} else if err != sql.ErrNoRows {
-		// Database error
-		log.Println("Error querying user from database:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	// New users start as customers unless a valid role is given
+	if req.Role == "" {
+		req.Role = models.RoleCustomer
+	} else if !slices.Contains(models.Roles, req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid role", "roles": models.Roles})
 	}
 
 	// Insert the new user into the database
-	_, err = database.DB.Exec("INSERT INTO users (id_user, email, name, phone, profile_picture) VALUES (?, ?, ?, ?, ?)", req.ID_user, req.Email, req.Name, req.Phone, req.Picture)
-	if err != nil {
+	err := h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Users.Create(ctx, &req); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditUserCreate, models.AuditTargetUser, req.ID_user, nil, &req)
+	})
+	if errors.Is(err, repository.ErrConflict) {
+		// Email or ID already exists
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Email already registered"})
+	} else if err != nil {
 		log.Println("Error inserting user into database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create user"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User created successfully"})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User created successfully", "id_user": req.ID_user})
 }
 
 // GetUsers retrieves all users from the database
-func GetUsers(c *fiber.Ctx) error {
+func (h *Handler) GetUsers(c *fiber.Ctx) error {
 	// Query the database for all users
-	rows, err := database.DB.Query("SELECT id_user, email, name, phone, profile_picture FROM users")
+	users, err := h.Users.List(c.UserContext())
 	if err != nil {
 		log.Println("Error querying users from database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error querying users"})
 	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		// Scan each row into the User struct
-		if err := rows.Scan(&user.ID_user, &user.Email, &user.Name, &user.Phone, &user.Picture); err != nil {
-			log.Println("Error scanning user:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error scanning user"})
-		}
-
-		// // No need to reassign; just check if the phone is valid
-		// if !user.Phone.Valid {
-		// 	user.Phone.String = "" // If phone is NULL, set it to an empty string
-		// }
-		// if !user.Picture.Valid {
-		// 	user.Picture.String = "" // If picture is NULL, set it to an empty string
-		// }
-
-		users = append(users, user)
-	}
 
 	// Return the list of users as JSON
 	return c.JSON(users)
 }
 
-// GetUserDetails retrieves the details of the currently logged-in user
-func GetUserDetails(c *fiber.Ctx) error {
-	// Get the email from the query parameter
-	ID_user := c.Params("id_user")
-	if ID_user == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No user id provided"})
+// GetUserDetails retrieves the details of a user by their ID; only the user
+// and staff allowed to list users see contact details and role
+func (h *Handler) GetUserDetails(c *fiber.Ctx) error {
+	ID_user, err := c.ParamsInt("id_user")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user id"})
 	}
 
 	// Query the database for the user details
-	var user models.User
-	err := database.DB.QueryRow("SELECT id_user, email, name, phone, profile_picture FROM users WHERE id_user = ?", ID_user).Scan(&user.ID_user, &user.Email, &user.Name, &user.Phone, &user.Picture)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-		}
+	user, err := h.Users.GetByID(c.UserContext(), ID_user)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	} else if err != nil {
 		log.Println("Error querying user details from database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
 
-	// Prepare response struct to handle NULL phone values
-	response := struct {
-		ID_user int     `json:"id_user"`
-		Email   string  `json:"email"`
-		Name    string  `json:"name"`
-		Phone   *string `json:"phone"`
-		Picture *string `json:"profile_picture"`
-	}{
-		ID_user: user.ID_user,
-		Email:   user.Email,
-		Name:    user.Name,
-		Phone:   user.Phone,
-		Picture: user.Picture,
+	if current := middleware.CurrentUser(c); current.ID_user != user.ID_user && !middleware.Can(c, auth.PermUserList) {
+		return c.JSON(user.Public())
 	}
 
-	// Return the user details as JSON
-	return c.JSON(response)
+	// Return the user details as JSON, tagged with their version for later updates
+	setETag(c, user.Version)
+	return c.JSON(user)
 }
 
-// UserSignIn handles user sign-in via Google and inserts user details if not already present
-func SignInGoogle(c *fiber.Ctx) error {
+// SignInGoogle verifies a Google/Firebase ID token, creates the user on first
+// sign-in and returns our own access and refresh tokens
+func (h *Handler) SignInGoogle(c *fiber.Ctx) error {
 	var req struct {
-		Email string `json:"email"`
-		Name  string `json:"name"`
+		IDToken string `json:"id_token"`
 	}
 
 	// Parse the request body
-	if err := c.BodyParser(&req); err != nil {
+	if err := c.BodyParser(&req); err != nil || req.IDToken == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Check if the user already exists in the database
-	var existingUser string
-	err := database.DB.QueryRow("SELECT email FROM users WHERE email = ?", req.Email).Scan(&existingUser)
+	// Verify the ID token signature and claims; never trust a client-supplied email
+	claims, err := h.Google.Verify(req.IDToken)
+	if err != nil {
+		log.Println("Rejected Google ID token:", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid ID token"})
+	}
 
-	if err == sql.ErrNoRows {
+	// Check if the user already exists in the database
+	user, err := h.Users.GetByEmail(c.UserContext(), claims.Email)
+	if errors.Is(err, repository.ErrNotFound) {
 		// If user doesn't exist, insert new user
-		_, err := database.DB.Exec("INSERT INTO users (email, name) VALUES (?, ?)", req.Email, req.Name)
-		if err != nil {
+		user = &models.User{Email: claims.Email, Name: claims.Name, Role: models.RoleCustomer}
+		err := h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+			if err := h.Users.Create(ctx, user); err != nil {
+				return err
+			}
+			return h.audit(ctx, c, models.AuditUserCreate, models.AuditTargetUser, user.ID_user, nil, user)
+		})
+		if errors.Is(err, repository.ErrConflict) {
+			// The email belongs to an account in the trash
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This account has been deleted"})
+		} else if err != nil {
 			log.Println("Error inserting new user into database:", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create user"})
 		}
@@ -146,29 +132,82 @@ func SignInGoogle(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User signed in successfully"})
+	// Issue our own session tokens tied to users.id_user
+	tokens, err := h.Tokens.Issue(user.ID_user)
+	if err != nil {
+		log.Println("Error issuing session tokens:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not sign in"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":       "User signed in successfully",
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          user,
+	})
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair
+func (h *Handler) RefreshToken(c *fiber.Ctx) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// Parse the request body
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userID, err := h.Tokens.ParseRefresh(req.RefreshToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+	}
+
+	// Make sure the user still exists before handing out new tokens
+	_, err = h.Users.GetByID(c.UserContext(), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+	} else if err != nil {
+		log.Println("Error querying user from database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	tokens, err := h.Tokens.Issue(userID)
+	if err != nil {
+		log.Println("Error issuing session tokens:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not refresh token"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(tokens)
 }
 
 // SaveUserPhoto saves the user's photo and updates the profile_picture field in the database.
-func SaveUserPhoto(c *fiber.Ctx) error {
+func (h *Handler) SaveUserPhoto(c *fiber.Ctx) error {
 	// Parse user ID from URL parameter
-	ID_user := c.Params("id_user")
-	if ID_user == "" {
+	ID_user, err := c.ParamsInt("id_user")
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "User ID is required",
 		})
 	}
 
+	// Only the profile owner or staff allowed to edit any user may change the picture
+	if !canEditUser(c, ID_user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You can only change your own profile picture",
+		})
+	}
+
 	// Check if the user exists and retrieve the current profile picture path
-	db := database.DB
-	var currentProfilePicturePath sql.NullString // Allows for null values
-	err := db.QueryRow(`SELECT profile_picture FROM users WHERE id_user = ?`, ID_user).Scan(&currentProfilePicturePath)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "User not found",
-			})
-		}
+	user, err := h.Users.GetByID(c.UserContext(), ID_user)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	} else if err != nil {
+		log.Println("Error querying user from database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database query failed",
 		})
@@ -178,14 +217,23 @@ func SaveUserPhoto(c *fiber.Ctx) error {
 	file, err := c.FormFile("profile_picture")
 	if file == nil {
 		// Set profile_picture to NULL in the database
-		query := `UPDATE users SET profile_picture = NULL WHERE id_user = ?`
-		_, err = db.Exec(query, ID_user)
+		after := *user
+		after.Picture = nil
+		err := h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+			if err := h.Users.SetPicture(ctx, ID_user, nil); err != nil {
+				return err
+			}
+			return h.audit(ctx, c, models.AuditUserPicture, models.AuditTargetUser, ID_user, user, &after)
+		})
 		if err != nil {
-			log.Printf("Failed to execute query: %s, error: %s", query, err.Error())
+			log.Println("Error clearing profile picture:", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Database update failed",
 			})
 		}
+		if user.Picture != nil && *user.Picture != "" {
+			h.removeProfilePicture(c.UserContext(), *user.Picture)
+		}
 
 		// Return success response indicating profile picture was set to NULL
 		return c.JSON(fiber.Map{
@@ -198,47 +246,64 @@ func SaveUserPhoto(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate a unique file name and save path
-	fileExt := strings.ToLower(filepath.Ext(file.Filename))
-	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), fileExt)
-	saveDir := "./userProfile/"
-	filePath := filepath.Join(saveDir, fileName)
-
-	// Create the directory if it doesn't exist
-	if _, err := os.Stat(saveDir); os.IsNotExist(err) {
-		os.MkdirAll(saveDir, os.ModePerm)
+	// Enforce the configured upload size limit before reading the file
+	if file.Size > h.Uploads.MaxBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("File must not exceed %d bytes", h.Uploads.MaxBytes),
+			"code":  imaging.CodeTooLarge,
+		})
 	}
 
-	// Save the new profile picture
-	if err := c.SaveFile(file, filePath); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to save file",
+	// Validate the content and re-encode it to strip metadata
+	src, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "File upload failed",
 		})
 	}
-
-	// Delete the previous profile picture if it exists and is not the default image
-	if currentProfilePicturePath.Valid && currentProfilePicturePath.String != "/default/profile_picture.png" {
-		oldFilePath := filepath.Join(".", currentProfilePicturePath.String)
-		if _, err := os.Stat(oldFilePath); err == nil {
-			if err := os.Remove(oldFilePath); err != nil {
-				log.Printf("Failed to delete old profile picture: %s", err)
-			}
-		}
+	defer src.Close()
+	img, err := imaging.Process(src, imaging.Limits{MaxBytes: h.Uploads.MaxBytes, MaxDimension: h.Uploads.MaxDimension})
+	if err != nil {
+		return imageError(c, err)
 	}
 
+	// Name the file after the detected format, never the client's extension
+	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), img.Ext)
+
 	// Define relative path to save in database
 	relativePath := fmt.Sprintf("/userProfile/%s", fileName)
 
+	// Store the new profile picture and its thumbnails
+	if err := h.saveProfilePicture(c.UserContext(), relativePath, img); err != nil {
+		log.Println("Error saving profile picture:", err)
+		h.removeProfilePicture(c.UserContext(), relativePath)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save file",
+		})
+	}
+
 	// Update the profile picture path in the database
-	query := `UPDATE users SET profile_picture = ? WHERE id_user = ?`
-	_, err = db.Exec(query, relativePath, ID_user)
+	after := *user
+	after.Picture = &relativePath
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		if err := h.Users.SetPicture(ctx, ID_user, &relativePath); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditUserPicture, models.AuditTargetUser, ID_user, user, &after)
+	})
 	if err != nil {
-		log.Printf("Failed to execute query: %s, error: %s", query, err.Error())
+		log.Println("Error updating profile picture:", err)
+		h.removeProfilePicture(c.UserContext(), relativePath)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database update failed",
 		})
 	}
 
+	// Delete the previous profile picture if it exists and is not the default image
+	if user.Picture != nil && *user.Picture != "" && *user.Picture != "/default/profile_picture.png" {
+		h.removeProfilePicture(c.UserContext(), *user.Picture)
+	}
+
 	// Return success response with new profile picture path
 	return c.JSON(fiber.Map{
 		"message":         "Profile picture updated successfully",
@@ -247,91 +312,86 @@ func SaveUserPhoto(c *fiber.Ctx) error {
 }
 
 // GetUserPhoto retrieves the user's profile picture based on their ID
-func GetUserPhoto(c *fiber.Ctx) error {
+func (h *Handler) GetUserPhoto(c *fiber.Ctx) error {
 	// Parse the user ID from the URL parameter
-	ID_user := c.Params("id_user")
-	if ID_user == "" {
+	ID_user, err := c.ParamsInt("id_user")
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "User ID is required",
 		})
 	}
 
 	// Check if the user exists and retrieve the profile picture path
-	db := database.DB
-	var profilePicturePath string
-	err := db.QueryRow(`SELECT profile_picture FROM users WHERE id_user = ?`, ID_user).Scan(&profilePicturePath)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "User not found",
-			})
-		}
+	user, err := h.Users.GetByID(c.UserContext(), ID_user)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	} else if err != nil {
+		log.Println("Error querying user from database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Database query failed",
 		})
 	}
 
-	// If the profile picture path is empty, return null in the JSON response
-	if profilePicturePath == "" {
-		return c.JSON(fiber.Map{
-			"profile_picture": nil,
-		})
+	// Pick the original or the requested square size
+	size := 0
+	if c.Query("size") != "" {
+		size, err = strconv.Atoi(c.Query("size"))
+		if err != nil || size < imaging.MinThumbnailSize || size > imaging.MaxThumbnailSize {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("size must be between %d and %d", imaging.MinThumbnailSize, imaging.MaxThumbnailSize),
+			})
+		}
 	}
 
-	// Build the absolute path for the profile picture
-	absolutePath := filepath.Join(".", profilePicturePath)
-
-	// Open and read the profile picture file
-	file, err := os.Open(absolutePath)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to open profile picture",
-		})
+	// Without a profile picture, draw an avatar from the user's initials
+	if user.Picture == nil || *user.Picture == "" {
+		return h.sendAvatar(c, user, size)
 	}
-	defer file.Close()
 
-	// Read file content as bytes
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
+	// Open the profile picture from storage
+	obj, err := h.openProfilePicture(c.UserContext(), *user.Picture, size)
+	if errors.Is(err, storage.ErrNotFound) {
+		// The file went missing, still answer with an image
+		log.Println("Profile picture missing from storage:", *user.Picture)
+		return h.sendAvatar(c, user, size)
+	} else if err != nil {
+		log.Println("Error opening profile picture:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to read profile picture file",
+			"error": "Failed to open profile picture",
 		})
 	}
 
-	// Set the appropriate content type
-	fileExt := strings.ToLower(filepath.Ext(absolutePath))
-	var contentType string
-	switch fileExt {
-	case ".jpg", ".jpeg":
-		contentType = "image/jpeg"
-	case ".png":
-		contentType = "image/png"
-	default:
-		contentType = "application/octet-stream" // Fallback for unknown types
-	}
-
-	// Set the response content type and send the file content
-	c.Set("Content-Type", contentType)
-	return c.Send(fileBytes)
+	// Let clients reuse the picture for a while, then revalidate it with the ETag
+	c.Set(fiber.HeaderCacheControl, h.pictureCacheControl())
+	return sendObject(c, obj, "")
 }
 
+// errEmailReadOnly refuses email changes, which would let an account claim
+// someone else's Google sign-in
+var errEmailReadOnly = errors.New("email cannot be changed")
+
 // UpdateUser updates an existing user data
-func UpdateUser(c *fiber.Ctx) error {
-	ID_user := c.Params("id_user")
+func (h *Handler) UpdateUser(c *fiber.Ctx) error {
+	ID_user, err := c.ParamsInt("id_user")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user id"})
+	}
 	var req models.User
-	log.Printf("Request data: %+v\n", req)
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Check if the user exists
-	var existingUser string
-	err := database.DB.QueryRow("SELECT id_user FROM users WHERE id_user = ?", ID_user).Scan(&existingUser)
-	if err == sql.ErrNoRows {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-	} else if err != nil {
-		log.Println("Error querying user from database:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	// Only the profile owner or staff allowed to edit any user may edit the profile
+	if !canEditUser(c, ID_user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only edit your own profile"})
+	}
+
+	// The edit must be based on the current version of the profile
+	version, err := checkVersion(c, req.Version)
+	if version == 0 {
+		return err
 	}
 
 	// Set default values if any fields are nil
@@ -339,20 +399,141 @@ func UpdateUser(c *fiber.Ctx) error {
 		req.Phone = new(string)
 		*req.Phone = "" // Set a default empty string if needed
 	}
-	// if req.Picture == nil {
-	// 	req.Picture = new(string)
-	// 	*req.Picture = "" // Set a default empty string if needed
-	// }
 
 	// Update user
-	_, err = database.DB.Exec(
-		"UPDATE users SET email = ?, name = ?, phone = ? WHERE id_user = ?",
-		req.Email, req.Name, req.Phone, ID_user,
-	)
-	if err != nil {
+	req.ID_user, req.Version = ID_user, version
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		before, err := h.Users.GetByID(ctx, ID_user)
+		if err != nil {
+			return err
+		}
+		// Sign-in matches users by email, so it stays the one Google verified
+		if req.Email != "" && req.Email != before.Email {
+			return errEmailReadOnly
+		}
+		req.Email = before.Email
+		if err := h.Users.Update(ctx, &req); err != nil {
+			return err
+		}
+		after := *before
+		after.Email, after.Name, after.Phone, after.Version = req.Email, req.Name, req.Phone, req.Version
+		return h.audit(ctx, c, models.AuditUserUpdate, models.AuditTargetUser, ID_user, before, &after)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	} else if errors.Is(err, repository.ErrStale) {
+		current, err := h.Users.GetByID(c.UserContext(), ID_user)
+		if err != nil {
+			log.Println("Error querying user from database:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+		return conflict(c, current.Version, current)
+	} else if errors.Is(err, errEmailReadOnly) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	} else if err != nil {
 		log.Println("Error updating user in database:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update user"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User updated successfully"})
+	setETag(c, req.Version)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User updated successfully", "version": req.Version})
+}
+
+// UpdateUserRole changes the role of a user
+func (h *Handler) UpdateUserRole(c *fiber.Ctx) error {
+	ID_user, err := c.ParamsInt("id_user")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user id"})
+	}
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if !slices.Contains(models.Roles, req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid role", "roles": models.Roles})
+	}
+
+	// Update the role
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		before, err := h.Users.GetByID(ctx, ID_user)
+		if err != nil {
+			return err
+		}
+		if err := h.Users.UpdateRole(ctx, ID_user, req.Role); err != nil {
+			return err
+		}
+		after := *before
+		after.Role = req.Role
+		return h.audit(ctx, c, models.AuditUserRoleUpdate, models.AuditTargetUser, ID_user, before, &after)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	} else if err != nil {
+		log.Println("Error updating user role in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update role"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User role updated successfully", "role": req.Role})
+}
+
+// DeleteUser moves an account to the trash, from where an admin can
+// restore it until it is purged
+func (h *Handler) DeleteUser(c *fiber.Ctx) error {
+	ID_user, err := c.ParamsInt("id_user")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user id"})
+	}
+
+	// Users may close their own account; admins may delete any
+	if current := middleware.CurrentUser(c); current.ID_user != ID_user && !middleware.Can(c, auth.PermUserDeleteAny) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You can only delete your own account"})
+	}
+
+	err = h.Tx.WithinTx(c.UserContext(), func(ctx context.Context) error {
+		before, err := h.Users.GetByID(ctx, ID_user)
+		if err != nil {
+			return err
+		}
+		if err := h.Users.Delete(ctx, ID_user); err != nil {
+			return err
+		}
+		return h.audit(ctx, c, models.AuditUserDelete, models.AuditTargetUser, ID_user, before, nil)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	} else if err != nil {
+		log.Println("Error deleting user in database:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete user"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User deleted successfully"})
+}
+
+// imageError answers a rejected upload with its error code
+func imageError(c *fiber.Ctx, err error) error {
+	var imgErr *imaging.Error
+	if !errors.As(err, &imgErr) {
+		log.Println("Error processing image:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process image"})
+	}
+
+	status := fiber.StatusUnprocessableEntity
+	switch imgErr.Code {
+	case imaging.CodeTooLarge:
+		status = fiber.StatusRequestEntityTooLarge
+	case imaging.CodeUnsupportedType:
+		status = fiber.StatusUnsupportedMediaType
+	}
+	return c.Status(status).JSON(fiber.Map{"error": imgErr.Message, "code": imgErr.Code})
+}
+
+// canEditUser reports whether the authenticated caller may modify the given user
+func canEditUser(c *fiber.Ctx, ID_user int) bool {
+	current := middleware.CurrentUser(c)
+	if current == nil {
+		return false
+	}
+	return current.ID_user == ID_user || middleware.Can(c, auth.PermUserUpdateAny)
 }
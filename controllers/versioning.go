@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errNoVersion is returned by requestedVersion when the caller did not say
+// which version its change is based on
+var errNoVersion = errors.New("send the version being changed in If-Match or the version field")
+
+// etag is the entity tag of a versioned resource
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag tags the response with the version of the resource it carries
+func setETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, etag(version))
+}
+
+// requestedVersion returns the version a change is based on, taken from the
+// If-Match header or else from the version field of the body
+func requestedVersion(c *fiber.Ctx, bodyVersion int) (int, error) {
+	match := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	// "*" is a valid precondition matching any version, but it does not say
+	// which one the change is based on
+	if match == "" || match == "*" {
+		if bodyVersion < 1 {
+			return 0, errNoVersion
+		}
+		return bodyVersion, nil
+	}
+
+	// Only a single strong tag as sent by setETag names a version
+	tag, okPrefix := strings.CutPrefix(match, `"`)
+	tag, okSuffix := strings.CutSuffix(tag, `"`)
+	if !okPrefix || !okSuffix {
+		return 0, errors.New("If-Match must be an ETag of the resource")
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, errors.New("If-Match must be an ETag of the resource")
+	}
+	return version, nil
+}
+
+// checkVersion reads the requested version, writing the error response
+// itself when it returns 0
+func checkVersion(c *fiber.Ctx, bodyVersion int) (int, error) {
+	version, err := requestedVersion(c, bodyVersion)
+	if errors.Is(err, errNoVersion) {
+		return 0, c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{"error": err.Error()})
+	} else if err != nil {
+		return 0, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return version, nil
+}
+
+// conflict answers a change based on an outdated version with the current
+// representation of the resource
+func conflict(c *fiber.Ctx, version int, current any) error {
+	setETag(c, version)
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":   "The resource was changed since it was read",
+		"current": current,
+	})
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"backend-nagaricare/config"
 	"database/sql"
 	"log"
 
@@ -9,15 +10,19 @@ import (
 
 var DB *sql.DB
 
-// ConnectDB connects to the MySQL database
-func ConnectDB() {
+// ConnectDB connects to the MySQL database and sizes the connection pool
+func ConnectDB(cfg config.DatabaseConfig) {
 	var err error
 
-	DB, err = sql.Open("mysql", "root:@tcp(127.0.0.1:3306)/forum_posts")
+	DB, err = sql.Open("mysql", cfg.DSN)
 	if err != nil {
 		log.Fatal("Failed to connect to the database: ", err)
 	}
 
+	DB.SetMaxOpenConns(cfg.MaxOpenConns)
+	DB.SetMaxIdleConns(cfg.MaxIdleConns)
+	DB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	// Ping the database to ensure connection is established
 	err = DB.Ping()
 	if err != nil {
@@ -0,0 +1,124 @@
+// Package diff compares two texts line by line
+package diff
+
+import "strings"
+
+// Operations of a chunk, telling how to get from the old text to the new one
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// maxCells bounds the comparison table; texts with more line pairs than
+// this are reported as entirely replaced
+const maxCells = 4 << 20
+
+// Chunk is a run of consecutive lines with the same operation
+type Chunk struct {
+	Op    string   `json:"op"`
+	Lines []string `json:"lines"`
+}
+
+// Lines returns the chunks turning a into b, keeping as many lines as
+// possible unchanged
+func Lines(a, b string) []Chunk {
+	x, y := splitLines(a), splitLines(b)
+
+	// Common leading and trailing lines need no table
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var chunks []Chunk
+	add := func(op string, line string) {
+		if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+			chunks[n-1].Lines = append(chunks[n-1].Lines, line)
+			return
+		}
+		chunks = append(chunks, Chunk{Op: op, Lines: []string{line}})
+	}
+
+	for _, line := range x[:prefix] {
+		add(OpEqual, line)
+	}
+	for _, edit := range middle(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]) {
+		add(edit.op, edit.line)
+	}
+	for _, line := range x[len(x)-suffix:] {
+		add(OpEqual, line)
+	}
+	return chunks
+}
+
+type edit struct {
+	op   string
+	line string
+}
+
+// middle diffs the lines between the common prefix and suffix through
+// their longest common subsequence
+func middle(x, y []string) []edit {
+	var edits []edit
+	if len(x)*len(y) > maxCells {
+		for _, line := range x {
+			edits = append(edits, edit{OpDelete, line})
+		}
+		for _, line := range y {
+			edits = append(edits, edit{OpInsert, line})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table, preferring deletions so they precede insertions
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			edits = append(edits, edit{OpEqual, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{OpDelete, x[i]})
+			i++
+		default:
+			edits = append(edits, edit{OpInsert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		edits = append(edits, edit{OpDelete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		edits = append(edits, edit{OpInsert, y[j]})
+	}
+	return edits
+}
+
+// splitLines splits s on line breaks; an empty text has no lines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Chunk
+	}{
+		{"identical", "a\nb", "a\nb", []Chunk{{OpEqual, []string{"a", "b"}}}},
+		{"both empty", "", "", nil},
+		{"from empty", "", "a\nb", []Chunk{{OpInsert, []string{"a", "b"}}}},
+		{"to empty", "a", "", []Chunk{{OpDelete, []string{"a"}}}},
+		{
+			name: "changed line",
+			a:    "hello\nmy transfer failed\nthanks",
+			b:    "hello\nmy transfer failed twice\nthanks",
+			want: []Chunk{
+				{OpEqual, []string{"hello"}},
+				{OpDelete, []string{"my transfer failed"}},
+				{OpInsert, []string{"my transfer failed twice"}},
+				{OpEqual, []string{"thanks"}},
+			},
+		},
+		{
+			name: "moved and added lines",
+			a:    "a\nb\nc\nd",
+			b:    "b\nc\na\nd\ne",
+			want: []Chunk{
+				{OpDelete, []string{"a"}},
+				{OpEqual, []string{"b", "c"}},
+				{OpInsert, []string{"a"}},
+				{OpEqual, []string{"d"}},
+				{OpInsert, []string{"e"}},
+			},
+		},
+		{"windows line breaks", "a\r\nb", "a\nb", []Chunk{{OpEqual, []string{"a", "b"}}}},
+	}
+	for _, tt := range tests {
+		if got := Lines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Lines = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLinesTooLarge(t *testing.T) {
+	// Beyond maxCells the texts are reported as replaced, around the shared edges
+	a := "start\n" + strings.Repeat("x\n", 3000) + "end"
+	b := "start\n" + strings.Repeat("y\n", 3000) + "end"
+	got := Lines(a, b)
+	if len(got) != 4 || got[0].Op != OpEqual || got[1].Op != OpDelete || got[2].Op != OpInsert || got[3].Op != OpEqual {
+		t.Fatalf("chunks = %d", len(got))
+	}
+	if len(got[1].Lines) != 3000 || len(got[2].Lines) != 3000 {
+		t.Errorf("replaced %d lines with %d", len(got[1].Lines), len(got[2].Lines))
+	}
+}
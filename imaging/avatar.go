@@ -0,0 +1,133 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// DefaultAvatarSize is the side of a generated avatar when no size is requested
+const DefaultAvatarSize = 256
+
+// avatarPalette holds the avatar backgrounds; white initials are readable on all of them
+var avatarPalette = []color.RGBA{
+	{0xE5, 0x39, 0x35, 0xFF}, // Red
+	{0xD8, 0x1B, 0x60, 0xFF}, // Pink
+	{0x8E, 0x24, 0xAA, 0xFF}, // Purple
+	{0x5E, 0x35, 0xB1, 0xFF}, // Deep purple
+	{0x39, 0x49, 0xAB, 0xFF}, // Indigo
+	{0x1E, 0x88, 0xE5, 0xFF}, // Blue
+	{0x03, 0x9B, 0xE5, 0xFF}, // Light blue
+	{0x00, 0x89, 0x7B, 0xFF}, // Teal
+	{0x43, 0xA0, 0x47, 0xFF}, // Green
+	{0xF4, 0x51, 0x1E, 0xFF}, // Deep orange
+	{0x6D, 0x4C, 0x41, 0xFF}, // Brown
+	{0x54, 0x6E, 0x7A, 0xFF}, // Blue grey
+}
+
+// Avatar is a generated picture showing a user's initials on a coloured square
+type Avatar struct {
+	Initials   string
+	Background color.RGBA
+}
+
+// NewAvatar derives the avatar of a user; the same name and ID always give
+// the same avatar
+func NewAvatar(name string, id int) Avatar {
+	h := fnv.New32a()
+	fmt.Fprint(h, id)
+	return Avatar{
+		Initials:   Initials(name),
+		Background: avatarPalette[h.Sum32()%uint32(len(avatarPalette))],
+	}
+}
+
+// Initials returns the upper-cased first letters of the first and last
+// words of name, or "?" when it has no letters
+func Initials(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	if len(words) == 0 {
+		return "?"
+	}
+
+	first := []rune(words[0])[0]
+	if len(words) == 1 {
+		return string(unicode.ToUpper(first))
+	}
+	last := []rune(words[len(words)-1])[0]
+	return string([]rune{unicode.ToUpper(first), unicode.ToUpper(last)})
+}
+
+var (
+	avatarFontOnce sync.Once
+	avatarFont     *opentype.Font
+	avatarFontErr  error
+)
+
+// PNG draws the avatar as a size x size PNG
+func (a Avatar) PNG(size int) ([]byte, error) {
+	avatarFontOnce.Do(func() {
+		avatarFont, avatarFontErr = opentype.Parse(goregular.TTF)
+	})
+	if avatarFontErr != nil {
+		return nil, avatarFontErr
+	}
+	face, err := opentype.NewFace(avatarFont, &opentype.FaceOptions{
+		Size:    float64(size) * 0.4,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(a.Background), image.Point{}, draw.Src)
+
+	// The bundled font only covers Latin, Greek and Cyrillic; other scripts get "?"
+	text := a.Initials
+	for _, r := range text {
+		if _, ok := face.GlyphAdvance(r); !ok {
+			text = "?"
+			break
+		}
+	}
+
+	// Centre the text horizontally and its capital letters vertically
+	d := &font.Drawer{Dst: dst, Src: image.White, Face: face}
+	width := d.MeasureString(text)
+	d.Dot = fixed.Point26_6{
+		X: (fixed.I(size) - width) / 2,
+		Y: (fixed.I(size) + face.Metrics().CapHeight) / 2,
+	}
+	d.DrawString(text)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the avatar as a size x size SVG document
+func (a Avatar) SVG(size int) []byte {
+	bg := fmt.Sprintf("#%02x%02x%02x", a.Background.R, a.Background.G, a.Background.B)
+	return fmt.Appendf(nil, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" viewBox="0 0 100 100">`+
+		`<rect width="100" height="100" fill="%[2]s"/>`+
+		`<text x="50" y="50" dy=".35em" fill="#ffffff" font-family="Helvetica, Arial, sans-serif" font-size="40" text-anchor="middle">%[3]s</text>`+
+		`</svg>`, size, bg, html.EscapeString(a.Initials))
+}
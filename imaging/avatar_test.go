@@ -0,0 +1,93 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestInitials(t *testing.T) {
+	for name, want := range map[string]string{
+		"Alice":               "A",
+		"alice cooper":        "AC",
+		"Jean-Luc de Picard":  "JP",
+		"  bob  ":             "B",
+		"élodie dubois":       "ÉD",
+		"o'neil":              "ON",
+		"":                    "?",
+		"!!!":                 "?",
+		"Putri Ayu Lestari 2": "P2",
+		"Дмитрий Иванов":      "ДИ",
+	} {
+		if got := Initials(name); got != want {
+			t.Errorf("Initials(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNewAvatarIsDeterministic(t *testing.T) {
+	a, b := NewAvatar("Alice", 1), NewAvatar("Alice", 1)
+	if a != b {
+		t.Errorf("same user gave %v and %v", a, b)
+	}
+
+	colours := map[color.RGBA]bool{}
+	for id := 1; id <= 50; id++ {
+		colours[NewAvatar("Alice", id).Background] = true
+	}
+	if len(colours) < 5 {
+		t.Errorf("50 users share %d colours", len(colours))
+	}
+}
+
+func TestAvatarPNG(t *testing.T) {
+	a := NewAvatar("Alice Cooper", 7)
+	for _, size := range []int{MinThumbnailSize, 100, MaxThumbnailSize} {
+		data, err := a.PNG(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Fatalf("size %d: got %v", size, b)
+		}
+		if got := color.RGBAModel.Convert(img.At(0, 0)); got != a.Background {
+			t.Errorf("size %d: corner is %v, want the background %v", size, got, a.Background)
+		}
+
+		// The initials cross the middle row
+		drawn := false
+		for x := 0; x < size; x++ {
+			if color.RGBAModel.Convert(img.At(x, size/2)) != a.Background {
+				drawn = true
+				break
+			}
+		}
+		if !drawn {
+			t.Errorf("size %d: no initials drawn", size)
+		}
+	}
+
+	// Scripts missing from the font still render
+	if _, err := NewAvatar("山田 太郎", 1).PNG(64); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAvatarSVGEscapes(t *testing.T) {
+	svg := Avatar{Initials: "<&", Background: color.RGBA{1, 2, 3, 255}}.SVG(64)
+	if err := xml.Unmarshal(svg, new(struct{})); err != nil {
+		t.Fatalf("not well-formed: %v\n%s", err, svg)
+	}
+	for _, want := range []string{`width="64"`, `fill="#010203"`, `&lt;&amp;`} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("missing %s in %s", want, svg)
+		}
+	}
+}
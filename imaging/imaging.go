@@ -0,0 +1,133 @@
+// Package imaging validates uploaded images and re-encodes them so only plain
+// pixel data reaches storage
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "golang.org/x/image/webp" // Register the WebP decoder with image.Decode
+)
+
+// Formats accepted for upload, named as registered with the image package
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// Error codes returned to clients when an upload is rejected
+const (
+	CodeTooLarge        = "file_too_large"
+	CodeUnsupportedType = "unsupported_type"
+	CodeDimensions      = "dimensions_too_large"
+	CodeInvalidImage    = "invalid_image"
+)
+
+// Error is a rejected upload with a machine-readable code
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Limits bounds what Process accepts
+type Limits struct {
+	MaxBytes     int64 // Size of the uploaded file
+	MaxDimension int   // Width and height in pixels
+}
+
+// Image is a validated, re-encoded upload
+type Image struct {
+	Data        []byte
+	Format      string // Format of Data, WebP uploads are stored as PNG
+	ContentType string
+	Ext         string // File extension including the dot
+	Width       int
+	Height      int
+	Pixels      image.Image // Decoded image, for deriving variants
+}
+
+// Sniff identifies an allowed image format from the leading magic bytes,
+// returning "" for anything else
+func Sniff(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	}
+	return ""
+}
+
+// Process reads an uploaded image, checks it against the limits and decodes
+// it; the pixels are then encoded again so EXIF/GPS metadata and any payload
+// hidden in the original file are dropped
+func Process(r io.Reader, limits Limits) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limits.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limits.MaxBytes {
+		return nil, &Error{CodeTooLarge, fmt.Sprintf("File must not exceed %d bytes", limits.MaxBytes)}
+	}
+
+	// Trust the content, never the client's file name or content type
+	format := Sniff(data)
+	if format == "" {
+		return nil, &Error{CodeUnsupportedType, "Only JPEG, PNG and WebP images are allowed"}
+	}
+
+	// Check the dimensions from the header before decoding so a small file
+	// cannot expand into a huge bitmap
+	cfg, decodedFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decodedFormat != format {
+		return nil, &Error{CodeInvalidImage, "The file is not a valid image"}
+	}
+	if cfg.Width > limits.MaxDimension || cfg.Height > limits.MaxDimension {
+		return nil, &Error{CodeDimensions, fmt.Sprintf("Image must not exceed %dx%d pixels", limits.MaxDimension, limits.MaxDimension)}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &Error{CodeInvalidImage, "The file is not a valid image"}
+	}
+
+	out := &Image{Width: cfg.Width, Height: cfg.Height, Pixels: img}
+	if out.Data, out.Format, err = Encode(img, format); err != nil {
+		return nil, err
+	}
+	out.ContentType, out.Ext = "image/"+out.Format, "."+out.Format
+	if out.Format == FormatJPEG {
+		out.Ext = ".jpg"
+	}
+	return out, nil
+}
+
+// Encode writes img in the given format; there is no WebP encoder, so WebP
+// images are encoded as lossless PNG. It returns the format actually used.
+func Encode(img image.Image, format string) ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+	switch format {
+	case FormatJPEG:
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, "", err
+		}
+	case FormatPNG, FormatWebP:
+		if err := png.Encode(buf, img); err != nil {
+			return nil, "", err
+		}
+		format = FormatPNG
+	default:
+		return nil, "", errors.New("imaging: unknown format " + format)
+	}
+	return buf.Bytes(), format, nil
+}
@@ -0,0 +1,139 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var testLimits = Limits{MaxBytes: 1 << 20, MaxDimension: 100}
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 200, A: 255})
+	}
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withEXIF inserts an APP1 Exif segment carrying payload right after the SOI marker
+func withEXIF(jpegData []byte, payload string) []byte {
+	segment := append([]byte("Exif\x00\x00"), payload...)
+	length := len(segment) + 2
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, 0xff, 0xe1, byte(length>>8), byte(length))
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"jpeg", []byte("\xff\xd8\xff\xe0rest"), FormatJPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\nrest"), FormatPNG},
+		{"webp", []byte("RIFF\x10\x00\x00\x00WEBPVP8 "), FormatWebP},
+		{"gif", []byte("GIF89a"), ""},
+		{"html", []byte("<html><script>"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Sniff(tt.data); got != tt.want {
+			t.Errorf("%s: Sniff = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessStripsMetadata(t *testing.T) {
+	data := withEXIF(encodeJPEG(t, 10, 10), "GPSLatitude=-6.2")
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("test image is not a valid JPEG: %v", err)
+	}
+
+	img, err := Process(bytes.NewReader(data), testLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != FormatJPEG || img.Ext != ".jpg" || img.ContentType != "image/jpeg" {
+		t.Errorf("got %s %s %s", img.Format, img.Ext, img.ContentType)
+	}
+	if img.Width != 10 || img.Height != 10 {
+		t.Errorf("dimensions = %dx%d", img.Width, img.Height)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("GPSLatitude")) {
+		t.Error("metadata survived re-encoding")
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	pngHeader := &bytes.Buffer{}
+	png.Encode(pngHeader, image.NewGray(image.Rect(0, 0, 4, 4)))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		limits Limits
+		code   string
+	}{
+		{"too many bytes", encodeJPEG(t, 10, 10), Limits{MaxBytes: 100, MaxDimension: 100}, CodeTooLarge},
+		{"unsupported type", []byte("GIF89a\x01\x00\x01\x00"), testLimits, CodeUnsupportedType},
+		{"script with image extension", []byte("<?php system($_GET['c']); ?>"), testLimits, CodeUnsupportedType},
+		{"too wide", encodeJPEG(t, 101, 1), testLimits, CodeDimensions},
+		{"truncated", pngHeader.Bytes()[:20], testLimits, CodeInvalidImage},
+		{"polyglot", append([]byte("\x89PNG\r\n\x1a\n"), "<script>alert(1)</script>"...), testLimits, CodeInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Process(bytes.NewReader(tt.data), tt.limits)
+			var imgErr *Error
+			if !errors.As(err, &imgErr) || imgErr.Code != tt.code {
+				t.Fatalf("err = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestEncodeWebPAsPNG(t *testing.T) {
+	data, format, err := Encode(image.NewRGBA(image.Rect(0, 0, 2, 2)), FormatWebP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != FormatPNG || Sniff(data) != FormatPNG {
+		t.Errorf("format = %s, sniffed %s", format, Sniff(data))
+	}
+}
+
+func TestThumbnailCropsCentre(t *testing.T) {
+	// A wide image whose centre square is blue with red bands either side
+	img := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 10; y++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 10 && x < 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	thumb := Thumbnail(img, 4)
+	if b := thumb.Bounds(); b.Dx() != 4 || b.Dy() != 4 {
+		t.Fatalf("bounds = %v", b)
+	}
+	for x := 0; x < 4; x++ {
+		if r, _, b, _ := thumb.At(x, 2).RGBA(); r > 0x1000 || b < 0xf000 {
+			t.Errorf("pixel %d is not blue: r=%x b=%x", x, r, b)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package imaging
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbnailSizes are the square variants generated for every upload
+var ThumbnailSizes = []int{64, 128, 512}
+
+// Bounds on sizes that can be requested and resized on demand
+const (
+	MinThumbnailSize = 16
+	MaxThumbnailSize = 1024
+)
+
+// Thumbnail crops the centre square of img and scales it to size x size
+func Thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
@@ -1,23 +1,104 @@
 package main
 
 import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/config"
+	"backend-nagaricare/controllers"
 	"backend-nagaricare/database"
+	"backend-nagaricare/migration"
+	"backend-nagaricare/notify"
+	"backend-nagaricare/repository"
 	routes "backend-nagaricare/routers"
+	"backend-nagaricare/search"
+	"backend-nagaricare/storage"
+	"context"
+	"flag"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
 func main() {
-	// Initialize Fiber app
-	app := fiber.New()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to an optional YAML config file")
+	autoMigrate := flag.Bool("migrate", false, "apply pending database migrations before starting the server")
+	flag.Parse()
+
+	// Load the configuration from the file and environment
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded configuration:\n%s", cfg.Redacted())
 
 	// Connect to the Database
-	database.ConnectDB()
+	database.ConnectDB(cfg.Database)
+
+	// "migrate up|down [steps]|status" manages the schema and exits
+	if flag.Arg(0) == "migrate" {
+		runMigrateCommand(flag.Args()[1:])
+		return
+	}
+	if *autoMigrate || cfg.Server.AutoMigrate {
+		migration.Migrate()
+	}
+
+	// Initialize Fiber app, leaving room in the body limit for multipart overhead
+	app := fiber.New(fiber.Config{
+		BodyLimit: int(max(cfg.Uploads.MaxBytes+1<<20, cfg.Attachments.MaxBytes+1<<20, fiber.DefaultBodyLimit)),
+	})
+
+	// Allow the configured browser origins
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		app.Use(cors.New(cors.Config{
+			AllowOrigins: strings.Join(cfg.CORS.AllowedOrigins, ","),
+			AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		}))
+	}
+
+	// Configure ID token verification and session tokens
+	google, tokens, err := auth.FromConfig(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Open the configured file storage
+	store, err := storage.FromConfig(cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Setup Routes backed by MySQL, searching through its FULLTEXT indexes
+	h := &controllers.Handler{
+		Users:            repository.NewMySQLUserRepository(database.DB),
+		Posts:            repository.NewMySQLPostRepository(database.DB),
+		Comments:         repository.NewMySQLCommentRepository(database.DB),
+		Attachments:      repository.NewMySQLAttachmentRepository(database.DB),
+		Audit:            repository.NewMySQLAuditRepository(database.DB),
+		Categories:       repository.NewMySQLCategoryRepository(database.DB),
+		Tags:             repository.NewMySQLTagRepository(database.DB),
+		SLAPolicies:      repository.NewMySQLSLAPolicyRepository(database.DB),
+		Tx:               repository.NewMySQLTransactor(database.DB),
+		Search:           search.NewMySQL(database.DB),
+		Google:           google,
+		Tokens:           tokens,
+		Uploads:          cfg.Uploads,
+		AttachmentLimits: cfg.Attachments,
+		Storage:          store,
+		Trash:            cfg.Trash,
+		SLA:              cfg.SLA,
+		Notify:           notify.FromConfig(cfg.Notify),
+	}
+	routes.SetupRoutes(app, h)
+
+	// Purge expired trash in the background
+	go h.RunPurge(context.Background())
 
-	// Setup Routes
-	routes.SetupRoutes(app)
+	// Flag missed SLA deadlines and notify agents and supervisors
+	go h.RunSLA(context.Background())
 
 	// Start the server
-	log.Fatal(app.Listen(":3000"))
+	log.Fatal(app.Listen(cfg.Server.ListenAddr))
 }
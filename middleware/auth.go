@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userKey is the fiber.Ctx locals key holding the authenticated *models.User
+const userKey = "currentUser"
+
+// Authenticator resolves the caller of a request from its session token
+type Authenticator struct {
+	Users  repository.UserRepository
+	Tokens *auth.TokenIssuer
+}
+
+// RequireAuth resolves the current user from the "Authorization: Bearer <token>"
+// header and rejects the request with 401 when it is missing or invalid
+func (a *Authenticator) RequireAuth(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing bearer token"})
+	}
+
+	userID, err := a.Tokens.ParseAccess(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+	}
+
+	// Load the user so handlers see the current role, not the one at sign-in time
+	user, err := a.Users.GetByID(c.UserContext(), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User no longer exists"})
+	} else if err != nil {
+		log.Println("Error loading authenticated user:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	c.Locals(userKey, user)
+	return c.Next()
+}
+
+// CurrentUser returns the user resolved by RequireAuth, or nil on public routes
+func CurrentUser(c *fiber.Ctx) *models.User {
+	user, _ := c.Locals(userKey).(*models.User)
+	return user
+}
+
+// RequirePermission returns a route guard rejecting callers whose role lacks
+// any of the given permissions; it must run after RequireAuth
+func RequirePermission(perms ...auth.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := CurrentUser(c)
+		if user == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authentication required"})
+		}
+
+		for _, perm := range perms {
+			if !auth.HasPermission(user.Role, perm) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Insufficient permissions"})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// Can reports whether the authenticated caller holds the given permission
+func Can(c *fiber.Ctx, perm auth.Permission) bool {
+	user := CurrentUser(c)
+	return user != nil && auth.HasPermission(user.Role, perm)
+}
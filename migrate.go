@@ -0,0 +1,63 @@
+package main
+
+import (
+	"backend-nagaricare/database"
+	"backend-nagaricare/migration"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// runMigrateCommand implements the "migrate" subcommand
+func runMigrateCommand(args []string) {
+	migrator, err := migration.New(database.DB)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		count, err := migrator.Up()
+		if err != nil {
+			log.Fatal("Failed to run migrations: ", err)
+		}
+		log.Printf("%d migration(s) applied", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal("Usage: migrate down [steps]")
+			}
+		}
+		count, err := migrator.Down(steps)
+		if err != nil {
+			log.Fatal("Failed to revert migrations: ", err)
+		}
+		log.Printf("%d migration(s) reverted", count)
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal("Failed to read migration status: ", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += " (MODIFIED since applied)"
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		log.Fatal("Usage: migrate up | down [steps] | status")
+	}
+}
@@ -2,28 +2,289 @@ package migration
 
 import (
 	"backend-nagaricare/database"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
 	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// Migrate runs the migration to create the forum_posts table
-func Migrate() {
-	db := database.DB
+// files holds the migration scripts, named <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of the up and down scripts, detects edits after release
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Modified  bool // The script changed since it was applied
+}
+
+// Migrator applies migrations and records them in the schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Load reads and orders the embedded migration scripts
+func Load() ([]Migration, error) {
+	return loadFS(files, "sql")
+}
+
+func loadFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.up.sql or .down.sql", name)
+		}
+		versionStr, migrationName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing name", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, found := byVersion[version]
+		if !found {
+			m = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = m
+		} else if m.Name != migrationName {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, migrationName)
+		}
+		if direction == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// New creates a migrator for the embedded migrations
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// ensureTable creates the schema_migrations bookkeeping table
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INT PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        checksum CHAR(64) NOT NULL,
+        applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`)
+	return err
+}
+
+// applied returns the recorded migrations keyed by version
+func (m *Migrator) applied() (map[int]Status, map[int]string, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.Query("SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := map[int]Status{}
+	checksums := map[int]string{}
+	for rows.Next() {
+		var s Status
+		var checksum, appliedAtStr string
+		if err := rows.Scan(&s.Version, &s.Name, &checksum, &appliedAtStr); err != nil {
+			return nil, nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		appliedAt, err := time.Parse("2006-01-02 15:04:05", appliedAtStr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse applied_at: %w", err)
+		}
+		s.AppliedAt = &appliedAt
+		statuses[s.Version] = s
+		checksums[s.Version] = checksum
+	}
+	return statuses, checksums, rows.Err()
+}
 
-	// SQL statement to create the forum_posts table
-	query := `
-    CREATE TABLE IF NOT EXISTS forum_posts (
-        id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-        title VARCHAR(255) NOT NULL,
-        content TEXT NOT NULL,
-        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-    );
-    `
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, checksums, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			s.AppliedAt = a.AppliedAt
+			s.Modified = checksums[mig.Version] != mig.Checksum
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order and returns how many ran.
+// It refuses to run when an applied migration has been edited or is unknown.
+func (m *Migrator) Up() (int, error) {
+	applied, checksums, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	known := map[int]bool{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		if checksum, ok := checksums[mig.Version]; ok && checksum != mig.Checksum {
+			return 0, fmt.Errorf("migration %d_%s was modified after being applied", mig.Version, mig.Name)
+		}
+	}
+	for version, s := range applied {
+		if !known[version] {
+			return 0, fmt.Errorf("database has migration %d_%s which this build does not know", version, s.Name)
+		}
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		if err := m.exec(mig.Up); err != nil {
+			return count, fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		_, err := m.db.Exec("INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)", mig.Version, mig.Name, mig.Checksum)
+		if err != nil {
+			return count, fmt.Errorf("record migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("Applied migration %d_%s", mig.Version, mig.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the given number of most recently applied migrations
+func (m *Migrator) Down(steps int) (int, error) {
+	applied, _, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+
+		if err := m.exec(mig.Down); err != nil {
+			return count, fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := m.db.Exec("DELETE FROM schema_migrations WHERE version = ?", mig.Version); err != nil {
+			return count, fmt.Errorf("unrecord migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("Reverted migration %d_%s", mig.Version, mig.Name)
+		count++
+	}
+	return count, nil
+}
+
+// exec runs each statement of a script; MySQL DDL is not transactional, so
+// statements are executed one by one
+func (m *Migrator) exec(script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := m.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script on semicolons ending a line
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
+
+// Migrate applies every pending migration to database.DB, used at startup
+func Migrate() {
+	migrator, err := New(database.DB)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
 
-	// Execute the migration
-	_, err := db.Exec(query)
+	count, err := migrator.Up()
 	if err != nil {
 		log.Fatal("Failed to run migration: ", err)
 	}
 
-	log.Println("Migration completed successfully")
+	log.Printf("Migration completed successfully, %d applied", count)
 }
@@ -0,0 +1,290 @@
+package migration
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// scripts builds a migration directory from file names and contents
+func scripts(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys["sql/"+name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+// mustLoad loads the migrations of files, failing the test on error
+func mustLoad(t *testing.T, files map[string]string) []Migration {
+	t.Helper()
+	migrations, err := loadFS(scripts(files), "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrations
+}
+
+func TestLoadFS(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		files    map[string]string
+		versions []string
+		err      string
+	}{
+		{
+			name: "ordered by version number",
+			files: map[string]string{
+				"0010_add_tags.up.sql":       "CREATE TABLE tags (id INT);",
+				"0010_add_tags.down.sql":     "DROP TABLE tags;",
+				"2_create_posts.up.sql":      "CREATE TABLE posts (id INT);",
+				"2_create_posts.down.sql":    "DROP TABLE posts;",
+				"0001_create_users.up.sql":   "CREATE TABLE users (id INT);",
+				"0001_create_users.down.sql": "DROP TABLE users;",
+			},
+			versions: []string{"1_create_users", "2_create_posts", "10_add_tags"},
+		},
+		{
+			name:     "underscores in the name",
+			files:    map[string]string{"0003_add_user_roles.up.sql": "ALTER TABLE users ADD role INT;", "0003_add_user_roles.down.sql": "ALTER TABLE users DROP role;"},
+			versions: []string{"3_add_user_roles"},
+		},
+		{
+			name:  "missing down script",
+			files: map[string]string{"0001_create_users.up.sql": "CREATE TABLE users (id INT);"},
+			err:   "needs both an up and a down script",
+		},
+		{
+			name:  "unknown direction",
+			files: map[string]string{"0001_create_users.sideways.sql": ""},
+			err:   "expected <version>_<name>.up.sql or .down.sql",
+		},
+		{
+			name:  "no direction",
+			files: map[string]string{"0001_create_users.sql": ""},
+			err:   "expected <version>_<name>.up.sql or .down.sql",
+		},
+		{
+			name:  "missing name",
+			files: map[string]string{"0001.up.sql": ""},
+			err:   "missing name",
+		},
+		{
+			name:  "invalid version",
+			files: map[string]string{"first_create_users.up.sql": ""},
+			err:   "invalid version",
+		},
+		{
+			name: "two names for one version",
+			files: map[string]string{
+				"0001_create_users.up.sql":    "CREATE TABLE users (id INT);",
+				"0001_create_people.down.sql": "DROP TABLE people;",
+			},
+			err: "migration 1 has two names",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := loadFS(scripts(tc.files), "sql")
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []string
+			for _, m := range migrations {
+				versions = append(versions, fmt.Sprintf("%d_%s", m.Version, m.Name))
+			}
+			if fmt.Sprint(versions) != fmt.Sprint(tc.versions) {
+				t.Errorf("versions = %v, want %v", versions, tc.versions)
+			}
+		})
+	}
+}
+
+func TestChecksumCoversBothScripts(t *testing.T) {
+	original := mustLoad(t, map[string]string{"0001_a.up.sql": "CREATE TABLE a (id INT);", "0001_a.down.sql": "DROP TABLE a;"})[0]
+
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		same  bool
+	}{
+		{name: "unchanged", files: map[string]string{"0001_a.up.sql": "CREATE TABLE a (id INT);", "0001_a.down.sql": "DROP TABLE a;"}, same: true},
+		{name: "edited up", files: map[string]string{"0001_a.up.sql": "CREATE TABLE a (id BIGINT);", "0001_a.down.sql": "DROP TABLE a;"}},
+		{name: "edited down", files: map[string]string{"0001_a.up.sql": "CREATE TABLE a (id INT);", "0001_a.down.sql": "DROP TABLE IF EXISTS a;"}},
+		// The separator keeps text moving between the scripts from going unnoticed
+		{name: "text moved between scripts", files: map[string]string{"0001_a.up.sql": "CREATE TABLE a (id INT);DROP", "0001_a.down.sql": " TABLE a;"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mustLoad(t, tc.files)[0]
+			if same := m.Checksum == original.Checksum; same != tc.same {
+				t.Errorf("checksum %s, original %s", m.Checksum, original.Checksum)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s found at position %d, versions must have no gaps", m.Version, m.Name, i+1)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{name: "empty", script: "", want: nil},
+		{name: "single", script: "DROP TABLE users;", want: []string{"DROP TABLE users"}},
+		{
+			name:   "several on their own lines",
+			script: "DROP TABLE comments;\nDROP TABLE posts;\n",
+			want:   []string{"DROP TABLE comments", "DROP TABLE posts"},
+		},
+		{
+			name:   "multi-line statement",
+			script: "CREATE TABLE users (\n    id INT,\n    email VARCHAR(255)\n);",
+			want:   []string{"CREATE TABLE users (\n    id INT,\n    email VARCHAR(255)\n)"},
+		},
+		{
+			name:   "comments and blank lines skipped",
+			script: "-- Users first\n\nCREATE TABLE users (id INT);\n  -- then posts\nCREATE TABLE posts (id INT);",
+			want:   []string{"CREATE TABLE users (id INT)", "CREATE TABLE posts (id INT)"},
+		},
+		{
+			name:   "semicolon inside a line",
+			script: "INSERT INTO settings VALUES ('a;b');",
+			want:   []string{"INSERT INTO settings VALUES ('a;b')"},
+		},
+		{
+			name:   "missing final semicolon",
+			script: "DROP TABLE comments;\nDROP TABLE posts",
+			want:   []string{"DROP TABLE comments", "DROP TABLE posts"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := splitStatements(tc.script)
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tc.want) {
+				t.Errorf("statements = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUp(t *testing.T) {
+	migrations := mustLoad(t, map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INT);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0002_create_posts.up.sql":   "CREATE TABLE posts (id INT);",
+		"0002_create_posts.down.sql": "DROP TABLE posts;",
+	})
+	recorded := func(version int, name, checksum string) []driver.Value {
+		return []driver.Value{int64(version), name, checksum, "2024-11-01 10:00:00"}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		applied [][]driver.Value
+		count   int
+		err     string
+		ran     []string
+	}{
+		{
+			name:  "fresh database",
+			count: 2,
+			ran:   []string{"CREATE TABLE users (id INT)", "CREATE TABLE posts (id INT)"},
+		},
+		{
+			name:    "one pending",
+			applied: [][]driver.Value{recorded(1, "create_users", migrations[0].Checksum)},
+			count:   1,
+			ran:     []string{"CREATE TABLE posts (id INT)"},
+		},
+		{
+			name:    "up to date",
+			applied: [][]driver.Value{recorded(1, "create_users", migrations[0].Checksum), recorded(2, "create_posts", migrations[1].Checksum)},
+		},
+		{
+			name:    "applied migration modified",
+			applied: [][]driver.Value{recorded(1, "create_users", strings.Repeat("0", 64))},
+			err:     "migration 1_create_users was modified after being applied",
+		},
+		{
+			name:    "applied migration unknown",
+			applied: [][]driver.Value{recorded(1, "create_users", migrations[0].Checksum), recorded(3, "create_tags", strings.Repeat("0", 64))},
+			err:     "database has migration 3_create_tags which this build does not know",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, func(query string, args []any) fakeAnswer {
+				if strings.HasPrefix(query, "SELECT version, name, checksum, applied_at FROM schema_migrations") {
+					return fakeAnswer{columns: []string{"version", "name", "checksum", "applied_at"}, rows: tc.applied}
+				}
+				return fakeAnswer{affected: 1}
+			})
+
+			count, err := (&Migrator{db: db, migrations: migrations}).Up()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if count != tc.count {
+				t.Errorf("count = %d, want %d", count, tc.count)
+			}
+
+			// Nothing may run once an applied migration is found edited
+			var ran []string
+			for _, statement := range fake.log() {
+				if strings.HasPrefix(statement.query, "CREATE TABLE") && !strings.HasPrefix(statement.query, "CREATE TABLE IF NOT EXISTS schema_migrations") {
+					ran = append(ran, statement.query)
+				}
+			}
+			if fmt.Sprint(ran) != fmt.Sprint(tc.ran) {
+				t.Errorf("ran %q, want %q", ran, tc.ran)
+			}
+		})
+	}
+}
+
+func TestStatusReportsModified(t *testing.T) {
+	migrations := mustLoad(t, map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INT);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0002_create_posts.up.sql":   "CREATE TABLE posts (id INT);",
+		"0002_create_posts.down.sql": "DROP TABLE posts;",
+	})
+	db, _ := newFakeDB(t, func(query string, args []any) fakeAnswer {
+		if strings.HasPrefix(query, "SELECT version") {
+			return fakeAnswer{
+				columns: []string{"version", "name", "checksum", "applied_at"},
+				rows:    [][]driver.Value{{int64(1), "create_users", strings.Repeat("0", 64), "2024-11-01 10:00:00"}},
+			}
+		}
+		return fakeAnswer{}
+	})
+
+	statuses, err := (&Migrator{db: db, migrations: migrations}).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].AppliedAt == nil || !statuses[0].Modified || statuses[1].AppliedAt != nil || statuses[1].Modified {
+		t.Errorf("statuses = %+v", statuses)
+	}
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS users (
+    id_user INT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) NULL,
+    profile_picture VARCHAR(255) NULL,
+    UNIQUE INDEX idx_users_email (email)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS posts (
+    id_posts INT AUTO_INCREMENT PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    id_user INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_posts_created (created_at, id_posts),
+    INDEX idx_posts_user (id_user, created_at),
+    FOREIGN KEY (id_user) REFERENCES users (id_user) ON DELETE CASCADE
+);
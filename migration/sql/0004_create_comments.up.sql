@@ -0,0 +1,14 @@
+CREATE TABLE comments (
+    id_comment INT AUTO_INCREMENT PRIMARY KEY,
+    id_post INT NOT NULL,
+    id_user INT NOT NULL,
+    parent_id INT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NULL,
+    INDEX idx_comments_post (id_post, parent_id, created_at),
+    FULLTEXT INDEX idx_comments_fulltext (content),
+    FOREIGN KEY (id_post) REFERENCES posts (id_posts) ON DELETE CASCADE,
+    FOREIGN KEY (id_user) REFERENCES users (id_user) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments (id_comment) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE posts ADD FULLTEXT INDEX idx_posts_fulltext (title, content);
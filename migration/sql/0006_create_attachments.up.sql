@@ -0,0 +1,14 @@
+CREATE TABLE attachments (
+    id_attachment INT AUTO_INCREMENT PRIMARY KEY,
+    id_post INT NOT NULL,
+    id_user INT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_attachments_key (storage_key),
+    INDEX idx_attachments_post (id_post, created_at),
+    FOREIGN KEY (id_post) REFERENCES posts (id_posts) ON DELETE CASCADE,
+    FOREIGN KEY (id_user) REFERENCES users (id_user) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP INDEX idx_users_deleted, DROP COLUMN deleted_at;
+ALTER TABLE posts DROP INDEX idx_posts_deleted, DROP COLUMN deleted_at;
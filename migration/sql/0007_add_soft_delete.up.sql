@@ -0,0 +1,2 @@
+ALTER TABLE posts ADD COLUMN deleted_at DATETIME NULL, ADD INDEX idx_posts_deleted (deleted_at);
+ALTER TABLE users ADD COLUMN deleted_at DATETIME NULL, ADD INDEX idx_users_deleted (deleted_at);
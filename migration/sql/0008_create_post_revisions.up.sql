@@ -0,0 +1,17 @@
+ALTER TABLE posts ADD COLUMN edited_at DATETIME NULL;
+
+CREATE TABLE post_revisions (
+    id_revision INT AUTO_INCREMENT PRIMARY KEY,
+    id_post INT NOT NULL,
+    revision INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    id_editor INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_post_revisions_number (id_post, revision),
+    FOREIGN KEY (id_post) REFERENCES posts (id_posts) ON DELETE CASCADE,
+    FOREIGN KEY (id_editor) REFERENCES users (id_user) ON DELETE SET NULL
+);
+
+INSERT INTO post_revisions (id_post, revision, title, content, id_editor, created_at)
+SELECT id_posts, 1, title, content, id_user, created_at FROM posts;
@@ -0,0 +1,21 @@
+CREATE TABLE audit_log (
+    id_audit BIGINT AUTO_INCREMENT PRIMARY KEY,
+    id_actor INT NULL,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) NOT NULL,
+    target_id INT NOT NULL,
+    before_json JSON NULL,
+    after_json JSON NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_audit_created (created_at, id_audit),
+    INDEX idx_audit_actor (id_actor, created_at),
+    INDEX idx_audit_target (target_type, target_id, created_at)
+);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
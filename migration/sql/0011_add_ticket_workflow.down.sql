@@ -0,0 +1,9 @@
+DROP TABLE post_status_changes;
+
+ALTER TABLE posts DROP FOREIGN KEY fk_posts_assignee;
+
+ALTER TABLE posts
+    DROP INDEX idx_posts_queue,
+    DROP COLUMN id_assignee,
+    DROP COLUMN status_changed_at,
+    DROP COLUMN status;
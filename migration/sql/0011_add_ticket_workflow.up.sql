@@ -0,0 +1,22 @@
+ALTER TABLE posts
+    ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'open',
+    ADD COLUMN status_changed_at DATETIME NULL,
+    ADD COLUMN id_assignee INT NULL,
+    ADD INDEX idx_posts_queue (id_assignee, status),
+    ADD CONSTRAINT fk_posts_assignee FOREIGN KEY (id_assignee) REFERENCES users (id_user) ON DELETE SET NULL;
+
+UPDATE posts SET status_changed_at = created_at;
+
+ALTER TABLE posts MODIFY status_changed_at DATETIME NOT NULL;
+
+CREATE TABLE post_status_changes (
+    id_status_change INT AUTO_INCREMENT PRIMARY KEY,
+    id_post INT NOT NULL,
+    from_status VARCHAR(32) NOT NULL,
+    to_status VARCHAR(32) NOT NULL,
+    id_actor INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_post_status_changes_post (id_post, id_status_change),
+    FOREIGN KEY (id_post) REFERENCES posts (id_posts) ON DELETE CASCADE,
+    FOREIGN KEY (id_actor) REFERENCES users (id_user) ON DELETE SET NULL
+);
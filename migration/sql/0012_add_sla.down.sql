@@ -0,0 +1,12 @@
+ALTER TABLE posts
+    DROP INDEX idx_posts_resolution_due,
+    DROP INDEX idx_posts_first_response_due,
+    DROP COLUMN resolution_breached_at,
+    DROP COLUMN resolved_at,
+    DROP COLUMN resolution_due,
+    DROP COLUMN first_response_breached_at,
+    DROP COLUMN first_response_at,
+    DROP COLUMN first_response_due,
+    DROP COLUMN priority;
+
+DROP TABLE sla_policies;
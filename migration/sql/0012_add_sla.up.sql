@@ -0,0 +1,35 @@
+CREATE TABLE sla_policies (
+    priority VARCHAR(16) PRIMARY KEY,
+    first_response_minutes INT NOT NULL,
+    resolution_minutes INT NOT NULL
+);
+
+INSERT INTO sla_policies (priority, first_response_minutes, resolution_minutes) VALUES
+    ('low', 1440, 10080),
+    ('normal', 480, 4320),
+    ('high', 120, 1440),
+    ('urgent', 30, 240);
+
+ALTER TABLE posts
+    ADD COLUMN priority VARCHAR(16) NOT NULL DEFAULT 'normal',
+    ADD COLUMN first_response_due DATETIME NULL,
+    ADD COLUMN first_response_at DATETIME NULL,
+    ADD COLUMN first_response_breached_at DATETIME NULL,
+    ADD COLUMN resolution_due DATETIME NULL,
+    ADD COLUMN resolved_at DATETIME NULL,
+    ADD COLUMN resolution_breached_at DATETIME NULL,
+    ADD INDEX idx_posts_first_response_due (first_response_due),
+    ADD INDEX idx_posts_resolution_due (resolution_due);
+
+UPDATE posts
+JOIN sla_policies ON sla_policies.priority = posts.priority
+SET posts.first_response_due = DATE_ADD(posts.created_at, INTERVAL sla_policies.first_response_minutes MINUTE),
+    posts.resolution_due = DATE_ADD(posts.created_at, INTERVAL sla_policies.resolution_minutes MINUTE);
+
+UPDATE posts SET resolved_at = status_changed_at WHERE status IN ('resolved', 'closed');
+
+UPDATE posts SET first_response_at = (
+    SELECT MIN(comments.created_at) FROM comments
+    JOIN users ON users.id_user = comments.id_user
+    WHERE comments.id_post = posts.id_posts AND comments.id_user <> posts.id_user AND users.role IN ('agent', 'moderator', 'admin')
+);
@@ -0,0 +1,9 @@
+DROP TABLE category_sla_policies;
+
+ALTER TABLE posts DROP FOREIGN KEY fk_posts_category;
+
+ALTER TABLE posts
+    DROP INDEX idx_posts_category,
+    DROP COLUMN id_category;
+
+DROP TABLE categories;
@@ -0,0 +1,33 @@
+CREATE TABLE categories (
+    id_category INT AUTO_INCREMENT PRIMARY KEY,
+    id_parent INT NULL,
+    slug VARCHAR(64) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    sort_order INT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_categories_slug (slug),
+    INDEX idx_categories_parent (id_parent, sort_order),
+    FOREIGN KEY (id_parent) REFERENCES categories (id_category)
+);
+
+-- Existing posts are filed under a general category admins can rename
+INSERT INTO categories (id_category, slug, name, description) VALUES
+    (1, 'general', 'General', 'Questions that fit no other topic');
+
+ALTER TABLE posts
+    ADD COLUMN id_category INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_posts_category (id_category, created_at),
+    ADD CONSTRAINT fk_posts_category FOREIGN KEY (id_category) REFERENCES categories (id_category);
+
+ALTER TABLE posts ALTER COLUMN id_category DROP DEFAULT;
+
+-- Targets of a priority within one category, overriding sla_policies
+CREATE TABLE category_sla_policies (
+    id_category INT NOT NULL,
+    priority VARCHAR(16) NOT NULL,
+    first_response_minutes INT NOT NULL,
+    resolution_minutes INT NOT NULL,
+    PRIMARY KEY (id_category, priority),
+    FOREIGN KEY (id_category) REFERENCES categories (id_category) ON DELETE CASCADE
+);
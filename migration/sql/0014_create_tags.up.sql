@@ -0,0 +1,15 @@
+CREATE TABLE tags (
+    id_tag INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_tags_name (name)
+);
+
+CREATE TABLE post_tags (
+    id_post INT NOT NULL,
+    id_tag INT NOT NULL,
+    PRIMARY KEY (id_post, id_tag),
+    INDEX idx_post_tags_tag (id_tag),
+    FOREIGN KEY (id_post) REFERENCES posts (id_posts) ON DELETE CASCADE,
+    FOREIGN KEY (id_tag) REFERENCES tags (id_tag) ON DELETE CASCADE
+);
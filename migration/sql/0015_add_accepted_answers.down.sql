@@ -0,0 +1,3 @@
+ALTER TABLE comments
+    DROP INDEX idx_comments_accepted,
+    DROP COLUMN accepted_at;
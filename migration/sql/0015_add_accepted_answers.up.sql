@@ -0,0 +1,5 @@
+-- The comment a post's author or an agent accepted as the answer; the
+-- application keeps at most one per post
+ALTER TABLE comments
+    ADD COLUMN accepted_at DATETIME NULL,
+    ADD INDEX idx_comments_accepted (id_post, accepted_at);
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Attachment is a file, such as a screenshot or a PDF, attached to a post
+type Attachment struct {
+	ID_attachment int       `json:"id_attachment"` // Primary key of the attachment
+	ID_post       int       `json:"id_post"`       // Post the file is attached to
+	ID_user       int       `json:"id_user"`       // User who uploaded the file
+	FileName      string    `json:"file_name"`     // Display name, derived from the uploaded name
+	ContentType   string    `json:"content_type"`  // Detected from the file's bytes
+	Size          int64     `json:"size"`
+	StorageKey    string    `json:"-"`             // Key of the file in storage
+	URL           string    `json:"url,omitempty"` // Signed download URL, filled when listing
+	CreatedAt     time.Time `json:"-"`
+}
+
+// MarshalJSON formats the CreatedAt field
+func (a *Attachment) MarshalJSON() ([]byte, error) {
+	type Alias Attachment
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAtStr string `json:"created_at"`
+	}{
+		Alias:        (*Alias)(a),
+		CreatedAtStr: a.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
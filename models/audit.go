@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited actions, named <target type>.<verb>
+const (
+	AuditPostCreate       = "post.create"
+	AuditPostUpdate       = "post.update"
+	AuditPostRollback     = "post.rollback"
+	AuditPostDelete       = "post.delete"
+	AuditPostRestore      = "post.restore"
+	AuditPostStatus       = "post.status_update"
+	AuditPostAssign       = "post.assign"
+	AuditPostCategory     = "post.category_update"
+	AuditPostTags         = "post.tags_update"
+	AuditPostAcceptAnswer = "post.accept_answer"
+	AuditPostPriority     = "post.priority_update"
+	AuditPostSLABreach    = "post.sla_breach"
+	AuditPostPurge        = "post.purge"
+	AuditCommentCreate    = "comment.create"
+	AuditCommentUpdate    = "comment.update"
+	AuditCommentDelete    = "comment.delete"
+	AuditAttachmentCreate = "attachment.create"
+	AuditAttachmentDelete = "attachment.delete"
+	AuditUserCreate       = "user.create"
+	AuditUserUpdate       = "user.update"
+	AuditUserRoleUpdate   = "user.role_update"
+	AuditUserPicture      = "user.picture_update"
+	AuditUserDelete       = "user.delete"
+	AuditUserRestore      = "user.restore"
+	AuditUserPurge        = "user.purge"
+	AuditCategoryCreate   = "category.create"
+	AuditCategoryUpdate   = "category.update"
+	AuditCategoryDelete   = "category.delete"
+	AuditTagMerge         = "tag.merge"
+	AuditSLAPolicyUpdate  = "sla_policy.update"
+	AuditSLAPolicyDelete  = "sla_policy.delete"
+)
+
+// Audited target types
+const (
+	AuditTargetPost       = "post"
+	AuditTargetComment    = "comment"
+	AuditTargetAttachment = "attachment"
+	AuditTargetUser       = "user"
+	AuditTargetCategory   = "category"
+	AuditTargetTag        = "tag"
+	AuditTargetSLAPolicy  = "sla_policy" // Identified by its category, 0 for the defaults
+)
+
+// AuditEntry records one change made through the API
+type AuditEntry struct {
+	ID_audit   int             `json:"id_audit"`
+	ID_actor   *int            `json:"id_actor"` // Null for sign-ups and the scheduled purge
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int             `json:"target_id"`
+	Before     json.RawMessage `json:"before"` // Target as JSON before the change, null when created
+	After      json.RawMessage `json:"after"`  // Target as JSON after the change, null when removed
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	CreatedAt  time.Time       `json:"-"`
+}
+
+// MarshalJSON formats the CreatedAt field
+func (a *AuditEntry) MarshalJSON() ([]byte, error) {
+	type Alias AuditEntry
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAtStr string `json:"created_at"`
+	}{
+		Alias:        (*Alias)(a),
+		CreatedAtStr: a.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
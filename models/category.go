@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+)
+
+// DefaultCategoryID is the category the migration files existing posts under
+const DefaultCategoryID = 1
+
+// slugPattern matches lowercase words joined by single hyphens
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidSlug reports whether s can name a category in URLs
+func ValidSlug(s string) bool {
+	return len(s) <= 64 && slugPattern.MatchString(s)
+}
+
+// Category is a topic posts are filed under; categories form a tree
+type Category struct {
+	ID_category int         `json:"id_category"`
+	ID_parent   *int        `json:"id_parent"` // Null for top-level categories
+	Slug        string      `json:"slug"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	SortOrder   int         `json:"sort_order"` // Position among its siblings, lowest first
+	PostCount   int         `json:"post_count"` // Posts filed directly under the category, outside the trash
+	CreatedAt   time.Time   `json:"-"`
+	Children    []*Category `json:"children,omitempty"`
+}
+
+// MarshalJSON formats the CreatedAt field and adds the posts of the subtree
+func (c *Category) MarshalJSON() ([]byte, error) {
+	type Alias Category
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAtStr   string `json:"created_at"`
+		TotalPostCount int    `json:"total_post_count"`
+	}{
+		Alias:          (*Alias)(c),
+		CreatedAtStr:   c.CreatedAt.Format("2006-01-02 15:04:05"),
+		TotalPostCount: c.TotalPostCount(),
+	})
+}
+
+// TotalPostCount counts the posts of the category and its descendants
+func (c *Category) TotalPostCount() int {
+	total := c.PostCount
+	for _, child := range c.Children {
+		total += child.TotalPostCount()
+	}
+	return total
+}
+
+// CategoryTree nests categories under their parents, keeping the order of
+// the list within each level
+func CategoryTree(categories []Category) []*Category {
+	nodes := make(map[int]*Category, len(categories))
+	for i := range categories {
+		category := categories[i]
+		category.Children = nil
+		nodes[category.ID_category] = &category
+	}
+
+	roots := []*Category{}
+	for i := range categories {
+		node := nodes[categories[i].ID_category]
+		if node.ID_parent != nil {
+			if parent, ok := nodes[*node.ID_parent]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Comment represents a reply to a post, or to another comment when ParentID is set
+type Comment struct {
+	ID_comment int        `json:"id_comment"` // Primary key of the comment
+	ID_post    int        `json:"id_post"`    // Post the comment belongs to
+	ID_user    int        `json:"id_user"`    // Author of the comment
+	ParentID   *int       `json:"parent_id"`  // Comment being replied to, nil for top-level comments
+	Content    string     `json:"content"`
+	CreatedAt  time.Time  `json:"-"`
+	UpdatedAt  *time.Time `json:"-"`
+	AcceptedAt *time.Time `json:"-"`                 // Set while the comment is the accepted answer of its post
+	Replies    []*Comment `json:"replies,omitempty"` // Only filled when listing as a tree
+}
+
+// MarshalJSON formats the CreatedAt, UpdatedAt and AcceptedAt fields
+func (c *Comment) MarshalJSON() ([]byte, error) {
+	type Alias Comment
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAtStr  string  `json:"created_at"`
+		UpdatedAtStr  *string `json:"updated_at"`
+		Accepted      bool    `json:"accepted"`
+		AcceptedAtStr *string `json:"accepted_at"`
+	}{
+		Alias:         (*Alias)(c),
+		CreatedAtStr:  c.CreatedAt.Format("2006-01-02 15:04:05"),
+		Accepted:      c.AcceptedAt != nil,
+		AcceptedAtStr: formatOptionalTime(c.AcceptedAt),
+		UpdatedAtStr: func() *string {
+			if c.UpdatedAt == nil {
+				return nil
+			}
+			formatted := c.UpdatedAt.Format("2006-01-02 15:04:05")
+			return &formatted
+		}(),
+	})
+}
@@ -7,20 +7,42 @@ import (
 
 // Post represents a post made by a user
 type Post struct {
-	ID_Posts     int       `json:"id_posts"` // Primary key of the post
-	Title        string    `json:"title"`    // Title of the forum post
-	Content      string    `json:"content"`  // Content of the forum post
-	ID_user      int       `json:"id_user"`
-	CreatedAt    time.Time `json:"-"`
-	CreatedAtStr string    `json:"created_at"` // Will hold the formatted date
+	ID_Posts     int        `json:"id_posts"` // Primary key of the post
+	Title        string     `json:"title"`    // Title of the forum post
+	Content      string     `json:"content"`  // Content of the forum post
+	ID_user      int        `json:"id_user"`
+	ID_category  int        `json:"category_id"`   // Topic the post is filed under
+	Tags         []string   `json:"tags"`          // Free-form labels, sorted, see NormalizeTags
+	CommentCount int        `json:"comment_count"` // Number of comments, including nested replies
+	Version      int        `json:"version"`       // Incremented by every edit, see PostRepository.Update
+	Status       string     `json:"status"`        // Ticket status, one of Statuses
+	ID_assignee  *int       `json:"id_assignee"`   // Agent working on the ticket, null when unassigned
+	Priority     string     `json:"priority"`      // One of Priorities, picks the SLA policy
+	SLA          SLA        `json:"sla"`
+	CreatedAt    time.Time  `json:"-"`
+	CreatedAtStr string     `json:"created_at"` // Will hold the formatted date
+	EditedAt     *time.Time `json:"-"`          // Time of the last edit, nil if never edited
+	DeletedAt    *time.Time `json:"-"`          // Set while the post is in the trash
+
+	StatusChangedAt time.Time `json:"-"` // Time the post entered its current status
+
+	// The comment accepted as the answer, see CommentRepository.SetAccepted
+	ID_accepted    *int     `json:"id_accepted_comment"`       // Null until solved
+	Solved         bool     `json:"solved"`                    // Set together with ID_accepted
+	AcceptedAnswer *Comment `json:"accepted_answer,omitempty"` // Only filled when getting a single post
 }
 
-// MarshalJSON formats the CreatedAt field
+// MarshalJSON formats the CreatedAt, EditedAt, StatusChangedAt and DeletedAt fields
 func (p *Post) MarshalJSON() ([]byte, error) {
 	type Alias Post
 	return json.Marshal(&struct {
 		*Alias
-		CreatedAtStr string `json:"created_at"`
+		CreatedAtStr string  `json:"created_at"`
+		EditedAtStr  *string `json:"edited_at"`
+		IsEdited     bool    `json:"is_edited"`
+		DeletedAtStr *string `json:"deleted_at,omitempty"`
+
+		StatusChangedAtStr string `json:"status_changed_at"`
 	}{
 		Alias: (*Alias)(p),
 		CreatedAtStr: func() string {
@@ -29,5 +51,24 @@ func (p *Post) MarshalJSON() ([]byte, error) {
 			}
 			return p.CreatedAt.Format("2006-01-02 15:04:05")
 		}(),
+		EditedAtStr:  formatOptionalTime(p.EditedAt),
+		IsEdited:     p.EditedAt != nil,
+		DeletedAtStr: formatOptionalTime(p.DeletedAt),
+
+		StatusChangedAtStr: func() string {
+			if p.StatusChangedAt.IsZero() {
+				return ""
+			}
+			return p.StatusChangedAt.Format("2006-01-02 15:04:05")
+		}(),
 	})
 }
+
+// formatOptionalTime formats a nullable timestamp like the other dates in responses
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.Format("2006-01-02 15:04:05")
+	return &formatted
+}
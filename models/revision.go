@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PostRevision is the title and content of a post as saved by one edit;
+// revision 1 is the post as first written
+type PostRevision struct {
+	ID_post   int       `json:"id_post"`
+	Revision  int       `json:"revision"` // Numbered from 1 per post
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	ID_editor *int      `json:"id_editor"` // Null once the editor's account is purged
+	CreatedAt time.Time `json:"-"`
+}
+
+// MarshalJSON formats the CreatedAt field
+func (r *PostRevision) MarshalJSON() ([]byte, error) {
+	type Alias PostRevision
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAtStr string `json:"created_at"`
+	}{
+		Alias:        (*Alias)(r),
+		CreatedAtStr: r.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
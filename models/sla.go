@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Ticket priorities, from least to most pressing
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// Priorities lists every valid priority, from least to most pressing
+var Priorities = []string{PriorityLow, PriorityNormal, PriorityHigh, PriorityUrgent}
+
+// SLA deadlines a ticket can breach
+const (
+	SLAFirstResponse = "first_response"
+	SLAResolution    = "resolution"
+)
+
+// SLAPolicy sets how quickly tickets of a priority must be answered and
+// resolved, in every category or only in one
+type SLAPolicy struct {
+	ID_category          *int   `json:"category_id"` // Null for the default of the priority
+	Priority             string `json:"priority"`
+	FirstResponseMinutes int    `json:"first_response_minutes"`
+	ResolutionMinutes    int    `json:"resolution_minutes"`
+}
+
+// Deadlines returns the first response and resolution deadlines of a ticket
+// opened at the given time
+func (p SLAPolicy) Deadlines(opened time.Time) (firstResponse, resolution time.Time) {
+	return opened.Add(time.Duration(p.FirstResponseMinutes) * time.Minute), opened.Add(time.Duration(p.ResolutionMinutes) * time.Minute)
+}
+
+// SLA tracks the deadlines of a ticket; a nil deadline means no policy applied
+type SLA struct {
+	FirstResponseDue        *time.Time // Staff must comment by then
+	FirstResponseAt         *time.Time // First comment by staff other than the author
+	FirstResponseBreachedAt *time.Time // When the breach was flagged
+	ResolutionDue           *time.Time // The ticket must be resolved by then
+	ResolvedAt              *time.Time // First move to resolved or closed, cleared on reopening
+	ResolutionBreachedAt    *time.Time // When the breach was flagged
+}
+
+// MarshalJSON formats the timestamps like the other dates in responses
+func (s *SLA) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		FirstResponseDue        *string `json:"first_response_due"`
+		FirstResponseAt         *string `json:"first_response_at"`
+		FirstResponseBreachedAt *string `json:"first_response_breached_at"`
+		ResolutionDue           *string `json:"resolution_due"`
+		ResolvedAt              *string `json:"resolved_at"`
+		ResolutionBreachedAt    *string `json:"resolution_breached_at"`
+	}{
+		FirstResponseDue:        formatOptionalTime(s.FirstResponseDue),
+		FirstResponseAt:         formatOptionalTime(s.FirstResponseAt),
+		FirstResponseBreachedAt: formatOptionalTime(s.FirstResponseBreachedAt),
+		ResolutionDue:           formatOptionalTime(s.ResolutionDue),
+		ResolvedAt:              formatOptionalTime(s.ResolvedAt),
+		ResolutionBreachedAt:    formatOptionalTime(s.ResolutionBreachedAt),
+	})
+}
+
+// SLABreach is a deadline a ticket has missed
+type SLABreach struct {
+	ID_post     int       `json:"id_post"`
+	Kind        string    `json:"kind"` // SLAFirstResponse or SLAResolution
+	Due         time.Time `json:"due"`
+	ID_assignee *int      `json:"id_assignee"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxTagsPerPost caps the tags of one post
+	MaxTagsPerPost = 5
+	// maxTagLength is the size of tags.name
+	maxTagLength = 32
+)
+
+// Tag is a free-form label on posts, such as "transfer-failed"
+type Tag struct {
+	ID_tag    int    `json:"id_tag"`
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"` // Posts outside the trash carrying the tag
+}
+
+// NormalizeTag lowercases and trims a tag, reporting whether the result is
+// lowercase words joined by single hyphens
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return tag, len(tag) <= maxTagLength && slugPattern.MatchString(tag)
+}
+
+// NormalizeTags normalizes the tags of a post, dropping duplicates and
+// sorting them by name
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		name, ok := NormalizeTag(tag)
+		if !ok {
+			return nil, fmt.Errorf("tag %q must be letters, digits and single hyphens, at most %d characters", tag, maxTagLength)
+		}
+		if !slices.Contains(normalized, name) {
+			normalized = append(normalized, name)
+		}
+	}
+	if len(normalized) > MaxTagsPerPost {
+		return nil, fmt.Errorf("a post can have at most %d tags", MaxTagsPerPost)
+	}
+	slices.Sort(normalized)
+	return normalized, nil
+}
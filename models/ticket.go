@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Ticket statuses of a post, in the order a support request usually goes through them
+const (
+	StatusOpen             = "open"
+	StatusInProgress       = "in_progress"
+	StatusAwaitingCustomer = "awaiting_customer"
+	StatusResolved         = "resolved"
+	StatusClosed           = "closed"
+)
+
+// Statuses lists every valid ticket status
+var Statuses = []string{StatusOpen, StatusInProgress, StatusAwaitingCustomer, StatusResolved, StatusClosed}
+
+// ActiveStatuses are the statuses of tickets still waiting for work
+var ActiveStatuses = []string{StatusOpen, StatusInProgress, StatusAwaitingCustomer}
+
+// statusTransitions lists the statuses a ticket may move to from each status
+var statusTransitions = map[string][]string{
+	StatusOpen:             {StatusInProgress, StatusAwaitingCustomer, StatusResolved, StatusClosed},
+	StatusInProgress:       {StatusOpen, StatusAwaitingCustomer, StatusResolved, StatusClosed},
+	StatusAwaitingCustomer: {StatusInProgress, StatusResolved, StatusClosed},
+	StatusResolved:         {StatusOpen, StatusClosed},
+	StatusClosed:           {StatusOpen},
+}
+
+// NextStatuses returns the statuses a ticket may move to from status
+func NextStatuses(status string) []string {
+	return statusTransitions[status]
+}
+
+// Resolved reports whether a status ends the work on a ticket
+func Resolved(status string) bool {
+	return status == StatusResolved || status == StatusClosed
+}
+
+// CanTransition reports whether a ticket may move from one status to another
+func CanTransition(from, to string) bool {
+	return slices.Contains(statusTransitions[from], to)
+}
+
+// StatusChange records one move of a ticket between statuses
+type StatusChange struct {
+	ID_post    int       `json:"id_post"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ID_actor   *int      `json:"id_actor"` // Null once the actor's account is purged
+	CreatedAt  time.Time `json:"-"`
+}
+
+// MarshalJSON formats the CreatedAt field
+func (s *StatusChange) MarshalJSON() ([]byte, error) {
+	type Alias StatusChange
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAtStr string `json:"created_at"`
+	}{
+		Alias:        (*Alias)(s),
+		CreatedAtStr: s.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
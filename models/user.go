@@ -1,6 +1,20 @@
 package models
 
-// import "database/sql"
+import (
+	"encoding/json"
+	"time"
+)
+
+// Roles a user can have; staff roles answer and moderate the forum
+const (
+	RoleCustomer  = "customer"
+	RoleAgent     = "agent"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every valid role, from least to most privileged
+var Roles = []string{RoleCustomer, RoleAgent, RoleModerator, RoleAdmin}
 
 type User struct {
 	ID_user int     `json:"id_user"`
@@ -8,4 +22,32 @@ type User struct {
 	Name    string  `json:"name"`
 	Phone   *string `json:"phone"`
 	Picture *string `json:"profile_picture"`
+	Role    string  `json:"role"`
+	Version int     `json:"version"` // Incremented by every change, see UserRepository.Update
+
+	DeletedAt *time.Time `json:"-"` // Set while the account is in the trash
+}
+
+// PublicUser is what any signed-in user may see of another user
+type PublicUser struct {
+	ID_user int     `json:"id_user"`
+	Name    string  `json:"name"`
+	Picture *string `json:"profile_picture"`
+}
+
+// Public returns the user without contact details or role
+func (u *User) Public() PublicUser {
+	return PublicUser{ID_user: u.ID_user, Name: u.Name, Picture: u.Picture}
+}
+
+// MarshalJSON adds the deletion time of trashed accounts
+func (u *User) MarshalJSON() ([]byte, error) {
+	type Alias User
+	return json.Marshal(&struct {
+		*Alias
+		DeletedAtStr *string `json:"deleted_at,omitempty"`
+	}{
+		Alias:        (*Alias)(u),
+		DeletedAtStr: formatOptionalTime(u.DeletedAt),
+	})
 }
@@ -0,0 +1,77 @@
+// Package notify delivers notifications to users, through a webhook or the
+// server log
+package notify
+
+import (
+	"backend-nagaricare/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Message is a notification for one user
+type Message struct {
+	ID_user int    `json:"id_user"`
+	Email   string `json:"email"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Notifier sends notifications
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Log writes notifications to the server log, for setups without a webhook
+type Log struct{}
+
+func (Log) Notify(ctx context.Context, msg Message) error {
+	log.Printf("Notification for user %d <%s>: %s", msg.ID_user, msg.Email, msg.Subject)
+	return nil
+}
+
+// Webhook posts each notification as JSON to a URL, such as a mail or chat
+// relay
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook creates a webhook notifier with a bounded request timeout
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *Webhook) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notify webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// FromConfig creates the configured notifier
+func FromConfig(cfg config.NotifyConfig) Notifier {
+	if cfg.WebhookURL == "" {
+		return Log{}
+	}
+	return NewWebhook(cfg.WebhookURL)
+}
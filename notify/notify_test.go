@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"backend-nagaricare/config"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookPostsMessage(t *testing.T) {
+	var got Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	msg := Message{ID_user: 5, Email: "agent@example.com", Subject: "Ticket #1 missed its deadline", Body: "details"}
+	if err := NewWebhook(srv.URL).Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("webhook received %+v, want %+v", got, msg)
+	}
+}
+
+func TestWebhookRejectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook(srv.URL).Notify(context.Background(), Message{ID_user: 1}); err == nil {
+		t.Fatal("expected an error for a 502 response")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if _, ok := FromConfig(config.NotifyConfig{}).(Log); !ok {
+		t.Error("expected the log notifier without a webhook URL")
+	}
+	if w, ok := FromConfig(config.NotifyConfig{WebhookURL: "https://hooks.example.com"}).(*Webhook); !ok || w.URL != "https://hooks.example.com" {
+		t.Error("expected a webhook notifier for the configured URL")
+	}
+}
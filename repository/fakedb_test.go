@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is a database/sql connector answering every statement with a
+// function, so the MySQL repositories can be tested without a server
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []fakeStatement
+	answer     func(query string, args []any) fakeAnswer
+}
+
+// fakeStatement is a statement run against a fakeDB, with its whitespace
+// collapsed; transactions show up as BEGIN, COMMIT and ROLLBACK
+type fakeStatement struct {
+	query string
+	args  []any
+}
+
+// fakeAnswer is the outcome of a statement: rows for queries, the number of
+// affected rows for the others
+type fakeAnswer struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+func newFakeDB(t *testing.T, answer func(query string, args []any) fakeAnswer) (*sql.DB, *fakeDB) {
+	f := &fakeDB{answer: answer}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+// log returns the statements run so far
+func (f *fakeDB) log() []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeStatement{}, f.statements...)
+}
+
+func (f *fakeDB) run(query string, named []driver.NamedValue) fakeAnswer {
+	query = strings.Join(strings.Fields(query), " ")
+	args := make([]any, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+
+	f.mu.Lock()
+	f.statements = append(f.statements, fakeStatement{query: query, args: args})
+	f.mu.Unlock()
+	if query == "BEGIN" || query == "COMMIT" || query == "ROLLBACK" {
+		return fakeAnswer{}
+	}
+	return f.answer(query, args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake: open through the connector")
+}
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake: prepared statements are not supported")
+}
+
+func (c fakeConn) Close() error { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	c.f.run("BEGIN", nil)
+	return c, nil
+}
+
+func (c fakeConn) Commit() error {
+	c.f.run("COMMIT", nil)
+	return nil
+}
+
+func (c fakeConn) Rollback() error {
+	c.f.run("ROLLBACK", nil)
+	return nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	answer := c.f.run(query, args)
+	if answer.err != nil {
+		return nil, answer.err
+	}
+	return driver.RowsAffected(answer.affected), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	answer := c.f.run(query, args)
+	if answer.err != nil {
+		return nil, answer.err
+	}
+	return &fakeRows{columns: answer.columns, rows: answer.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,1441 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"backend-nagaricare/search"
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps users, posts, revisions, status changes, comments,
+// attachments, categories, tags, SLA policies and the audit log in process
+// memory.
+// It backs the HTTP API in tests and local runs without a MySQL server, and
+// mirrors the MySQL behaviour including cascading deletes.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	users       map[int]models.User
+	posts       map[int]models.Post
+	revisions   map[int][]models.PostRevision // Revisions per post, oldest first
+	statuses    map[int][]models.StatusChange // Status changes per post, oldest first
+	comments    map[int]models.Comment
+	attachments map[int]models.Attachment
+	categories  map[int]models.Category
+	tags        map[int]string                    // Tag names by ID
+	postTags    map[int][]int                     // Tag IDs per post
+	slaPolicies map[slaPolicyKey]models.SLAPolicy // Category 0 holds the defaults
+	audit       []models.AuditEntry
+	lastID      map[string]int // Auto-increment counter per table
+
+	// Now returns the current time; tests may replace it to control ordering
+	Now func() time.Time
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       map[int]models.User{},
+		posts:       map[int]models.Post{},
+		revisions:   map[int][]models.PostRevision{},
+		statuses:    map[int][]models.StatusChange{},
+		comments:    map[int]models.Comment{},
+		attachments: map[int]models.Attachment{},
+		categories:  map[int]models.Category{defaultCategory.ID_category: defaultCategory},
+		tags:        map[int]string{},
+		postTags:    map[int][]int{},
+		slaPolicies: maps.Clone(defaultSLAPolicies),
+		lastID:      map[string]int{"categories": defaultCategory.ID_category},
+		Now:         func() time.Time { return time.Now().UTC().Truncate(time.Second) },
+	}
+}
+
+// slaPolicyKey names the policy of a priority in a category
+type slaPolicyKey struct {
+	categoryID int
+	priority   string
+}
+
+// defaultSLAPolicies are the policies seeded by the SLA migration
+var defaultSLAPolicies = map[slaPolicyKey]models.SLAPolicy{
+	{0, models.PriorityLow}:    {Priority: models.PriorityLow, FirstResponseMinutes: 1440, ResolutionMinutes: 10080},
+	{0, models.PriorityNormal}: {Priority: models.PriorityNormal, FirstResponseMinutes: 480, ResolutionMinutes: 4320},
+	{0, models.PriorityHigh}:   {Priority: models.PriorityHigh, FirstResponseMinutes: 120, ResolutionMinutes: 1440},
+	{0, models.PriorityUrgent}: {Priority: models.PriorityUrgent, FirstResponseMinutes: 30, ResolutionMinutes: 240},
+}
+
+// defaultCategory is the category seeded by the categories migration
+var defaultCategory = models.Category{
+	ID_category: models.DefaultCategoryID,
+	Slug:        "general",
+	Name:        "General",
+	Description: "Questions that fit no other topic",
+	CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// nextID returns the next auto-increment value for a table; the caller must hold s.mu
+func (s *MemoryStore) nextID(table string) int {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// liveUser returns the user unless it is missing or trashed; the caller must hold s.mu
+func (s *MemoryStore) liveUser(id int) (models.User, bool) {
+	user, ok := s.users[id]
+	return user, ok && user.DeletedAt == nil
+}
+
+// livePost returns the post unless it is missing or trashed; the caller must hold s.mu
+func (s *MemoryStore) livePost(id int) (models.Post, bool) {
+	post, ok := s.posts[id]
+	return post, ok && post.DeletedAt == nil
+}
+
+// acceptedAnswer returns the comment accepted as the answer of the post; the
+// caller must hold s.mu
+func (s *MemoryStore) acceptedAnswer(postID int) (int, bool) {
+	for id, comment := range s.comments {
+		if comment.ID_post == postID && comment.AcceptedAt != nil {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// deleteComment removes a comment and, transitively, its replies; the caller must hold s.mu
+func (s *MemoryStore) deleteComment(commentID int) {
+	doomed := []int{commentID}
+	for len(doomed) > 0 {
+		id := doomed[0]
+		doomed = doomed[1:]
+		delete(s.comments, id)
+		for replyID, reply := range s.comments {
+			if reply.ParentID != nil && *reply.ParentID == id {
+				doomed = append(doomed, replyID)
+			}
+		}
+	}
+}
+
+// purgePost removes a post with its revisions, status changes, comments and
+// attachments; the caller must hold s.mu
+func (s *MemoryStore) purgePost(id int) {
+	delete(s.posts, id)
+	delete(s.revisions, id)
+	delete(s.statuses, id)
+	delete(s.postTags, id)
+	for commentID, comment := range s.comments {
+		if comment.ID_post == id {
+			delete(s.comments, commentID)
+		}
+	}
+	for attachmentID, attachment := range s.attachments {
+		if attachment.ID_post == id {
+			delete(s.attachments, attachmentID)
+		}
+	}
+}
+
+// Users returns the store's user repository
+func (s *MemoryStore) Users() UserRepository { return memoryUsers{s} }
+
+// Posts returns the store's post repository
+func (s *MemoryStore) Posts() PostRepository { return memoryPosts{s} }
+
+// Comments returns the store's comment repository
+func (s *MemoryStore) Comments() CommentRepository { return memoryComments{s} }
+
+// Attachments returns the store's attachment repository
+func (s *MemoryStore) Attachments() AttachmentRepository { return memoryAttachments{s} }
+
+// Categories returns the store's category repository
+func (s *MemoryStore) Categories() CategoryRepository { return memoryCategories{s} }
+
+// Tags returns the store's tag repository
+func (s *MemoryStore) Tags() TagRepository { return memoryTags{s} }
+
+// SLAPolicies returns the store's SLA policy repository
+func (s *MemoryStore) SLAPolicies() SLAPolicyRepository { return memorySLAPolicies{s} }
+
+// Audit returns the store's audit log
+func (s *MemoryStore) Audit() AuditRepository { return memoryAudit{s} }
+
+// WithinTx implements Transactor by restoring a snapshot of the store when fn
+// fails. Unlike MySQL it does not isolate fn from concurrent writers.
+func (s *MemoryStore) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.mu.RLock()
+	snapshot := s.snapshot()
+	s.mu.RUnlock()
+
+	if err := fn(ctx); err != nil {
+		s.mu.Lock()
+		s.users, s.posts, s.revisions, s.statuses = snapshot.users, snapshot.posts, snapshot.revisions, snapshot.statuses
+		s.comments, s.attachments = snapshot.comments, snapshot.attachments
+		s.categories, s.tags, s.postTags, s.slaPolicies = snapshot.categories, snapshot.tags, snapshot.postTags, snapshot.slaPolicies
+		s.audit, s.lastID = snapshot.audit, snapshot.lastID
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// snapshot copies the tables; the caller must hold s.mu
+func (s *MemoryStore) snapshot() *MemoryStore {
+	revisions := make(map[int][]models.PostRevision, len(s.revisions))
+	for postID, list := range s.revisions {
+		revisions[postID] = append([]models.PostRevision{}, list...)
+	}
+	statuses := make(map[int][]models.StatusChange, len(s.statuses))
+	for postID, list := range s.statuses {
+		statuses[postID] = append([]models.StatusChange{}, list...)
+	}
+	postTags := make(map[int][]int, len(s.postTags))
+	for postID, list := range s.postTags {
+		postTags[postID] = slices.Clone(list)
+	}
+	return &MemoryStore{
+		users:       maps.Clone(s.users),
+		posts:       maps.Clone(s.posts),
+		revisions:   revisions,
+		statuses:    statuses,
+		comments:    maps.Clone(s.comments),
+		attachments: maps.Clone(s.attachments),
+		categories:  maps.Clone(s.categories),
+		tags:        maps.Clone(s.tags),
+		postTags:    postTags,
+		slaPolicies: maps.Clone(s.slaPolicies),
+		audit:       append([]models.AuditEntry{}, s.audit...),
+		lastID:      maps.Clone(s.lastID),
+	}
+}
+
+// searchDocument describes a post to the search index; the caller must hold s.mu
+func (s *MemoryStore) searchDocument(post models.Post) search.Document {
+	_, solved := s.acceptedAnswer(post.ID_Posts)
+	return search.Document{
+		PostID:     post.ID_Posts,
+		UserID:     post.ID_user,
+		Title:      post.Title,
+		Content:    post.Content,
+		CreatedAt:  post.CreatedAt,
+		Solved:     solved,
+		Status:     post.Status,
+		AssigneeID: post.ID_assignee,
+		Categories: s.categoryPath(post.ID_category),
+		Tags:       s.tagNames(post.ID_Posts),
+	}
+}
+
+// Search implements search.Searcher by indexing the current posts and comments
+func (s *MemoryStore) Search(ctx context.Context, q search.Query) (*search.Result, error) {
+	s.mu.RLock()
+	index := search.NewMemoryIndex()
+	for _, post := range s.posts {
+		if post.DeletedAt != nil {
+			continue
+		}
+		index.Index(s.searchDocument(post))
+	}
+	for _, comment := range s.comments {
+		post, ok := s.livePost(comment.ID_post)
+		if !ok {
+			continue
+		}
+		id := comment.ID_comment
+		doc := s.searchDocument(post)
+		doc.CommentID, doc.UserID, doc.Title, doc.Content, doc.CreatedAt = &id, comment.ID_user, "", comment.Content, comment.CreatedAt
+		index.Index(doc)
+	}
+	s.mu.RUnlock()
+
+	return index.Search(ctx, q)
+}
+
+type memoryUsers struct{ s *MemoryStore }
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.users {
+		if existing.Email == user.Email {
+			return ErrConflict
+		}
+	}
+	if user.ID_user == 0 {
+		user.ID_user = r.s.nextID("users")
+	} else if _, ok := r.s.users[user.ID_user]; ok {
+		return ErrConflict
+	} else if user.ID_user > r.s.lastID["users"] {
+		r.s.lastID["users"] = user.ID_user
+	}
+
+	user.Version = 1
+	r.s.users[user.ID_user] = *user
+	return nil
+}
+
+func (r memoryUsers) List(ctx context.Context) ([]models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(r.s.users))
+	for _, user := range r.s.users {
+		if user.DeletedAt == nil {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID_user < users[j].ID_user })
+	return users, nil
+}
+
+func (r memoryUsers) GetByID(ctx context.Context, id int) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, ok := r.s.liveUser(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r memoryUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, user := range r.s.users {
+		if user.Email == email && user.DeletedAt == nil {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) Update(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.liveUser(user.ID_user)
+	if !ok {
+		return ErrNotFound
+	}
+	for id, existing := range r.s.users {
+		if id != user.ID_user && existing.Email == user.Email {
+			return ErrConflict
+		}
+	}
+	if stored.Version != user.Version {
+		return ErrStale
+	}
+
+	stored.Email, stored.Name, stored.Phone = user.Email, user.Name, user.Phone
+	stored.Version++
+	r.s.users[user.ID_user] = stored
+	user.Version = stored.Version
+	return nil
+}
+
+func (r memoryUsers) UpdateRole(ctx context.Context, id int, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.liveUser(id)
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Role = role
+	stored.Version++
+	r.s.users[id] = stored
+	return nil
+}
+
+func (r memoryUsers) SetPicture(ctx context.Context, id int, picture *string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.liveUser(id)
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Picture = picture
+	stored.Version++
+	r.s.users[id] = stored
+	return nil
+}
+
+func (r memoryUsers) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.liveUser(id)
+	if !ok {
+		return ErrNotFound
+	}
+	now := r.s.Now()
+	stored.DeletedAt = &now
+	r.s.users[id] = stored
+	return nil
+}
+
+func (r memoryUsers) Restore(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok || stored.DeletedAt == nil {
+		return ErrNotFound
+	}
+	stored.DeletedAt = nil
+	r.s.users[id] = stored
+	return nil
+}
+
+func (r memoryUsers) ListDeleted(ctx context.Context) ([]models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	users := []models.User{}
+	for _, user := range r.s.users {
+		if user.DeletedAt != nil {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].DeletedAt.Equal(*users[j].DeletedAt) {
+			return users[i].DeletedAt.After(*users[j].DeletedAt)
+		}
+		return users[i].ID_user > users[j].ID_user
+	})
+	return users, nil
+}
+
+func (r memoryUsers) Purge(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if user, ok := r.s.users[id]; !ok || user.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	// Cascade like the id_user foreign keys
+	delete(r.s.users, id)
+	for postID, post := range r.s.posts {
+		if post.ID_user == id {
+			r.s.purgePost(postID)
+		}
+	}
+	for commentID, comment := range r.s.comments {
+		if comment.ID_user == id {
+			r.s.deleteComment(commentID)
+		}
+	}
+	for attachmentID, attachment := range r.s.attachments {
+		if attachment.ID_user == id {
+			delete(r.s.attachments, attachmentID)
+		}
+	}
+	for _, revisions := range r.s.revisions {
+		for i := range revisions {
+			if editor := revisions[i].ID_editor; editor != nil && *editor == id {
+				revisions[i].ID_editor = nil
+			}
+		}
+	}
+	for _, changes := range r.s.statuses {
+		for i := range changes {
+			if actor := changes[i].ID_actor; actor != nil && *actor == id {
+				changes[i].ID_actor = nil
+			}
+		}
+	}
+	for postID, post := range r.s.posts {
+		if post.ID_assignee != nil && *post.ID_assignee == id {
+			post.ID_assignee = nil
+			r.s.posts[postID] = post
+		}
+	}
+	return nil
+}
+
+type memoryPosts struct{ s *MemoryStore }
+
+// withDerived fills the comment count, tags and accepted answer of the post;
+// the caller must hold s.mu
+func (r memoryPosts) withDerived(post models.Post) models.Post {
+	post.CommentCount = 0
+	for _, comment := range r.s.comments {
+		if comment.ID_post == post.ID_Posts {
+			post.CommentCount++
+		}
+	}
+	post.Tags = r.s.tagNames(post.ID_Posts)
+	post.ID_accepted, post.Solved = nil, false
+	if id, ok := r.s.acceptedAnswer(post.ID_Posts); ok {
+		post.ID_accepted, post.Solved = &id, true
+	}
+	return post
+}
+
+func (r memoryPosts) Create(ctx context.Context, post *models.Post) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	post.ID_Posts = r.s.nextID("posts")
+	post.CreatedAt = r.s.Now()
+	post.Version = 1
+	post.Status, post.StatusChangedAt, post.Priority = models.StatusOpen, post.CreatedAt, models.PriorityNormal
+	r.s.posts[post.ID_Posts] = *post
+
+	author := post.ID_user
+	r.s.revisions[post.ID_Posts] = []models.PostRevision{
+		{ID_post: post.ID_Posts, Revision: 1, Title: post.Title, Content: post.Content, ID_editor: &author, CreatedAt: post.CreatedAt},
+	}
+	return nil
+}
+
+func (r memoryPosts) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	post, ok := r.s.livePost(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	post = r.withDerived(post)
+	return &post, nil
+}
+
+func (r memoryPosts) List(ctx context.Context, filter PostFilter) (*PostPage, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// Apply the filters
+	var matched []models.Post
+	for _, post := range r.s.posts {
+		if (post.DeletedAt != nil) != filter.Trash {
+			continue
+		}
+		if filter.UserID != 0 && post.ID_user != filter.UserID {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, post.Status) {
+			continue
+		}
+		if filter.AssigneeID != 0 && (post.ID_assignee == nil || *post.ID_assignee != filter.AssigneeID) {
+			continue
+		}
+		if filter.Unassigned && post.ID_assignee != nil {
+			continue
+		}
+		if filter.SLA != "" && !r.inSLAState(post, filter) {
+			continue
+		}
+		if (filter.CategoryID != 0 || filter.CategorySlug != "") && !r.s.inCategory(post.ID_category, filter) {
+			continue
+		}
+		if _, solved := r.s.acceptedAnswer(post.ID_Posts); filter.Solved != nil && solved != *filter.Solved {
+			continue
+		}
+		if filter.Tag != "" && !slices.ContainsFunc(r.s.postTags[post.ID_Posts], func(id int) bool { return r.s.tags[id] == filter.Tag }) {
+			continue
+		}
+		if filter.From != nil && post.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !post.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		matched = append(matched, r.withDerived(post))
+	}
+	total := len(matched)
+
+	// Sort with the same tie-breakers as the SQL queries
+	newer := func(a, b models.Post) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID_Posts > b.ID_Posts
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		switch filter.Sort {
+		case SortOldest:
+			return newer(b, a)
+		case SortMostCommented:
+			if a.CommentCount != b.CommentCount {
+				return a.CommentCount > b.CommentCount
+			}
+		}
+		return newer(a, b)
+	})
+
+	// Skip to the cursor or offset
+	start := min(filter.Offset, len(matched))
+	if filter.Cursor != nil {
+		cursor := models.Post{ID_Posts: filter.Cursor.ID, CreatedAt: filter.Cursor.CreatedAt}
+		start = len(matched)
+		for i, post := range matched {
+			if (filter.Sort == SortOldest && newer(post, cursor)) || (filter.Sort != SortOldest && newer(cursor, post)) {
+				start = i
+				break
+			}
+		}
+	}
+	end := min(start+filter.Limit, len(matched))
+
+	return &PostPage{
+		Posts:   append([]models.Post{}, matched[start:end]...),
+		Total:   total,
+		HasMore: end < len(matched),
+	}, nil
+}
+
+func (r memoryPosts) Update(ctx context.Context, post *models.Post, editorID int) (*models.PostRevision, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.livePost(post.ID_Posts)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if stored.Version != post.Version {
+		return nil, ErrStale
+	}
+	now := r.s.Now()
+	stored.Title, stored.Content, stored.EditedAt = post.Title, post.Content, &now
+	stored.Version++
+	r.s.posts[post.ID_Posts] = stored
+	post.EditedAt, post.Version = &now, stored.Version
+
+	revisions := r.s.revisions[post.ID_Posts]
+	revision := models.PostRevision{
+		ID_post:   post.ID_Posts,
+		Revision:  len(revisions) + 1,
+		Title:     post.Title,
+		Content:   post.Content,
+		ID_editor: &editorID,
+		CreatedAt: now,
+	}
+	r.s.revisions[post.ID_Posts] = append(revisions, revision)
+	return &revision, nil
+}
+
+func (r memoryPosts) ListRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return append([]models.PostRevision{}, r.s.revisions[postID]...), nil
+}
+
+func (r memoryPosts) GetRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	revisions := r.s.revisions[postID]
+	if revision < 1 || revision > len(revisions) {
+		return nil, ErrNotFound
+	}
+	found := revisions[revision-1]
+	return &found, nil
+}
+
+func (r memoryPosts) SetStatus(ctx context.Context, id int, from, to string, actorID int) (*models.StatusChange, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.livePost(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if stored.Status != from {
+		return nil, ErrStale
+	}
+	now := r.s.Now()
+	stored.Status, stored.StatusChangedAt = to, now
+	if !models.Resolved(to) {
+		stored.SLA.ResolvedAt = nil
+	} else if stored.SLA.ResolvedAt == nil {
+		stored.SLA.ResolvedAt = &now
+	}
+	r.s.posts[id] = stored
+
+	change := models.StatusChange{ID_post: id, FromStatus: from, ToStatus: to, ID_actor: &actorID, CreatedAt: now}
+	r.s.statuses[id] = append(r.s.statuses[id], change)
+	return &change, nil
+}
+
+func (r memoryPosts) Assign(ctx context.Context, id int, assigneeID *int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.livePost(id)
+	if !ok {
+		return ErrNotFound
+	}
+	stored.ID_assignee = nil
+	if assigneeID != nil {
+		assignee := *assigneeID
+		stored.ID_assignee = &assignee
+	}
+	r.s.posts[id] = stored
+	return nil
+}
+
+func (r memoryPosts) SetCategory(ctx context.Context, id, categoryID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.livePost(id)
+	if !ok {
+		return ErrNotFound
+	}
+	stored.ID_category = categoryID
+	r.s.posts[id] = stored
+	return nil
+}
+
+func (r memoryPosts) ListStatusChanges(ctx context.Context, postID int) ([]models.StatusChange, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return append([]models.StatusChange{}, r.s.statuses[postID]...), nil
+}
+
+// slaPending reports whether the post has a first response or resolution
+// deadline still to meet before t, like the pendingBefore SQL condition
+func slaPending(post models.Post, t time.Time) bool {
+	sla := post.SLA
+	return (sla.FirstResponseAt == nil && sla.FirstResponseDue != nil && !sla.FirstResponseDue.After(t)) ||
+		(sla.ResolutionDue != nil && !sla.ResolutionDue.After(t))
+}
+
+// inSLAState applies the SLA filter; the caller must hold s.mu
+func (r memoryPosts) inSLAState(post models.Post, filter PostFilter) bool {
+	if !slices.Contains(models.ActiveStatuses, post.Status) {
+		return false
+	}
+	now := r.s.Now()
+	if filter.SLA == SLABreached {
+		return slaPending(post, now)
+	}
+	return slaPending(post, now.Add(filter.AtRiskWindow)) && !slaPending(post, now)
+}
+
+func (r memoryPosts) SetPriority(ctx context.Context, id int, priority string, firstResponseDue, resolutionDue time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.livePost(id)
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Priority = priority
+	stored.SLA.FirstResponseDue, stored.SLA.ResolutionDue = &firstResponseDue, &resolutionDue
+	stored.SLA.FirstResponseBreachedAt, stored.SLA.ResolutionBreachedAt = nil, nil
+	r.s.posts[id] = stored
+	return nil
+}
+
+func (r memoryPosts) MarkFirstResponse(ctx context.Context, id int, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if stored, ok := r.s.posts[id]; ok && stored.SLA.FirstResponseAt == nil {
+		stored.SLA.FirstResponseAt = &at
+		r.s.posts[id] = stored
+	}
+	return nil
+}
+
+func (r memoryPosts) ListSLABreaches(ctx context.Context, now time.Time) ([]models.SLABreach, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var breaches []models.SLABreach
+	for _, post := range r.s.posts {
+		if post.DeletedAt != nil || !slices.Contains(models.ActiveStatuses, post.Status) {
+			continue
+		}
+		sla := post.SLA
+		if sla.FirstResponseAt == nil && sla.FirstResponseDue != nil && !sla.FirstResponseDue.After(now) && sla.FirstResponseBreachedAt == nil {
+			breaches = append(breaches, models.SLABreach{ID_post: post.ID_Posts, Kind: models.SLAFirstResponse, Due: *sla.FirstResponseDue, ID_assignee: post.ID_assignee})
+		}
+		if sla.ResolutionDue != nil && !sla.ResolutionDue.After(now) && sla.ResolutionBreachedAt == nil {
+			breaches = append(breaches, models.SLABreach{ID_post: post.ID_Posts, Kind: models.SLAResolution, Due: *sla.ResolutionDue, ID_assignee: post.ID_assignee})
+		}
+	}
+	// Same order as the SQL query: by deadline, then post
+	sort.Slice(breaches, func(i, j int) bool {
+		if !breaches[i].Due.Equal(breaches[j].Due) {
+			return breaches[i].Due.Before(breaches[j].Due)
+		}
+		return breaches[i].ID_post < breaches[j].ID_post
+	})
+	return breaches, nil
+}
+
+func (r memoryPosts) FlagSLABreach(ctx context.Context, id int, kind string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.posts[id]
+	if !ok {
+		return ErrStale
+	}
+	flag := &stored.SLA.FirstResponseBreachedAt
+	if kind == models.SLAResolution {
+		flag = &stored.SLA.ResolutionBreachedAt
+	}
+	if *flag != nil {
+		return ErrStale
+	}
+	*flag = &at
+	r.s.posts[id] = stored
+	return nil
+}
+
+func (r memoryPosts) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.livePost(id)
+	if !ok {
+		return ErrNotFound
+	}
+	now := r.s.Now()
+	stored.DeletedAt = &now
+	r.s.posts[id] = stored
+	return nil
+}
+
+func (r memoryPosts) Restore(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.posts[id]
+	if !ok || stored.DeletedAt == nil {
+		return ErrNotFound
+	}
+	stored.DeletedAt = nil
+	r.s.posts[id] = stored
+	return nil
+}
+
+func (r memoryPosts) ListDeletedBefore(ctx context.Context, cutoff time.Time) ([]int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	ids := []int{}
+	for id, post := range r.s.posts {
+		if post.DeletedAt != nil && post.DeletedAt.Before(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (r memoryPosts) Purge(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if post, ok := r.s.posts[id]; !ok || post.DeletedAt == nil {
+		return ErrNotFound
+	}
+	r.s.purgePost(id)
+	return nil
+}
+
+type memoryComments struct{ s *MemoryStore }
+
+func (r memoryComments) Create(ctx context.Context, comment *models.Comment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	comment.ID_comment = r.s.nextID("comments")
+	comment.CreatedAt = r.s.Now()
+	stored := *comment
+	stored.Replies = nil
+	r.s.comments[comment.ID_comment] = stored
+	return nil
+}
+
+func (r memoryComments) GetByID(ctx context.Context, postID, commentID int) (*models.Comment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	comment, ok := r.s.comments[commentID]
+	if !ok || comment.ID_post != postID {
+		return nil, ErrNotFound
+	}
+	return &comment, nil
+}
+
+func (r memoryComments) List(ctx context.Context, postID int, filter CommentFilter) ([]*models.Comment, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var all []models.Comment
+	for _, comment := range r.s.comments {
+		if comment.ID_post == postID {
+			all = append(all, comment)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].CreatedAt.Before(all[j].CreatedAt)
+		}
+		return all[i].ID_comment < all[j].ID_comment
+	})
+
+	offset := (filter.Page - 1) * filter.Limit
+	page := func(items []models.Comment) []models.Comment {
+		start := min(offset, len(items))
+		return items[start:min(start+filter.Limit, len(items))]
+	}
+
+	if filter.View == CommentViewFlat {
+		comments := []*models.Comment{}
+		for _, comment := range page(all) {
+			comments = append(comments, &comment)
+		}
+		return comments, len(all), nil
+	}
+
+	// Take a page of top-level comments, then keep their descendants
+	var roots []models.Comment
+	for _, comment := range all {
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		}
+	}
+	included := map[int]bool{}
+	for _, root := range page(roots) {
+		included[root.ID_comment] = true
+	}
+	comments := []*models.Comment{}
+	for _, comment := range all {
+		if comment.ParentID != nil && included[*comment.ParentID] {
+			included[comment.ID_comment] = true
+		}
+		if included[comment.ID_comment] {
+			comments = append(comments, &comment)
+		}
+	}
+	return comments, len(roots), nil
+}
+
+func (r memoryComments) UpdateContent(ctx context.Context, commentID int, content string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.comments[commentID]
+	if !ok {
+		return ErrNotFound
+	}
+	now := r.s.Now()
+	stored.Content, stored.UpdatedAt = content, &now
+	r.s.comments[commentID] = stored
+	return nil
+}
+
+func (r memoryComments) Delete(ctx context.Context, commentID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.comments[commentID]; !ok {
+		return ErrNotFound
+	}
+	r.s.deleteComment(commentID)
+	return nil
+}
+
+func (r memoryComments) SetAccepted(ctx context.Context, postID int, commentID *int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.livePost(postID); !ok {
+		return ErrNotFound
+	}
+	if commentID != nil {
+		if comment, ok := r.s.comments[*commentID]; !ok || comment.ID_post != postID {
+			return ErrNotFound
+		}
+	}
+
+	for id, comment := range r.s.comments {
+		if comment.ID_post == postID && comment.AcceptedAt != nil {
+			comment.AcceptedAt = nil
+			r.s.comments[id] = comment
+		}
+	}
+	if commentID != nil {
+		now := r.s.Now()
+		comment := r.s.comments[*commentID]
+		comment.AcceptedAt = &now
+		r.s.comments[*commentID] = comment
+	}
+	return nil
+}
+
+type memoryAttachments struct{ s *MemoryStore }
+
+func (r memoryAttachments) Create(ctx context.Context, attachment *models.Attachment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	attachment.ID_attachment = r.s.nextID("attachments")
+	attachment.CreatedAt = r.s.Now()
+	stored := *attachment
+	stored.URL = ""
+	r.s.attachments[attachment.ID_attachment] = stored
+	return nil
+}
+
+func (r memoryAttachments) GetByID(ctx context.Context, postID, attachmentID int) (*models.Attachment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	attachment, ok := r.s.attachments[attachmentID]
+	if !ok || attachment.ID_post != postID {
+		return nil, ErrNotFound
+	}
+	return &attachment, nil
+}
+
+func (r memoryAttachments) ListByPost(ctx context.Context, postID int) ([]models.Attachment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	attachments := []models.Attachment{}
+	for _, attachment := range r.s.attachments {
+		if attachment.ID_post == postID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if !attachments[i].CreatedAt.Equal(attachments[j].CreatedAt) {
+			return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+		}
+		return attachments[i].ID_attachment < attachments[j].ID_attachment
+	})
+	return attachments, nil
+}
+
+func (r memoryAttachments) ListByPostLocked(ctx context.Context, postID int) ([]models.Attachment, error) {
+	r.s.mu.RLock()
+	_, ok := r.s.livePost(postID)
+	r.s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r.ListByPost(ctx, postID)
+}
+
+func (r memoryAttachments) Delete(ctx context.Context, attachmentID int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.attachments[attachmentID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.attachments, attachmentID)
+	return nil
+}
+
+func (r memoryAttachments) ListOwnedBy(ctx context.Context, userID int) ([]models.Attachment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	attachments := []models.Attachment{}
+	for _, attachment := range r.s.attachments {
+		if attachment.ID_user == userID || r.s.posts[attachment.ID_post].ID_user == userID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID_attachment < attachments[j].ID_attachment })
+	return attachments, nil
+}
+
+type memoryAudit struct{ s *MemoryStore }
+
+func (r memoryAudit) Record(ctx context.Context, entry *models.AuditEntry) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	entry.ID_audit = r.s.nextID("audit_log")
+	entry.CreatedAt = r.s.Now()
+	r.s.audit = append(r.s.audit, *entry)
+	return nil
+}
+
+func (r memoryAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	// Entries are appended in order, so walk them backwards for newest first
+	matched := []models.AuditEntry{}
+	for i := len(r.s.audit) - 1; i >= 0; i-- {
+		entry := r.s.audit[i]
+		switch {
+		case filter.ActorID != 0 && (entry.ID_actor == nil || *entry.ID_actor != filter.ActorID),
+			filter.Action != "" && entry.Action != filter.Action,
+			filter.TargetType != "" && entry.TargetType != filter.TargetType,
+			filter.TargetID != 0 && entry.TargetID != filter.TargetID,
+			filter.From != nil && entry.CreatedAt.Before(*filter.From),
+			filter.To != nil && !entry.CreatedAt.Before(*filter.To):
+			continue
+		}
+		matched = append(matched, entry)
+	}
+
+	start := min(filter.Offset, len(matched))
+	end := min(start+filter.Limit, len(matched))
+	return append([]models.AuditEntry{}, matched[start:end]...), len(matched), nil
+}
+
+// tagNames returns the tags of a post sorted by name; the caller must hold s.mu
+func (s *MemoryStore) tagNames(postID int) []string {
+	names := []string{}
+	for _, tagID := range s.postTags[postID] {
+		names = append(names, s.tags[tagID])
+	}
+	slices.Sort(names)
+	return names
+}
+
+// categoryPath maps a category and its ancestors to their slugs; the caller
+// must hold s.mu
+func (s *MemoryStore) categoryPath(categoryID int) map[int]string {
+	path := map[int]string{}
+	for id := categoryID; id != 0; {
+		category, ok := s.categories[id]
+		if !ok {
+			break
+		}
+		path[id] = category.Slug
+		id = 0
+		if category.ID_parent != nil {
+			id = *category.ID_parent
+		}
+	}
+	return path
+}
+
+// inCategory reports whether a category is the one named by the filter or
+// one of its descendants; the caller must hold s.mu
+func (s *MemoryStore) inCategory(categoryID int, filter PostFilter) bool {
+	for id := categoryID; id != 0; {
+		category, ok := s.categories[id]
+		if !ok {
+			return false
+		}
+		if id == filter.CategoryID || (filter.CategoryID == 0 && category.Slug == filter.CategorySlug) {
+			return true
+		}
+		id = 0
+		if category.ID_parent != nil {
+			id = *category.ID_parent
+		}
+	}
+	return false
+}
+
+type memoryCategories struct{ s *MemoryStore }
+
+// slugTaken reports whether another category uses the slug; the caller must hold s.mu
+func (r memoryCategories) slugTaken(slug string, exceptID int) bool {
+	for id, category := range r.s.categories {
+		if id != exceptID && category.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memoryCategories) Create(ctx context.Context, category *models.Category) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.slugTaken(category.Slug, 0) {
+		return ErrConflict
+	}
+	category.ID_category = r.s.nextID("categories")
+	category.CreatedAt = r.s.Now()
+	category.PostCount, category.Children = 0, nil
+	r.s.categories[category.ID_category] = *category
+	return nil
+}
+
+// withPostCount fills the live posts of the category; the caller must hold s.mu
+func (r memoryCategories) withPostCount(category models.Category) models.Category {
+	category.PostCount = 0
+	for _, post := range r.s.posts {
+		if post.ID_category == category.ID_category && post.DeletedAt == nil {
+			category.PostCount++
+		}
+	}
+	return category
+}
+
+func (r memoryCategories) List(ctx context.Context) ([]models.Category, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	categories := []models.Category{}
+	for _, category := range r.s.categories {
+		categories = append(categories, r.withPostCount(category))
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := categories[i], categories[j]
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID_category < b.ID_category
+	})
+	return categories, nil
+}
+
+func (r memoryCategories) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	category, ok := r.s.categories[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	category = r.withPostCount(category)
+	return &category, nil
+}
+
+func (r memoryCategories) Update(ctx context.Context, category *models.Category) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.categories[category.ID_category]
+	if !ok {
+		return ErrNotFound
+	}
+	if r.slugTaken(category.Slug, category.ID_category) {
+		return ErrConflict
+	}
+	stored.ID_parent, stored.Slug, stored.Name = category.ID_parent, category.Slug, category.Name
+	stored.Description, stored.SortOrder = category.Description, category.SortOrder
+	r.s.categories[category.ID_category] = stored
+	return nil
+}
+
+func (r memoryCategories) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.categories[id]; !ok {
+		return ErrNotFound
+	}
+	for _, category := range r.s.categories {
+		if category.ID_parent != nil && *category.ID_parent == id {
+			return ErrInUse
+		}
+	}
+	for _, post := range r.s.posts {
+		if post.ID_category == id {
+			return ErrInUse
+		}
+	}
+	delete(r.s.categories, id)
+	// Overrides of the category go with it, like ON DELETE CASCADE
+	for key := range r.s.slaPolicies {
+		if key.categoryID == id {
+			delete(r.s.slaPolicies, key)
+		}
+	}
+	return nil
+}
+
+type memorySLAPolicies struct{ s *MemoryStore }
+
+// effective returns the policy of the priority in effect in the category;
+// the caller must hold s.mu
+func (r memorySLAPolicies) effective(categoryID int, priority string) (models.SLAPolicy, bool) {
+	if policy, ok := r.s.slaPolicies[slaPolicyKey{categoryID, priority}]; ok && categoryID != 0 {
+		return policy, true
+	}
+	policy, ok := r.s.slaPolicies[slaPolicyKey{0, priority}]
+	return policy, ok
+}
+
+func (r memorySLAPolicies) List(ctx context.Context, categoryID int) ([]models.SLAPolicy, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	policies := []models.SLAPolicy{}
+	for _, priority := range models.Priorities {
+		if policy, ok := r.effective(categoryID, priority); ok {
+			policies = append(policies, policy)
+		}
+	}
+	return policies, nil
+}
+
+func (r memorySLAPolicies) Get(ctx context.Context, categoryID int, priority string) (*models.SLAPolicy, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	policy, ok := r.effective(categoryID, priority)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &policy, nil
+}
+
+func (r memorySLAPolicies) Update(ctx context.Context, policy *models.SLAPolicy) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.slaPolicies[slaPolicyKey{0, policy.Priority}]; !ok {
+		return ErrNotFound
+	}
+	key := slaPolicyKey{0, policy.Priority}
+	stored := *policy
+	if policy.ID_category != nil {
+		if _, ok := r.s.categories[*policy.ID_category]; !ok {
+			return ErrNotFound
+		}
+		categoryID := *policy.ID_category
+		key.categoryID, stored.ID_category = categoryID, &categoryID
+	}
+	r.s.slaPolicies[key] = stored
+	return nil
+}
+
+func (r memorySLAPolicies) DeleteOverride(ctx context.Context, categoryID int, priority string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key := slaPolicyKey{categoryID, priority}
+	if _, ok := r.s.slaPolicies[key]; !ok || categoryID == 0 {
+		return ErrNotFound
+	}
+	delete(r.s.slaPolicies, key)
+	return nil
+}
+
+type memoryTags struct{ s *MemoryStore }
+
+// withPostCount counts the live posts carrying the tag; the caller must hold s.mu
+func (r memoryTags) withPostCount(id int) models.Tag {
+	tag := models.Tag{ID_tag: id, Name: r.s.tags[id]}
+	for postID, tagIDs := range r.s.postTags {
+		if _, ok := r.s.livePost(postID); ok && slices.Contains(tagIDs, id) {
+			tag.PostCount++
+		}
+	}
+	return tag
+}
+
+// tagID returns the ID of the tag with the name, 0 when there is none; the
+// caller must hold s.mu
+func (r memoryTags) tagID(name string) int {
+	for id, tag := range r.s.tags {
+		if tag == name {
+			return id
+		}
+	}
+	return 0
+}
+
+// createTag returns the ID of the tag, adding it when missing; the caller
+// must hold s.mu
+func (r memoryTags) createTag(name string) int {
+	if id := r.tagID(name); id != 0 {
+		return id
+	}
+	id := r.s.nextID("tags")
+	r.s.tags[id] = name
+	return id
+}
+
+func (r memoryTags) List(ctx context.Context, prefix string, limit int) ([]models.Tag, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	tags := []models.Tag{}
+	for id, name := range r.s.tags {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if tag := r.withPostCount(id); tag.PostCount > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags[:min(limit, len(tags))], nil
+}
+
+func (r memoryTags) GetByName(ctx context.Context, name string) (*models.Tag, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	id := r.tagID(name)
+	if id == 0 {
+		return nil, ErrNotFound
+	}
+	tag := r.withPostCount(id)
+	return &tag, nil
+}
+
+func (r memoryTags) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.livePost(postID); !ok {
+		return ErrNotFound
+	}
+	tagIDs := []int{}
+	for _, name := range tags {
+		tagIDs = append(tagIDs, r.createTag(name))
+	}
+	r.s.postTags[postID] = tagIDs
+	return nil
+}
+
+func (r memoryTags) Merge(ctx context.Context, from, into string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	fromID := r.tagID(from)
+	if fromID == 0 {
+		return 0, ErrNotFound
+	}
+	intoID := r.createTag(into)
+
+	moved := 0
+	for postID, tagIDs := range r.s.postTags {
+		i := slices.Index(tagIDs, fromID)
+		if i < 0 {
+			continue
+		}
+		moved++
+		// Posts carrying both tags keep a single one
+		tagIDs = slices.Delete(slices.Clone(tagIDs), i, i+1)
+		if !slices.Contains(tagIDs, intoID) {
+			tagIDs = append(tagIDs, intoID)
+		}
+		r.s.postTags[postID] = tagIDs
+	}
+	delete(r.s.tags, fromID)
+	return moved, nil
+}
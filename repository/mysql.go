@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// timeLayout is the format of DATETIME columns as returned by the driver
+const timeLayout = "2006-01-02 15:04:05"
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// parseTime converts a DATETIME column read as a string
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(timeLayout, s)
+}
+
+// parseNullTime converts a nullable DATETIME column read as a string
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := parseTime(s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique constraint violation
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// checkAffected turns an UPDATE or DELETE that matched no row into ErrNotFound
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// queryIDs runs a query selecting a single integer column
+func queryIDs(ctx context.Context, db *sql.DB, query string, args ...any) ([]int, error) {
+	rows, err := conn(ctx, db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// dbConn is satisfied by *sql.DB and *sql.Tx
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txKey is the context key of the transaction started by withTx
+type txKey struct{}
+
+// conn returns the transaction carried by ctx, or db outside of one
+func conn(ctx context.Context, db *sql.DB) dbConn {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// withTx runs fn with a context carrying a transaction, committing when it
+// returns nil; inside an existing transaction fn simply joins it
+func withTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// MySQLTransactor runs functions in MySQL transactions shared by the MySQL
+// repositories
+type MySQLTransactor struct {
+	db *sql.DB
+}
+
+// NewMySQLTransactor creates a transactor for the given database
+func NewMySQLTransactor(db *sql.DB) *MySQLTransactor {
+	return &MySQLTransactor{db: db}
+}
+
+func (t *MySQLTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTx(ctx, t.db, fn)
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLAttachmentRepository stores attachment metadata in the attachments table
+type MySQLAttachmentRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLAttachmentRepository creates an attachment repository backed by the given database
+func NewMySQLAttachmentRepository(db *sql.DB) *MySQLAttachmentRepository {
+	return &MySQLAttachmentRepository{db: db}
+}
+
+// attachmentColumns is the column list shared by every attachment query
+const attachmentColumns = "id_attachment, id_post, id_user, file_name, content_type, size, storage_key, created_at"
+
+func scanAttachment(row rowScanner) (*models.Attachment, error) {
+	var attachment models.Attachment
+	var createdAtStr string
+
+	if err := row.Scan(&attachment.ID_attachment, &attachment.ID_post, &attachment.ID_user, &attachment.FileName,
+		&attachment.ContentType, &attachment.Size, &attachment.StorageKey, &createdAtStr); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var err error
+	if attachment.CreatedAt, err = parseTime(createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	return &attachment, nil
+}
+
+func (r *MySQLAttachmentRepository) Create(ctx context.Context, attachment *models.Attachment) error {
+	attachment.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	result, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO attachments (id_post, id_user, file_name, content_type, size, storage_key, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		attachment.ID_post, attachment.ID_user, attachment.FileName, attachment.ContentType, attachment.Size, attachment.StorageKey, attachment.CreatedAt.Format(timeLayout))
+	if err != nil {
+		return fmt.Errorf("insert attachment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("read new attachment id: %w", err)
+	}
+	attachment.ID_attachment = int(id)
+	return nil
+}
+
+func (r *MySQLAttachmentRepository) GetByID(ctx context.Context, postID, attachmentID int) (*models.Attachment, error) {
+	return scanAttachment(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE id_attachment = ? AND id_post = ?", attachmentID, postID))
+}
+
+func (r *MySQLAttachmentRepository) ListByPost(ctx context.Context, postID int) ([]models.Attachment, error) {
+	return r.queryAttachments(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE id_post = ? ORDER BY created_at, id_attachment", postID)
+}
+
+func (r *MySQLAttachmentRepository) ListByPostLocked(ctx context.Context, postID int) ([]models.Attachment, error) {
+	var id int
+	err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT id_posts FROM posts WHERE id_posts = ? AND deleted_at IS NULL FOR UPDATE", postID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("lock post: %w", err)
+	}
+	return r.ListByPost(ctx, postID)
+}
+
+func (r *MySQLAttachmentRepository) ListOwnedBy(ctx context.Context, userID int) ([]models.Attachment, error) {
+	return r.queryAttachments(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE id_user = ? OR id_post IN (SELECT id_posts FROM posts WHERE id_user = ?) ORDER BY id_attachment", userID, userID)
+}
+
+// queryAttachments runs a query selecting attachmentColumns
+func (r *MySQLAttachmentRepository) queryAttachments(ctx context.Context, query string, args ...any) ([]models.Attachment, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		attachments = append(attachments, *attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+func (r *MySQLAttachmentRepository) Delete(ctx context.Context, attachmentID int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM attachments WHERE id_attachment = ?", attachmentID)
+	if err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	return checkAffected(result)
+}
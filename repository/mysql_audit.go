@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MySQLAuditRepository appends to the audit_log table, which triggers keep
+// append-only
+type MySQLAuditRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLAuditRepository creates an audit repository backed by the given database
+func NewMySQLAuditRepository(db *sql.DB) *MySQLAuditRepository {
+	return &MySQLAuditRepository{db: db}
+}
+
+// auditColumns is the column list shared by every audit query
+const auditColumns = "id_audit, id_actor, action, target_type, target_id, before_json, after_json, ip, user_agent, created_at"
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var actor sql.NullInt64
+	var before, after sql.NullString
+	var createdAtStr string
+	if err := row.Scan(&entry.ID_audit, &actor, &entry.Action, &entry.TargetType, &entry.TargetID,
+		&before, &after, &entry.IP, &entry.UserAgent, &createdAtStr); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if actor.Valid {
+		id := int(actor.Int64)
+		entry.ID_actor = &id
+	}
+	if before.Valid {
+		entry.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		entry.After = json.RawMessage(after.String)
+	}
+	var err error
+	if entry.CreatedAt, err = parseTime(createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	return &entry, nil
+}
+
+// nullJSON stores an empty document as NULL
+func nullJSON(doc json.RawMessage) any {
+	if len(doc) == 0 {
+		return nil
+	}
+	return string(doc)
+}
+
+func (r *MySQLAuditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	result, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO audit_log (id_actor, action, target_type, target_id, before_json, after_json, ip, user_agent, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.ID_actor, entry.Action, entry.TargetType, entry.TargetID, nullJSON(entry.Before), nullJSON(entry.After),
+		entry.IP, entry.UserAgent, entry.CreatedAt.Format(timeLayout))
+	if err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("read new audit entry id: %w", err)
+	}
+	entry.ID_audit = int(id)
+	return nil
+}
+
+func (r *MySQLAuditRepository) List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, int, error) {
+	var conds []string
+	var args []any
+	if filter.ActorID != 0 {
+		conds = append(conds, "id_actor = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		conds = append(conds, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		conds = append(conds, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		conds = append(conds, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.From != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, filter.From.UTC().Format(timeLayout))
+	}
+	if filter.To != nil {
+		conds = append(conds, "created_at < ?")
+		args = append(args, filter.To.UTC().Format(timeLayout))
+	}
+
+	var total int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where(conds), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count audit entries: %w", err)
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_log" + where(conds) + " ORDER BY created_at DESC, id_audit DESC LIMIT ? OFFSET ?"
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan audit entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate audit entries: %w", err)
+	}
+	return entries, total, nil
+}
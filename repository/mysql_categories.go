@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLCategoryRepository stores categories in the categories table
+type MySQLCategoryRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLCategoryRepository creates a category repository backed by the given database
+func NewMySQLCategoryRepository(db *sql.DB) *MySQLCategoryRepository {
+	return &MySQLCategoryRepository{db: db}
+}
+
+// categoryColumns is the column list shared by every category query, including the number of live posts
+const categoryColumns = "id_category, id_parent, slug, name, description, sort_order, created_at, " +
+	"(SELECT COUNT(*) FROM posts WHERE posts.id_category = categories.id_category AND posts.deleted_at IS NULL) AS post_count"
+
+func scanCategory(row rowScanner) (*models.Category, error) {
+	var category models.Category
+	var parent sql.NullInt64
+	var createdAtStr string
+	if err := row.Scan(&category.ID_category, &parent, &category.Slug, &category.Name, &category.Description,
+		&category.SortOrder, &createdAtStr, &category.PostCount); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if parent.Valid {
+		id := int(parent.Int64)
+		category.ID_parent = &id
+	}
+	var err error
+	if category.CreatedAt, err = parseTime(createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	return &category, nil
+}
+
+func (r *MySQLCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	category.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	result, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO categories (id_parent, slug, name, description, sort_order, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		category.ID_parent, category.Slug, category.Name, category.Description, category.SortOrder, category.CreatedAt.Format(timeLayout))
+	if isDuplicateEntry(err) {
+		return ErrConflict
+	} else if err != nil {
+		return fmt.Errorf("insert category: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("read new category id: %w", err)
+	}
+	category.ID_category = int(id)
+	return nil
+}
+
+func (r *MySQLCategoryRepository) List(ctx context.Context) ([]models.Category, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT "+categoryColumns+" FROM categories ORDER BY sort_order, name, id_category")
+	if err != nil {
+		return nil, fmt.Errorf("query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan category: %w", err)
+		}
+		categories = append(categories, *category)
+	}
+	return categories, rows.Err()
+}
+
+func (r *MySQLCategoryRepository) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	return scanCategory(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id_category = ?", id))
+}
+
+func (r *MySQLCategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	// Check existence first: MySQL reports 0 affected rows when nothing changed
+	if _, err := r.GetByID(ctx, category.ID_category); err != nil {
+		return err
+	}
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE categories SET id_parent = ?, slug = ?, name = ?, description = ?, sort_order = ? WHERE id_category = ?",
+		category.ID_parent, category.Slug, category.Name, category.Description, category.SortOrder, category.ID_category)
+	if isDuplicateEntry(err) {
+		return ErrConflict
+	} else if err != nil {
+		return fmt.Errorf("update category: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLCategoryRepository) Delete(ctx context.Context, id int) error {
+	// Trashed posts count too: they keep their category when restored
+	var children, posts int
+	err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM categories WHERE id_parent = ?), (SELECT COUNT(*) FROM posts WHERE id_category = ?)", id, id).
+		Scan(&children, &posts)
+	if err != nil {
+		return fmt.Errorf("count category references: %w", err)
+	}
+	if children > 0 || posts > 0 {
+		return ErrInUse
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM categories WHERE id_category = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete category: %w", err)
+	}
+	return checkAffected(result)
+}
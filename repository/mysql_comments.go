@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLCommentRepository stores comments in the comments table
+type MySQLCommentRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLCommentRepository creates a comment repository backed by the given database
+func NewMySQLCommentRepository(db *sql.DB) *MySQLCommentRepository {
+	return &MySQLCommentRepository{db: db}
+}
+
+// commentColumns is the column list shared by every comment query
+const commentColumns = "id_comment, id_post, id_user, parent_id, content, created_at, updated_at, accepted_at"
+
+func scanComment(row rowScanner) (*models.Comment, error) {
+	var comment models.Comment
+	var parentID sql.NullInt64
+	var createdAtStr string
+	var updatedAtStr, acceptedAtStr sql.NullString
+
+	if err := row.Scan(&comment.ID_comment, &comment.ID_post, &comment.ID_user, &parentID, &comment.Content, &createdAtStr, &updatedAtStr, &acceptedAtStr); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		comment.ParentID = &id
+	}
+
+	// Convert the timestamps to time.Time
+	var err error
+	if comment.CreatedAt, err = parseTime(createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	if comment.UpdatedAt, err = parseNullTime(updatedAtStr); err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	if comment.AcceptedAt, err = parseNullTime(acceptedAtStr); err != nil {
+		return nil, fmt.Errorf("parse accepted_at: %w", err)
+	}
+	return &comment, nil
+}
+
+func (r *MySQLCommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	comment.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	result, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO comments (id_post, id_user, parent_id, content, created_at) VALUES (?, ?, ?, ?, ?)",
+		comment.ID_post, comment.ID_user, comment.ParentID, comment.Content, comment.CreatedAt.Format(timeLayout))
+	if err != nil {
+		return fmt.Errorf("insert comment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("read new comment id: %w", err)
+	}
+	comment.ID_comment = int(id)
+	return nil
+}
+
+func (r *MySQLCommentRepository) GetByID(ctx context.Context, postID, commentID int) (*models.Comment, error) {
+	return scanComment(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+commentColumns+" FROM comments WHERE id_comment = ? AND id_post = ?", commentID, postID))
+}
+
+func (r *MySQLCommentRepository) List(ctx context.Context, postID int, filter CommentFilter) ([]*models.Comment, int, error) {
+	// Count what is being paginated: every comment in flat view, top-level ones in tree view
+	countQuery := "SELECT COUNT(*) FROM comments WHERE id_post = ?"
+	if filter.View == CommentViewTree {
+		countQuery += " AND parent_id IS NULL"
+	}
+	var total int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, countQuery, postID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count comments: %w", err)
+	}
+
+	var query string
+	if filter.View == CommentViewFlat {
+		query = "SELECT " + commentColumns + " FROM comments WHERE id_post = ? ORDER BY created_at, id_comment LIMIT ? OFFSET ?"
+	} else {
+		// Select a page of top-level comments together with all of their descendants
+		query = `
+		WITH RECURSIVE thread AS (
+			SELECT * FROM (
+				SELECT * FROM comments WHERE id_post = ? AND parent_id IS NULL
+				ORDER BY created_at, id_comment LIMIT ? OFFSET ?
+			) AS roots
+			UNION ALL
+			SELECT child.* FROM comments child JOIN thread ON child.parent_id = thread.id_comment
+		)
+		SELECT ` + commentColumns + ` FROM thread ORDER BY created_at, id_comment`
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, postID, filter.Limit, (filter.Page-1)*filter.Limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate comments: %w", err)
+	}
+	return comments, total, nil
+}
+
+func (r *MySQLCommentRepository) UpdateContent(ctx context.Context, commentID int, content string) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		// Check existence first: MySQL reports 0 affected rows when nothing changed
+		var id int
+		err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT id_comment FROM comments WHERE id_comment = ? FOR UPDATE", commentID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("lock comment: %w", err)
+		}
+
+		if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE comments SET content = ?, updated_at = ? WHERE id_comment = ?",
+			content, time.Now().UTC().Format(timeLayout), commentID); err != nil {
+			return fmt.Errorf("update comment: %w", err)
+		}
+		return nil
+	})
+}
+
+// commentSubtree selects a comment and all of its replies, the deepest first
+const commentSubtree = `WITH RECURSIVE subtree AS (
+		SELECT id_comment, 0 AS depth FROM comments WHERE id_comment = ?
+		UNION ALL
+		SELECT child.id_comment, subtree.depth + 1 FROM comments child JOIN subtree ON child.parent_id = subtree.id_comment
+	)
+	SELECT id_comment FROM subtree ORDER BY depth DESC, id_comment`
+
+func (r *MySQLCommentRepository) Delete(ctx context.Context, commentID int) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		// Remove the replies leaves first: InnoDB aborts parent_id cascades
+		// deeper than 15 levels
+		ids, err := queryIDs(ctx, r.db, commentSubtree, commentID)
+		if err != nil {
+			return fmt.Errorf("query comment replies: %w", err)
+		}
+		if len(ids) == 0 {
+			return ErrNotFound
+		}
+		for _, id := range ids {
+			if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM comments WHERE id_comment = ?", id); err != nil {
+				return fmt.Errorf("delete comment: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *MySQLCommentRepository) SetAccepted(ctx context.Context, postID int, commentID *int) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		// Lock the post so concurrent requests cannot accept two answers
+		var id int
+		err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT id_posts FROM posts WHERE id_posts = ? AND deleted_at IS NULL FOR UPDATE", postID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("lock post: %w", err)
+		}
+
+		if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE comments SET accepted_at = NULL WHERE id_post = ? AND accepted_at IS NOT NULL", postID); err != nil {
+			return fmt.Errorf("unmark accepted answer: %w", err)
+		}
+		if commentID == nil {
+			return nil
+		}
+		result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE comments SET accepted_at = ? WHERE id_comment = ? AND id_post = ?", now.Format(timeLayout), *commentID, postID)
+		if err != nil {
+			return fmt.Errorf("mark accepted answer: %w", err)
+		}
+		return checkAffected(result)
+	})
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMySQLCommentUpdateContent(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		exists bool
+		want   error
+	}{
+		// MySQL reports 0 affected rows when the content is unchanged
+		{name: "unchanged content", exists: true, want: nil},
+		{name: "missing comment", exists: false, want: ErrNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, func(query string, args []any) fakeAnswer {
+				if strings.HasPrefix(query, "SELECT id_comment FROM comments") {
+					answer := fakeAnswer{columns: []string{"id_comment"}}
+					if tc.exists {
+						answer.rows = [][]driver.Value{{int64(7)}}
+					}
+					return answer
+				}
+				return fakeAnswer{affected: 0}
+			})
+
+			err := NewMySQLCommentRepository(db).UpdateContent(context.Background(), 7, "Same text")
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			log := fake.log()
+			if !strings.HasSuffix(log[1].query, "FOR UPDATE") {
+				t.Errorf("comment not locked: %q", log[1].query)
+			}
+			if updated := strings.HasPrefix(log[2].query, "UPDATE comments"); updated != tc.exists {
+				t.Errorf("statements = %+v", log)
+			}
+		})
+	}
+}
+
+func TestMySQLCommentDeleteRemovesRepliesDeepestFirst(t *testing.T) {
+	db, fake := newFakeDB(t, func(query string, args []any) fakeAnswer {
+		if strings.HasPrefix(query, "WITH RECURSIVE subtree") {
+			// A thread deeper than the 15 levels InnoDB cascades through
+			answer := fakeAnswer{columns: []string{"id_comment"}}
+			for id := 20; id >= 1; id-- {
+				answer.rows = append(answer.rows, []driver.Value{int64(id)})
+			}
+			return answer
+		}
+		return fakeAnswer{affected: 1}
+	})
+
+	if err := NewMySQLCommentRepository(db).Delete(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	var deleted []any
+	for _, statement := range fake.log() {
+		if strings.HasPrefix(statement.query, "DELETE FROM comments") {
+			deleted = append(deleted, statement.args[0])
+		}
+	}
+	if len(deleted) != 20 || deleted[0] != int64(20) || deleted[19] != int64(1) {
+		t.Errorf("deleted = %v", deleted)
+	}
+}
+
+func TestMySQLCommentDeleteMissing(t *testing.T) {
+	db, _ := newFakeDB(t, func(query string, args []any) fakeAnswer {
+		return fakeAnswer{columns: []string{"id_comment"}}
+	})
+
+	if err := NewMySQLCommentRepository(db).Delete(context.Background(), 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v", err)
+	}
+}
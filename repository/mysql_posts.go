@@ -0,0 +1,544 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MySQLPostRepository stores posts in the posts table
+type MySQLPostRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLPostRepository creates a post repository backed by the given database
+func NewMySQLPostRepository(db *sql.DB) *MySQLPostRepository {
+	return &MySQLPostRepository{db: db}
+}
+
+// postColumns is the column list shared by every post query, including the number of comments,
+// the tags joined by commas and the accepted answer
+const postColumns = "id_posts, title, content, id_user, id_category, created_at, edited_at, deleted_at, version, status, status_changed_at, id_assignee, priority, " +
+	"first_response_due, first_response_at, first_response_breached_at, resolution_due, resolved_at, resolution_breached_at, (SELECT COUNT(*) FROM comments WHERE comments.id_post = posts.id_posts) AS comment_count, " +
+	"(SELECT GROUP_CONCAT(tags.name ORDER BY tags.name SEPARATOR ',') FROM post_tags JOIN tags ON tags.id_tag = post_tags.id_tag WHERE post_tags.id_post = posts.id_posts) AS tags, " +
+	"(SELECT id_comment FROM comments WHERE comments.id_post = posts.id_posts AND comments.accepted_at IS NOT NULL LIMIT 1) AS id_accepted_comment"
+
+func scanPost(row rowScanner) (*models.Post, error) {
+	var post models.Post
+	var createdAtStr string
+	var editedAtStr, deletedAtStr sql.NullString
+	var statusChangedAtStr string
+	var assignee sql.NullInt64
+	var sla [6]sql.NullString
+	var tags sql.NullString
+	var accepted sql.NullInt64
+	if err := row.Scan(&post.ID_Posts, &post.Title, &post.Content, &post.ID_user, &post.ID_category, &createdAtStr, &editedAtStr, &deletedAtStr, &post.Version,
+		&post.Status, &statusChangedAtStr, &assignee, &post.Priority,
+		&sla[0], &sla[1], &sla[2], &sla[3], &sla[4], &sla[5], &post.CommentCount, &tags, &accepted); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var err error
+	if post.CreatedAt, err = parseTime(createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	if post.EditedAt, err = parseNullTime(editedAtStr); err != nil {
+		return nil, fmt.Errorf("parse edited_at: %w", err)
+	}
+	if post.DeletedAt, err = parseNullTime(deletedAtStr); err != nil {
+		return nil, fmt.Errorf("parse deleted_at: %w", err)
+	}
+	if post.StatusChangedAt, err = parseTime(statusChangedAtStr); err != nil {
+		return nil, fmt.Errorf("parse status_changed_at: %w", err)
+	}
+	if assignee.Valid {
+		id := int(assignee.Int64)
+		post.ID_assignee = &id
+	}
+	post.Tags = []string{}
+	if tags.Valid {
+		post.Tags = strings.Split(tags.String, ",")
+	}
+	if accepted.Valid {
+		id := int(accepted.Int64)
+		post.ID_accepted, post.Solved = &id, true
+	}
+	slaTimes := []**time.Time{
+		&post.SLA.FirstResponseDue, &post.SLA.FirstResponseAt, &post.SLA.FirstResponseBreachedAt,
+		&post.SLA.ResolutionDue, &post.SLA.ResolvedAt, &post.SLA.ResolutionBreachedAt,
+	}
+	for i, dst := range slaTimes {
+		if *dst, err = parseNullTime(sla[i]); err != nil {
+			return nil, fmt.Errorf("parse SLA time: %w", err)
+		}
+	}
+	return &post, nil
+}
+
+func (r *MySQLPostRepository) Create(ctx context.Context, post *models.Post) error {
+	post.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	post.Status, post.StatusChangedAt, post.Priority = models.StatusOpen, post.CreatedAt, models.PriorityNormal
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		result, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO posts (title, content, created_at, id_user, id_category, status, status_changed_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			post.Title, post.Content, post.CreatedAt.Format(timeLayout), post.ID_user, post.ID_category, post.Status, post.StatusChangedAt.Format(timeLayout))
+		if err != nil {
+			return fmt.Errorf("insert post: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("read new post id: %w", err)
+		}
+		post.ID_Posts = int(id)
+		post.Version = 1
+
+		// The post as first written is revision 1
+		_, err = conn(ctx, r.db).ExecContext(ctx, "INSERT INTO post_revisions (id_post, revision, title, content, id_editor, created_at) VALUES (?, 1, ?, ?, ?, ?)",
+			post.ID_Posts, post.Title, post.Content, post.ID_user, post.CreatedAt.Format(timeLayout))
+		if err != nil {
+			return fmt.Errorf("insert first revision: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *MySQLPostRepository) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	return scanPost(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+postColumns+" FROM posts WHERE id_posts = ? AND deleted_at IS NULL", id))
+}
+
+// filters builds the WHERE conditions shared by the count and the page query
+func (f PostFilter) filters() ([]string, []any) {
+	conds := []string{"deleted_at IS NULL"}
+	var args []any
+
+	if f.Trash {
+		conds[0] = "deleted_at IS NOT NULL"
+	}
+	if f.UserID != 0 {
+		conds = append(conds, "id_user = ?")
+		args = append(args, f.UserID)
+	}
+	if len(f.Statuses) > 0 {
+		conds = append(conds, "status IN (?"+strings.Repeat(", ?", len(f.Statuses)-1)+")")
+		for _, status := range f.Statuses {
+			args = append(args, status)
+		}
+	}
+	if f.AssigneeID != 0 {
+		conds = append(conds, "id_assignee = ?")
+		args = append(args, f.AssigneeID)
+	}
+	if f.Unassigned {
+		conds = append(conds, "id_assignee IS NULL")
+	}
+	if f.SLA != "" {
+		// Only unresolved posts have pending deadlines; a breached post has
+		// one before now and an at risk post has its earliest one after now
+		// but within the window
+		now := time.Now().UTC()
+		conds = append(conds, "status IN (?, ?, ?)", pendingBefore)
+		args = append(args, models.StatusOpen, models.StatusInProgress, models.StatusAwaitingCustomer)
+		if f.SLA == SLABreached {
+			args = append(args, now.Format(timeLayout), now.Format(timeLayout))
+		} else {
+			soon := now.Add(f.AtRiskWindow)
+			conds = append(conds, "NOT "+pendingBefore)
+			args = append(args, soon.Format(timeLayout), soon.Format(timeLayout), now.Format(timeLayout), now.Format(timeLayout))
+		}
+	}
+	if f.CategoryID != 0 || f.CategorySlug != "" {
+		// Walk down the tree from the named category
+		root, key := "id_category = ?", any(f.CategoryID)
+		if f.CategoryID == 0 {
+			root, key = "slug = ?", f.CategorySlug
+		}
+		conds = append(conds, "id_category IN (WITH RECURSIVE subtree AS ("+
+			"SELECT id_category FROM categories WHERE "+root+
+			" UNION ALL SELECT categories.id_category FROM categories JOIN subtree ON categories.id_parent = subtree.id_category"+
+			") SELECT id_category FROM subtree)")
+		args = append(args, key)
+	}
+	if f.Tag != "" {
+		conds = append(conds, "id_posts IN (SELECT post_tags.id_post FROM post_tags JOIN tags ON tags.id_tag = post_tags.id_tag WHERE tags.name = ?)")
+		args = append(args, f.Tag)
+	}
+	if f.Solved != nil {
+		solved := "EXISTS (SELECT 1 FROM comments WHERE comments.id_post = posts.id_posts AND comments.accepted_at IS NOT NULL)"
+		if !*f.Solved {
+			solved = "NOT " + solved
+		}
+		conds = append(conds, solved)
+	}
+	if f.From != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.From.Format(timeLayout))
+	}
+	if f.To != nil {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.To.Format(timeLayout))
+	}
+	return conds, args
+}
+
+// pendingBefore matches posts with a first response or resolution deadline
+// still to meet before the time given twice as arguments
+const pendingBefore = "((first_response_at IS NULL AND first_response_due <= ?) OR resolution_due <= ?)"
+
+// where joins conditions into a WHERE clause
+func where(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+func (r *MySQLPostRepository) List(ctx context.Context, filter PostFilter) (*PostPage, error) {
+	conds, args := filter.filters()
+
+	// Count every post matching the filters, regardless of the page
+	var total int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM posts"+where(conds), args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count posts: %w", err)
+	}
+
+	// Restrict to posts after the cursor
+	if filter.Cursor != nil {
+		op := "<"
+		if filter.Sort == SortOldest {
+			op = ">"
+		}
+		conds = append(conds, "(created_at "+op+" ? OR (created_at = ? AND id_posts "+op+" ?))")
+		createdAt := filter.Cursor.CreatedAt.Format(timeLayout)
+		args = append(args, createdAt, createdAt, filter.Cursor.ID)
+	}
+
+	var order string
+	switch filter.Sort {
+	case SortOldest:
+		order = " ORDER BY created_at ASC, id_posts ASC"
+	case SortMostCommented:
+		order = " ORDER BY comment_count DESC, created_at DESC, id_posts DESC"
+	default:
+		order = " ORDER BY created_at DESC, id_posts DESC"
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query := "SELECT " + postColumns + " FROM posts" + where(conds) + order + " LIMIT ?"
+	args = append(args, filter.Limit+1)
+	if filter.Cursor == nil {
+		query += " OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query posts: %w", err)
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan post: %w", err)
+		}
+		posts = append(posts, *post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate posts: %w", err)
+	}
+
+	page := &PostPage{Posts: posts, Total: total}
+	if len(posts) > filter.Limit {
+		page.Posts = posts[:filter.Limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
+
+func (r *MySQLPostRepository) Update(ctx context.Context, post *models.Post, editorID int) (*models.PostRevision, error) {
+	if _, err := r.GetByID(ctx, post.ID_Posts); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	revision := &models.PostRevision{ID_post: post.ID_Posts, Title: post.Title, Content: post.Content, ID_editor: &editorID, CreatedAt: now}
+	err := withTx(ctx, r.db, func(ctx context.Context) error {
+		// Lock the post so concurrent edits get consecutive revision numbers
+		var current, version int
+		err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT (SELECT COALESCE(MAX(revision), 0) FROM post_revisions WHERE id_post = posts.id_posts), version FROM posts WHERE id_posts = ? FOR UPDATE",
+			post.ID_Posts).Scan(&current, &version)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("lock post: %w", err)
+		}
+		if version != post.Version {
+			return ErrStale
+		}
+		revision.Revision = current + 1
+
+		_, err = conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET title = ?, content = ?, edited_at = ?, version = version + 1 WHERE id_posts = ?",
+			post.Title, post.Content, now.Format(timeLayout), post.ID_Posts)
+		if err != nil {
+			return fmt.Errorf("update post: %w", err)
+		}
+		_, err = conn(ctx, r.db).ExecContext(ctx, "INSERT INTO post_revisions (id_post, revision, title, content, id_editor, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			revision.ID_post, revision.Revision, revision.Title, revision.Content, editorID, now.Format(timeLayout))
+		if err != nil {
+			return fmt.Errorf("insert revision: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	post.EditedAt = &now
+	post.Version++
+	return revision, nil
+}
+
+// revisionColumns is the column list shared by every revision query
+const revisionColumns = "id_post, revision, title, content, id_editor, created_at"
+
+func scanRevision(row rowScanner) (*models.PostRevision, error) {
+	var revision models.PostRevision
+	var editor sql.NullInt64
+	var createdAtStr string
+	if err := row.Scan(&revision.ID_post, &revision.Revision, &revision.Title, &revision.Content, &editor, &createdAtStr); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if editor.Valid {
+		id := int(editor.Int64)
+		revision.ID_editor = &id
+	}
+	var err error
+	if revision.CreatedAt, err = parseTime(createdAtStr); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	return &revision, nil
+}
+
+func (r *MySQLPostRepository) ListRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT "+revisionColumns+" FROM post_revisions WHERE id_post = ? ORDER BY revision", postID)
+	if err != nil {
+		return nil, fmt.Errorf("query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.PostRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan revision: %w", err)
+		}
+		revisions = append(revisions, *revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *MySQLPostRepository) GetRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	return scanRevision(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+revisionColumns+" FROM post_revisions WHERE id_post = ? AND revision = ?", postID, revision))
+}
+
+func (r *MySQLPostRepository) SetStatus(ctx context.Context, id int, from, to string, actorID int) (*models.StatusChange, error) {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	change := &models.StatusChange{ID_post: id, FromStatus: from, ToStatus: to, ID_actor: &actorID, CreatedAt: now}
+	err := withTx(ctx, r.db, func(ctx context.Context) error {
+		// Only move the post if nobody moved it since its status was read
+		result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET status = ?, status_changed_at = ?, resolved_at = IF(?, COALESCE(resolved_at, ?), NULL) WHERE id_posts = ? AND status = ?",
+			to, now.Format(timeLayout), models.Resolved(to), now.Format(timeLayout), id, from)
+		if err != nil {
+			return fmt.Errorf("update post status: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("read affected rows: %w", err)
+		} else if n == 0 {
+			return ErrStale
+		}
+
+		_, err = conn(ctx, r.db).ExecContext(ctx, "INSERT INTO post_status_changes (id_post, from_status, to_status, id_actor, created_at) VALUES (?, ?, ?, ?, ?)",
+			id, from, to, actorID, now.Format(timeLayout))
+		if err != nil {
+			return fmt.Errorf("insert status change: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (r *MySQLPostRepository) Assign(ctx context.Context, id int, assigneeID *int) error {
+	// Check existence first: MySQL reports 0 affected rows when nothing changed
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET id_assignee = ? WHERE id_posts = ?", assigneeID, id); err != nil {
+		return fmt.Errorf("assign post: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLPostRepository) SetCategory(ctx context.Context, id, categoryID int) error {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET id_category = ? WHERE id_posts = ?", categoryID, id); err != nil {
+		return fmt.Errorf("update post category: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLPostRepository) ListStatusChanges(ctx context.Context, postID int) ([]models.StatusChange, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT id_post, from_status, to_status, id_actor, created_at FROM post_status_changes WHERE id_post = ? ORDER BY id_status_change", postID)
+	if err != nil {
+		return nil, fmt.Errorf("query status changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.StatusChange{}
+	for rows.Next() {
+		var change models.StatusChange
+		var actor sql.NullInt64
+		var createdAtStr string
+		if err := rows.Scan(&change.ID_post, &change.FromStatus, &change.ToStatus, &actor, &createdAtStr); err != nil {
+			return nil, fmt.Errorf("scan status change: %w", err)
+		}
+		if actor.Valid {
+			id := int(actor.Int64)
+			change.ID_actor = &id
+		}
+		if change.CreatedAt, err = parseTime(createdAtStr); err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *MySQLPostRepository) SetPriority(ctx context.Context, id int, priority string, firstResponseDue, resolutionDue time.Time) error {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET priority = ?, first_response_due = ?, resolution_due = ?, first_response_breached_at = NULL, resolution_breached_at = NULL WHERE id_posts = ?",
+		priority, firstResponseDue.UTC().Format(timeLayout), resolutionDue.UTC().Format(timeLayout), id)
+	if err != nil {
+		return fmt.Errorf("update post priority: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLPostRepository) MarkFirstResponse(ctx context.Context, id int, at time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET first_response_at = ? WHERE id_posts = ? AND first_response_at IS NULL",
+		at.UTC().Format(timeLayout), id); err != nil {
+		return fmt.Errorf("mark first response: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLPostRepository) ListSLABreaches(ctx context.Context, now time.Time) ([]models.SLABreach, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT id_posts, ?, first_response_due, id_assignee FROM posts
+		WHERE deleted_at IS NULL AND status IN (?, ?, ?) AND first_response_at IS NULL AND first_response_due <= ? AND first_response_breached_at IS NULL
+		UNION ALL
+		SELECT id_posts, ?, resolution_due, id_assignee FROM posts
+		WHERE deleted_at IS NULL AND status IN (?, ?, ?) AND resolution_due <= ? AND resolution_breached_at IS NULL
+		ORDER BY 3, 1`,
+		models.SLAFirstResponse, models.StatusOpen, models.StatusInProgress, models.StatusAwaitingCustomer, now.UTC().Format(timeLayout),
+		models.SLAResolution, models.StatusOpen, models.StatusInProgress, models.StatusAwaitingCustomer, now.UTC().Format(timeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("query SLA breaches: %w", err)
+	}
+	defer rows.Close()
+
+	var breaches []models.SLABreach
+	for rows.Next() {
+		var breach models.SLABreach
+		var dueStr string
+		var assignee sql.NullInt64
+		if err := rows.Scan(&breach.ID_post, &breach.Kind, &dueStr, &assignee); err != nil {
+			return nil, fmt.Errorf("scan SLA breach: %w", err)
+		}
+		if breach.Due, err = parseTime(dueStr); err != nil {
+			return nil, fmt.Errorf("parse due: %w", err)
+		}
+		if assignee.Valid {
+			id := int(assignee.Int64)
+			breach.ID_assignee = &id
+		}
+		breaches = append(breaches, breach)
+	}
+	return breaches, rows.Err()
+}
+
+func (r *MySQLPostRepository) FlagSLABreach(ctx context.Context, id int, kind string, at time.Time) error {
+	column := "first_response_breached_at"
+	if kind == models.SLAResolution {
+		column = "resolution_breached_at"
+	}
+	result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET "+column+" = ? WHERE id_posts = ? AND "+column+" IS NULL",
+		at.UTC().Format(timeLayout), id)
+	if err != nil {
+		return fmt.Errorf("flag SLA breach: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("read affected rows: %w", err)
+	} else if n == 0 {
+		return ErrStale
+	}
+	return nil
+}
+
+func (r *MySQLPostRepository) Delete(ctx context.Context, id int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET deleted_at = ? WHERE id_posts = ? AND deleted_at IS NULL",
+		time.Now().UTC().Format(timeLayout), id)
+	if err != nil {
+		return fmt.Errorf("delete post: %w", err)
+	}
+	return checkAffected(result)
+}
+
+func (r *MySQLPostRepository) Restore(ctx context.Context, id int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE posts SET deleted_at = NULL WHERE id_posts = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("restore post: %w", err)
+	}
+	return checkAffected(result)
+}
+
+func (r *MySQLPostRepository) ListDeletedBefore(ctx context.Context, cutoff time.Time) ([]int, error) {
+	ids, err := queryIDs(ctx, r.db, "SELECT id_posts FROM posts WHERE deleted_at < ? ORDER BY id_posts", cutoff.UTC().Format(timeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("query deleted posts: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *MySQLPostRepository) Purge(ctx context.Context, id int) error {
+	// Comments, revisions and attachment rows are removed by the id_post foreign key cascades
+	result, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM posts WHERE id_posts = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("purge post: %w", err)
+	}
+	return checkAffected(result)
+}
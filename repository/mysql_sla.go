@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MySQLSLAPolicyRepository stores the default SLA policies in the
+// sla_policies table and the overrides of categories in category_sla_policies
+type MySQLSLAPolicyRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLSLAPolicyRepository creates an SLA policy repository backed by the given database
+func NewMySQLSLAPolicyRepository(db *sql.DB) *MySQLSLAPolicyRepository {
+	return &MySQLSLAPolicyRepository{db: db}
+}
+
+// slaPolicyQuery selects the policies in effect in the category given as the
+// first argument, preferring its overrides to the defaults
+const slaPolicyQuery = `SELECT overrides.id_category, defaults.priority,
+	COALESCE(overrides.first_response_minutes, defaults.first_response_minutes),
+	COALESCE(overrides.resolution_minutes, defaults.resolution_minutes)
+	FROM sla_policies defaults
+	LEFT JOIN category_sla_policies overrides ON overrides.priority = defaults.priority AND overrides.id_category = ?`
+
+func scanSLAPolicy(row rowScanner) (*models.SLAPolicy, error) {
+	var policy models.SLAPolicy
+	var category sql.NullInt64
+	if err := row.Scan(&category, &policy.Priority, &policy.FirstResponseMinutes, &policy.ResolutionMinutes); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if category.Valid {
+		id := int(category.Int64)
+		policy.ID_category = &id
+	}
+	return &policy, nil
+}
+
+func (r *MySQLSLAPolicyRepository) List(ctx context.Context, categoryID int) ([]models.SLAPolicy, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, slaPolicyQuery+" ORDER BY FIELD(defaults.priority, ?, ?, ?, ?)",
+		categoryID, models.PriorityLow, models.PriorityNormal, models.PriorityHigh, models.PriorityUrgent)
+	if err != nil {
+		return nil, fmt.Errorf("query SLA policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []models.SLAPolicy{}
+	for rows.Next() {
+		policy, err := scanSLAPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan SLA policy: %w", err)
+		}
+		policies = append(policies, *policy)
+	}
+	return policies, rows.Err()
+}
+
+func (r *MySQLSLAPolicyRepository) Get(ctx context.Context, categoryID int, priority string) (*models.SLAPolicy, error) {
+	policy, err := scanSLAPolicy(conn(ctx, r.db).QueryRowContext(ctx, slaPolicyQuery+" WHERE defaults.priority = ?", categoryID, priority))
+	if err != nil && err != ErrNotFound {
+		return nil, fmt.Errorf("query SLA policy: %w", err)
+	}
+	return policy, err
+}
+
+func (r *MySQLSLAPolicyRepository) Update(ctx context.Context, policy *models.SLAPolicy) error {
+	// Check existence first: MySQL reports 0 affected rows when nothing changed
+	if _, err := r.Get(ctx, 0, policy.Priority); err != nil {
+		return err
+	}
+
+	if policy.ID_category == nil {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE sla_policies SET first_response_minutes = ?, resolution_minutes = ? WHERE priority = ?",
+			policy.FirstResponseMinutes, policy.ResolutionMinutes, policy.Priority); err != nil {
+			return fmt.Errorf("update SLA policy: %w", err)
+		}
+		return nil
+	}
+
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id_category = ?)", *policy.ID_category).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("query category: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `INSERT INTO category_sla_policies (id_category, priority, first_response_minutes, resolution_minutes) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE first_response_minutes = VALUES(first_response_minutes), resolution_minutes = VALUES(resolution_minutes)`,
+		*policy.ID_category, policy.Priority, policy.FirstResponseMinutes, policy.ResolutionMinutes); err != nil {
+		return fmt.Errorf("save category SLA policy: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLSLAPolicyRepository) DeleteOverride(ctx context.Context, categoryID int, priority string) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM category_sla_policies WHERE id_category = ? AND priority = ?", categoryID, priority)
+	if err != nil {
+		return fmt.Errorf("delete category SLA policy: %w", err)
+	}
+	return checkAffected(result)
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MySQLTagRepository stores tags in the tags and post_tags tables
+type MySQLTagRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLTagRepository creates a tag repository backed by the given database
+func NewMySQLTagRepository(db *sql.DB) *MySQLTagRepository {
+	return &MySQLTagRepository{db: db}
+}
+
+// tagCounts selects tags with the number of live posts carrying them
+const tagCounts = "SELECT tags.id_tag, tags.name, COUNT(posts.id_posts) AS post_count FROM tags " +
+	"LEFT JOIN post_tags ON post_tags.id_tag = tags.id_tag " +
+	"LEFT JOIN posts ON posts.id_posts = post_tags.id_post AND posts.deleted_at IS NULL"
+
+func (r *MySQLTagRepository) List(ctx context.Context, prefix string, limit int) ([]models.Tag, error) {
+	// Tags are plain words, but escape LIKE wildcards all the same
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+	rows, err := conn(ctx, r.db).QueryContext(ctx, tagCounts+" WHERE tags.name LIKE ? GROUP BY tags.id_tag, tags.name HAVING post_count > 0 ORDER BY post_count DESC, tags.name LIMIT ?",
+		pattern, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID_tag, &tag.Name, &tag.PostCount); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (r *MySQLTagRepository) GetByName(ctx context.Context, name string) (*models.Tag, error) {
+	var tag models.Tag
+	err := conn(ctx, r.db).QueryRowContext(ctx, tagCounts+" WHERE tags.name = ? GROUP BY tags.id_tag, tags.name", name).Scan(&tag.ID_tag, &tag.Name, &tag.PostCount)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("query tag: %w", err)
+	}
+	return &tag, nil
+}
+
+// createTag inserts the tag unless it exists and returns its ID
+func (r *MySQLTagRepository) createTag(ctx context.Context, name string, now time.Time) (int, error) {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "INSERT IGNORE INTO tags (name, created_at) VALUES (?, ?)", name, now.Format(timeLayout))
+	if err != nil {
+		return 0, fmt.Errorf("insert tag: %w", err)
+	}
+	var id int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT id_tag FROM tags WHERE name = ?", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("query tag id: %w", err)
+	}
+	return id, nil
+}
+
+func (r *MySQLTagRepository) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		// Lock the post so concurrent updates do not interleave their tags
+		var id int
+		err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT id_posts FROM posts WHERE id_posts = ? AND deleted_at IS NULL FOR UPDATE", postID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("lock post: %w", err)
+		}
+
+		if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM post_tags WHERE id_post = ?", postID); err != nil {
+			return fmt.Errorf("delete post tags: %w", err)
+		}
+		for _, name := range tags {
+			tagID, err := r.createTag(ctx, name, now)
+			if err != nil {
+				return err
+			}
+			if _, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO post_tags (id_post, id_tag) VALUES (?, ?)", postID, tagID); err != nil {
+				return fmt.Errorf("insert post tag: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *MySQLTagRepository) Merge(ctx context.Context, from, into string) (int, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	var moved int
+	err := withTx(ctx, r.db, func(ctx context.Context) error {
+		var fromID int
+		err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT id_tag FROM tags WHERE name = ? FOR UPDATE", from).Scan(&fromID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("lock tag: %w", err)
+		}
+		intoID, err := r.createTag(ctx, into, now)
+		if err != nil {
+			return err
+		}
+
+		if err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM post_tags WHERE id_tag = ?", fromID).Scan(&moved); err != nil {
+			return fmt.Errorf("count tagged posts: %w", err)
+		}
+		// Posts carrying both tags keep a single one
+		_, err = conn(ctx, r.db).ExecContext(ctx, "INSERT IGNORE INTO post_tags (id_post, id_tag) SELECT id_post, ? FROM post_tags WHERE id_tag = ?", intoID, fromID)
+		if err != nil {
+			return fmt.Errorf("retag posts: %w", err)
+		}
+		// Deleting the tag removes its post_tags rows by cascade
+		if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM tags WHERE id_tag = ?", fromID); err != nil {
+			return fmt.Errorf("delete tag: %w", err)
+		}
+		return nil
+	})
+	return moved, err
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLUserRepository stores users in the users table
+type MySQLUserRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLUserRepository creates a user repository backed by the given database
+func NewMySQLUserRepository(db *sql.DB) *MySQLUserRepository {
+	return &MySQLUserRepository{db: db}
+}
+
+const userColumns = "id_user, email, name, phone, profile_picture, role, version, deleted_at"
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var deletedAtStr sql.NullString
+	if err := row.Scan(&user.ID_user, &user.Email, &user.Name, &user.Phone, &user.Picture, &user.Role, &user.Version, &deletedAtStr); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var err error
+	if user.DeletedAt, err = parseNullTime(deletedAtStr); err != nil {
+		return nil, fmt.Errorf("parse deleted_at: %w", err)
+	}
+	return &user, nil
+}
+
+// queryUsers runs a query selecting userColumns
+func (r *MySQLUserRepository) queryUsers(ctx context.Context, query string, args ...any) ([]models.User, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (r *MySQLUserRepository) Create(ctx context.Context, user *models.User) error {
+	// Let MySQL generate the ID unless one is given
+	var id any
+	if user.ID_user != 0 {
+		id = user.ID_user
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO users (id_user, email, name, phone, profile_picture, role) VALUES (?, ?, ?, ?, ?, ?)",
+		id, user.Email, user.Name, user.Phone, user.Picture, user.Role)
+	if isDuplicateEntry(err) {
+		return ErrConflict
+	} else if err != nil {
+		return fmt.Errorf("insert user: %w", err)
+	}
+
+	if user.ID_user == 0 {
+		newID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("read new user id: %w", err)
+		}
+		user.ID_user = int(newID)
+	}
+	user.Version = 1
+	return nil
+}
+
+func (r *MySQLUserRepository) List(ctx context.Context) ([]models.User, error) {
+	return r.queryUsers(ctx, "SELECT "+userColumns+" FROM users WHERE deleted_at IS NULL ORDER BY id_user")
+}
+
+func (r *MySQLUserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	return scanUser(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id_user = ? AND deleted_at IS NULL", id))
+}
+
+func (r *MySQLUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return scanUser(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = ? AND deleted_at IS NULL", email))
+}
+
+func (r *MySQLUserRepository) Update(ctx context.Context, user *models.User) error {
+	// Check existence first so that no affected row means a stale version
+	if _, err := r.GetByID(ctx, user.ID_user); err != nil {
+		return err
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE users SET email = ?, name = ?, phone = ?, version = version + 1 WHERE id_user = ? AND version = ?",
+		user.Email, user.Name, user.Phone, user.ID_user, user.Version)
+	if isDuplicateEntry(err) {
+		return ErrConflict
+	} else if err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("read affected rows: %w", err)
+	} else if n == 0 {
+		return ErrStale
+	}
+	user.Version++
+	return nil
+}
+
+func (r *MySQLUserRepository) UpdateRole(ctx context.Context, id int, role string) error {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE users SET role = ?, version = version + 1 WHERE id_user = ?", role, id); err != nil {
+		return fmt.Errorf("update user role: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLUserRepository) SetPicture(ctx context.Context, id int, picture *string) error {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE users SET profile_picture = ?, version = version + 1 WHERE id_user = ?", picture, id); err != nil {
+		return fmt.Errorf("update profile picture: %w", err)
+	}
+	return nil
+}
+
+func (r *MySQLUserRepository) Delete(ctx context.Context, id int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE users SET deleted_at = ? WHERE id_user = ? AND deleted_at IS NULL",
+		time.Now().UTC().Format(timeLayout), id)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	return checkAffected(result)
+}
+
+func (r *MySQLUserRepository) Restore(ctx context.Context, id int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE users SET deleted_at = NULL WHERE id_user = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("restore user: %w", err)
+	}
+	return checkAffected(result)
+}
+
+func (r *MySQLUserRepository) ListDeleted(ctx context.Context) ([]models.User, error) {
+	return r.queryUsers(ctx, "SELECT "+userColumns+" FROM users WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id_user DESC")
+}
+
+func (r *MySQLUserRepository) Purge(ctx context.Context, id int) error {
+	// Posts, comments and attachment rows are removed by the id_user foreign key cascades
+	result, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM users WHERE id_user = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("purge user: %w", err)
+	}
+	return checkAffected(result)
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a unique constraint would be violated
+	ErrConflict = errors.New("conflict")
+	// ErrStale is returned when a row was changed since the version being
+	// updated was read
+	ErrStale = errors.New("stale version")
+	// ErrInUse is returned when a row cannot be removed while others refer to it
+	ErrInUse = errors.New("in use")
+)
+
+// UserRepository stores users
+type UserRepository interface {
+	// Create inserts the user; a zero ID_user is filled with the generated ID
+	Create(ctx context.Context, user *models.User) error
+	List(ctx context.Context) ([]models.User, error)
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// Update saves the email, name and phone of the user unless its Version
+	// is no longer current, and increments Version
+	Update(ctx context.Context, user *models.User) error
+	// UpdateRole and SetPicture increment the version without checking it
+	UpdateRole(ctx context.Context, id int, role string) error
+	// SetPicture stores the profile picture path, nil clears it
+	SetPicture(ctx context.Context, id int, picture *string) error
+
+	// Delete moves the user to the trash; the other methods above treat
+	// trashed users as not found
+	Delete(ctx context.Context, id int) error
+	// Restore takes a trashed user out of the trash
+	Restore(ctx context.Context, id int) error
+	// ListDeleted returns the trashed users, most recently deleted first
+	ListDeleted(ctx context.Context) ([]models.User, error)
+	// Purge permanently removes a trashed user together with their posts,
+	// comments and attachment rows
+	Purge(ctx context.Context, id int) error
+}
+
+// Post sort orders
+const (
+	SortNewest        = "newest"
+	SortOldest        = "oldest"
+	SortMostCommented = "most_commented"
+)
+
+// SLA states of a post listing
+const (
+	SLABreached = "breached" // A pending deadline has passed
+	SLAAtRisk   = "at_risk"  // A pending deadline passes within PostFilter.AtRiskWindow
+)
+
+// PostCursor marks the last post of a page for keyset pagination
+type PostCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// PostFilter holds the pagination, sorting and filter options of a post listing
+type PostFilter struct {
+	Limit  int
+	Offset int         // Fallback pagination, ignored when Cursor is set
+	Cursor *PostCursor // Keyset pagination, only for the newest and oldest sorts
+	Sort   string
+	UserID int        // Only posts by this user when non-zero
+	From   *time.Time // Inclusive lower bound on created_at
+	To     *time.Time // Exclusive upper bound on created_at
+	Trash  bool       // List the trashed posts instead of the live ones
+
+	Statuses   []string // Only posts in one of these ticket statuses when set
+	AssigneeID int      // Only posts assigned to this user when non-zero
+	Unassigned bool     // Only posts nobody is assigned to
+
+	SLA          string        // Only posts in this SLA state when set, judged at the current time
+	AtRiskWindow time.Duration // How close a deadline makes a post at risk
+
+	// Only posts in the category with this ID or slug, or in one of its
+	// subcategories, when set
+	CategoryID   int
+	CategorySlug string
+
+	Tag    string // Only posts carrying this tag when set
+	Solved *bool  // Only posts with, or without, an accepted answer when set
+}
+
+// PostPage is one page of a post listing
+type PostPage struct {
+	Posts   []models.Post
+	Total   int  // Posts matching the filter across all pages
+	HasMore bool // Another page follows
+}
+
+// PostRepository stores forum posts
+type PostRepository interface {
+	// Create inserts the post as its first revision and fills its ID and
+	// creation time
+	Create(ctx context.Context, post *models.Post) error
+	GetByID(ctx context.Context, id int) (*models.Post, error)
+	List(ctx context.Context, filter PostFilter) (*PostPage, error)
+	// Update saves the title and content of the post as a new revision by
+	// the editor unless its Version is no longer current, increments Version,
+	// sets EditedAt and returns the revision
+	Update(ctx context.Context, post *models.Post, editorID int) (*models.PostRevision, error)
+	// ListRevisions returns every revision of the post, oldest first
+	ListRevisions(ctx context.Context, postID int) ([]models.PostRevision, error)
+	GetRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error)
+
+	// SetStatus moves the post to a new ticket status unless its status is
+	// no longer from, sets StatusChangedAt, sets SLA.ResolvedAt when resolving
+	// or closing and clears it otherwise, and records the change by the actor
+	SetStatus(ctx context.Context, id int, from, to string, actorID int) (*models.StatusChange, error)
+	// Assign sets the agent working on the post, nil unassigns it
+	Assign(ctx context.Context, id int, assigneeID *int) error
+	// SetCategory files the post under another category
+	SetCategory(ctx context.Context, id, categoryID int) error
+	// ListStatusChanges returns the status history of the post, oldest first
+	ListStatusChanges(ctx context.Context, postID int) ([]models.StatusChange, error)
+
+	// SetPriority changes the priority and SLA deadlines of the post and
+	// clears its breaches, which are flagged again if still missed
+	SetPriority(ctx context.Context, id int, priority string, firstResponseDue, resolutionDue time.Time) error
+	// MarkFirstResponse records the first staff response unless one was already recorded
+	MarkFirstResponse(ctx context.Context, id int, at time.Time) error
+	// ListSLABreaches returns the missed deadlines of unresolved posts that
+	// are not flagged yet
+	ListSLABreaches(ctx context.Context, now time.Time) ([]models.SLABreach, error)
+	// FlagSLABreach marks a deadline of the post as breached, or returns
+	// ErrStale when it already is
+	FlagSLABreach(ctx context.Context, id int, kind string, at time.Time) error
+
+	// Delete moves the post to the trash; the other methods above treat
+	// trashed posts as not found, except List with PostFilter.Trash
+	Delete(ctx context.Context, id int) error
+	// Restore takes a trashed post out of the trash
+	Restore(ctx context.Context, id int) error
+	// ListDeletedBefore returns the IDs of the posts trashed before the cutoff
+	ListDeletedBefore(ctx context.Context, cutoff time.Time) ([]int, error)
+	// Purge permanently removes a trashed post together with its comments,
+	// revisions and attachment rows
+	Purge(ctx context.Context, id int) error
+}
+
+// Comment list views
+const (
+	CommentViewTree = "tree"
+	CommentViewFlat = "flat"
+)
+
+// CommentFilter holds the pagination options of a comment listing
+type CommentFilter struct {
+	View  string // In tree view only top-level comments count towards Limit
+	Page  int
+	Limit int
+}
+
+// CommentRepository stores comments on posts
+type CommentRepository interface {
+	// Create inserts the comment and fills its ID and creation time
+	Create(ctx context.Context, comment *models.Comment) error
+	// GetByID returns the comment when it belongs to the given post
+	GetByID(ctx context.Context, postID, commentID int) (*models.Comment, error)
+	// List returns the comments of a page ordered so parents precede their
+	// replies, plus the number of comments being paginated
+	List(ctx context.Context, postID int, filter CommentFilter) ([]*models.Comment, int, error)
+	UpdateContent(ctx context.Context, commentID int, content string) error
+	// Delete removes the comment together with its replies
+	Delete(ctx context.Context, commentID int) error
+	// SetAccepted marks the comment as the accepted answer of the post in
+	// place of any other, or only unmarks the current one when commentID is
+	// nil; it returns ErrNotFound when the comment is not on the post
+	SetAccepted(ctx context.Context, postID int, commentID *int) error
+}
+
+// AttachmentRepository stores the metadata of files attached to posts; the
+// files themselves live in storage
+type AttachmentRepository interface {
+	// Create inserts the attachment and fills its ID and creation time
+	Create(ctx context.Context, attachment *models.Attachment) error
+	// GetByID returns the attachment when it belongs to the given post
+	GetByID(ctx context.Context, postID, attachmentID int) (*models.Attachment, error)
+	// ListByPost returns the attachments of a post, oldest first
+	ListByPost(ctx context.Context, postID int) ([]models.Attachment, error)
+	// ListByPostLocked is ListByPost locking the post until the transaction
+	// ends, so concurrent uploads see each other's files; it returns
+	// ErrNotFound when the post is missing or in the trash
+	ListByPostLocked(ctx context.Context, postID int) ([]models.Attachment, error)
+	// ListOwnedBy returns the attachments uploaded by the user or on their
+	// posts, which are removed when the user is purged
+	ListOwnedBy(ctx context.Context, userID int) ([]models.Attachment, error)
+	Delete(ctx context.Context, attachmentID int) error
+}
+
+// Transactor runs functions in a transaction spanning the repositories
+type Transactor interface {
+	// WithinTx runs fn with a context carrying a transaction; repository
+	// calls made with that context are committed together when fn returns
+	// nil and rolled back otherwise
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// AuditFilter holds the pagination and filter options of an audit log listing
+type AuditFilter struct {
+	Limit      int
+	Offset     int
+	ActorID    int        // Only entries by this user when non-zero
+	Action     string     // Only this action when set
+	TargetType string     // Only this target type when set
+	TargetID   int        // Only this target when non-zero
+	From       *time.Time // Inclusive lower bound on created_at
+	To         *time.Time // Exclusive upper bound on created_at
+}
+
+// CategoryRepository stores the category tree
+type CategoryRepository interface {
+	// Create inserts the category and fills its ID and creation time, or
+	// returns ErrConflict when the slug is taken
+	Create(ctx context.Context, category *models.Category) error
+	// List returns every category with its PostCount, ordered by SortOrder
+	// then name
+	List(ctx context.Context) ([]models.Category, error)
+	GetByID(ctx context.Context, id int) (*models.Category, error)
+	// Update saves the parent, slug, name, description and order of the
+	// category, or returns ErrConflict when the slug is taken
+	Update(ctx context.Context, category *models.Category) error
+	// Delete removes the category, or returns ErrInUse while it has
+	// subcategories or posts, including trashed ones
+	Delete(ctx context.Context, id int) error
+}
+
+// SLAPolicyRepository stores the SLA policy of each priority, which a
+// category can override
+type SLAPolicyRepository interface {
+	// List returns the policies in effect in the category, or the defaults
+	// for category 0, from the lowest to the highest priority
+	List(ctx context.Context, categoryID int) ([]models.SLAPolicy, error)
+	// Get returns the policy of the priority in effect in the category
+	Get(ctx context.Context, categoryID int, priority string) (*models.SLAPolicy, error)
+	// Update saves the targets of an existing priority, as the default when
+	// the policy has no category and as an override of its category otherwise
+	Update(ctx context.Context, policy *models.SLAPolicy) error
+	// DeleteOverride drops the override of the priority in the category
+	DeleteOverride(ctx context.Context, categoryID int, priority string) error
+}
+
+// TagRepository stores the tags of posts; posts read their Tags through
+// PostRepository
+type TagRepository interface {
+	// List returns at most limit tags starting with prefix that are on a post
+	// outside the trash, the most used first
+	List(ctx context.Context, prefix string, limit int) ([]models.Tag, error)
+	// GetByName returns the tag with its PostCount
+	GetByName(ctx context.Context, name string) (*models.Tag, error)
+	// SetPostTags replaces the tags of the post with the normalized tags,
+	// creating the ones that do not exist yet
+	SetPostTags(ctx context.Context, postID int, tags []string) error
+	// Merge moves the tag from onto every post carrying it as the tag into,
+	// which is created when missing so that merging renames, then deletes
+	// from; it returns how many posts carried from
+	Merge(ctx context.Context, from, into string) (int, error)
+}
+
+// AuditRepository appends to and reads the audit log; entries are never
+// changed or removed
+type AuditRepository interface {
+	// Record inserts the entry and fills its ID and creation time
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	// List returns a page of matching entries, newest first, and the number
+	// of entries matching the filter
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, int, error)
+}
@@ -0,0 +1,242 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"net/http"
+	"testing"
+)
+
+// addComment adds a comment to a seeded post and returns its ID
+func addComment(t *testing.T, e *testEnv, postID, userID int, content string) int {
+	t.Helper()
+	comment := models.Comment{ID_post: postID, ID_user: userID, Content: content}
+	if err := e.store.Comments().Create(context.Background(), &comment); err != nil {
+		t.Fatal(err)
+	}
+	return comment.ID_comment
+}
+
+// accept marks a comment as the accepted answer of a seeded post
+func accept(t *testing.T, e *testEnv, postID, commentID int) {
+	t.Helper()
+	if err := e.store.Comments().SetAccepted(context.Background(), postID, &commentID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wantAccepted fails unless the stored post has the accepted answer, 0 for none
+func wantAccepted(t *testing.T, e *testEnv, postID, commentID int) {
+	t.Helper()
+	post, err := e.store.Posts().GetByID(context.Background(), postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commentID == 0 && (post.ID_accepted != nil || post.Solved) {
+		t.Errorf("post %d is solved, want no accepted answer", postID)
+	}
+	if commentID != 0 && (post.ID_accepted == nil || *post.ID_accepted != commentID || !post.Solved) {
+		t.Errorf("post %d is not solved by comment %d", postID, commentID)
+	}
+}
+
+func TestSetAcceptedAnswer(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by author",
+			req:    apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": 1}, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "solved", true)
+				wantAccepted(t, e, 1, 1)
+				entry := wantAudit(t, e, models.AuditPostAcceptAnswer, 1, alice)
+				if auditField(t, entry.Before, "solved") != false || auditField(t, entry.After, "id_accepted_comment") != float64(1) {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name:   "replaces the accepted answer",
+			setup:  func(t *testing.T, e *testEnv) { accept(t, e, 1, 1); addComment(t, e, 1, agent, "Retry after 24 hours") },
+			req:    apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": 2}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantAccepted(t, e, 1, 2)
+				if comment, _ := e.store.Comments().GetByID(context.Background(), 1, 1); comment.AcceptedAt != nil {
+					t.Error("previous answer still accepted")
+				}
+			},
+		},
+		{
+			name:   "unmarked",
+			setup:  func(t *testing.T, e *testEnv) { accept(t, e, 1, 1) },
+			req:    apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": nil}, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "solved", false)
+				wantAccepted(t, e, 1, 0)
+			},
+		},
+		{
+			name:   "comment on another post",
+			setup:  func(t *testing.T, e *testEnv) { addComment(t, e, 2, agent, "Call the bank") },
+			req:    apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": 2}, as: alice},
+			status: http.StatusBadRequest,
+		},
+		{name: "unknown comment", req: apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": 99}, as: alice}, status: http.StatusBadRequest},
+		{name: "by another customer", req: apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": 1}, as: bob}, status: http.StatusForbidden},
+		{name: "post not found", req: apiRequest{method: "PUT", path: "/posts/99/accepted-answer", body: map[string]any{"id_comment": 1}, as: agent}, status: http.StatusNotFound},
+		{name: "invalid body", req: apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: "{", as: alice}, status: http.StatusBadRequest},
+		{name: "anonymous", req: apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": 1}}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Comments.SetAccepted"}, req: apiRequest{method: "PUT", path: "/posts/1/accepted-answer", body: map[string]any{"id_comment": 1}, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetPostWithAcceptedAnswer(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "accepted answer first",
+			setup:  func(t *testing.T, e *testEnv) { accept(t, e, 1, 1) },
+			req:    apiRequest{method: "GET", path: "/posts/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				post := decode[struct {
+					Solved         bool           `json:"solved"`
+					AcceptedAnswer map[string]any `json:"accepted_answer"`
+				}](t, body)
+				if !post.Solved || post.AcceptedAnswer["id_comment"] != float64(1) || post.AcceptedAnswer["accepted"] != true {
+					t.Errorf("unexpected post: %s", body)
+				}
+			},
+		},
+		{
+			name:   "unsolved",
+			req:    apiRequest{method: "GET", path: "/posts/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "solved", false)
+				if _, ok := decode[map[string]any](t, body)["accepted_answer"]; ok {
+					t.Errorf("unexpected accepted answer: %s", body)
+				}
+			},
+		},
+		{name: "answer lookup error", fail: []string{"Comments.GetByID"}, setup: func(t *testing.T, e *testEnv) { accept(t, e, 1, 1) }, req: apiRequest{method: "GET", path: "/posts/1"}, status: http.StatusInternalServerError},
+		{
+			name: "answer deleted",
+			setup: func(t *testing.T, e *testEnv) {
+				accept(t, e, 1, 1)
+				if err := e.store.Comments().Delete(context.Background(), 1); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    apiRequest{method: "GET", path: "/posts/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "solved", false)
+			},
+		},
+	})
+}
+
+func TestListSolvedPosts(t *testing.T) {
+	solvedFirst := func(t *testing.T, e *testEnv) { accept(t, e, 1, 1) }
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "solved flag",
+			setup:  solvedFirst,
+			req:    apiRequest{method: "GET", path: "/posts?sort=oldest"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				page := decode[struct {
+					Data []struct {
+						Solved bool `json:"solved"`
+					} `json:"data"`
+				}](t, body)
+				if len(page.Data) != 2 || !page.Data[0].Solved || page.Data[1].Solved {
+					t.Errorf("unexpected page: %s", body)
+				}
+			},
+		},
+		{
+			name:   "solved only",
+			setup:  solvedFirst,
+			req:    apiRequest{method: "GET", path: "/posts?solved=true"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if page := decode[ticketPage](t, body); page.Total != 1 || page.Data[0].ID_Posts != 1 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{
+			name:   "unsolved only",
+			setup:  solvedFirst,
+			req:    apiRequest{method: "GET", path: "/posts?solved=false"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if page := decode[ticketPage](t, body); page.Total != 1 || page.Data[0].ID_Posts != 2 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{name: "invalid filter", req: apiRequest{method: "GET", path: "/posts?solved=maybe"}, status: http.StatusBadRequest},
+	})
+}
+
+func TestSearchRanksSolvedThreads(t *testing.T) {
+	// Post 3 matches "card" as well as post 2 and is newer, so it comes
+	// first unless post 2 is solved
+	twoCardPosts := func(t *testing.T, e *testEnv) {
+		post := models.Post{Title: "Card expired", Content: "My card expired yesterday", ID_user: alice, ID_category: general}
+		if err := e.store.Posts().Create(context.Background(), &post); err != nil {
+			t.Fatal(err)
+		}
+		addComment(t, e, 2, agent, "Call the bank to unblock it")
+	}
+	firstHit := func(want int, solved bool) func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+		return func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+			result := decode[struct {
+				Data []struct {
+					PostID int  `json:"id_posts"`
+					Solved bool `json:"solved"`
+				} `json:"data"`
+			}](t, body)
+			if len(result.Data) == 0 || result.Data[0].PostID != want || result.Data[0].Solved != solved {
+				t.Errorf("unexpected result: %s", body)
+			}
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{name: "newest first when equally relevant", setup: twoCardPosts, req: apiRequest{method: "GET", path: "/posts/search?q=card"}, status: http.StatusOK, check: firstHit(3, false)},
+		{
+			name:   "solved thread first",
+			setup:  func(t *testing.T, e *testEnv) { twoCardPosts(t, e); accept(t, e, 2, 2) },
+			req:    apiRequest{method: "GET", path: "/posts/search?q=card"},
+			status: http.StatusOK,
+			check:  firstHit(2, true),
+		},
+		{
+			name:   "only solved threads",
+			setup:  func(t *testing.T, e *testEnv) { twoCardPosts(t, e); accept(t, e, 2, 2) },
+			req:    apiRequest{method: "GET", path: "/posts/search?q=card&solved=true"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 1)
+				firstHit(2, true)(t, e, resp, body)
+			},
+		},
+		{
+			name:   "only open threads",
+			setup:  func(t *testing.T, e *testEnv) { twoCardPosts(t, e); accept(t, e, 2, 2) },
+			req:    apiRequest{method: "GET", path: "/posts/search?q=card&solved=false"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 1)
+				firstHit(3, false)(t, e, resp, body)
+			},
+		},
+		{name: "invalid solved filter", req: apiRequest{method: "GET", path: "/posts/search?q=card&solved=maybe"}, status: http.StatusBadRequest},
+	})
+}
@@ -0,0 +1,288 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPDF returns a minimal PDF padded to the given size
+func testPDF(size int) []byte {
+	data := []byte("%PDF-1.4\n%%EOF\n")
+	return append(data, bytes.Repeat([]byte(" "), max(size-len(data), 0))...)
+}
+
+func pngFile(t *testing.T, name string) multipartFile {
+	return multipartFile{field: "file", filename: name, data: testPNG(t)}
+}
+
+func pdfFile(name string, size int) multipartFile {
+	return multipartFile{field: "file", filename: name, data: testPDF(size)}
+}
+
+// attach uploads a file to a post and returns the created attachment
+func attach(t *testing.T, e *testEnv, postID, as int, file multipartFile) models.Attachment {
+	t.Helper()
+	resp, body := e.do(t, apiRequest{method: "POST", path: fmt.Sprintf("/posts/%d/attachments", postID), body: file, as: as})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("attach failed: %d %s", resp.StatusCode, body)
+	}
+	return decode[models.Attachment](t, body)
+}
+
+// storedAttachments lists the files kept in storage for a post
+func storedAttachments(e *testEnv, postID int) []string {
+	files, _ := filepath.Glob(filepath.Join(e.uploadDir, "attachments", fmt.Sprint(postID), "*"))
+	return files
+}
+
+func TestUploadAttachment(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "image by author",
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, `C:\Users\alice\failed transfer.gif`), as: alice},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "file_name", "failed transfer.png")
+				wantField(t, body, "content_type", "image/png")
+				wantField(t, body, "id_user", alice)
+				if url := decode[models.Attachment](t, body).URL; !strings.HasPrefix(url, "/files/attachments/1/") {
+					t.Errorf("url = %q", url)
+				}
+				if files := storedAttachments(e, 1); len(files) != 1 {
+					t.Errorf("stored files: %v", files)
+				}
+			},
+		},
+		{
+			name:   "pdf",
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: pdfFile("statement", 100), as: alice},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "file_name", "statement.pdf")
+				wantField(t, body, "content_type", "application/pdf")
+				wantField(t, body, "size", 100)
+			},
+		},
+		{name: "by moderator", req: apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, "x.png"), as: mod}, status: http.StatusCreated},
+		{name: "by another customer", req: apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, "x.png"), as: bob}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, "x.png")}, status: http.StatusUnauthorized},
+		{name: "invalid post id", req: apiRequest{method: "POST", path: "/posts/abc/attachments", body: pngFile(t, "x.png"), as: alice}, status: http.StatusBadRequest},
+		{name: "post not found", req: apiRequest{method: "POST", path: "/posts/99/attachments", body: pngFile(t, "x.png"), as: alice}, status: http.StatusNotFound},
+		{name: "no file", req: apiRequest{method: "POST", path: "/posts/1/attachments", body: multipartFile{field: "other", filename: "x.png"}, as: alice}, status: http.StatusBadRequest},
+		{
+			name:   "too large",
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: pdfFile("big.pdf", testMaxAttachment+1), as: alice},
+			status: http.StatusRequestEntityTooLarge,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "file_too_large")
+			},
+		},
+		{
+			name:   "unsupported type",
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: multipartFile{field: "file", filename: "x.pdf", data: []byte("<html><script>alert(1)</script>")}, as: alice},
+			status: http.StatusUnsupportedMediaType,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "unsupported_type")
+			},
+		},
+		{
+			name:   "corrupt image",
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: multipartFile{field: "file", filename: "x.png", data: testPNG(t)[:30]}, as: alice},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "too many files",
+			setup: func(t *testing.T, e *testEnv) {
+				for range testMaxAttachments {
+					attach(t, e, 1, alice, pngFile(t, "x.png"))
+				}
+			},
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, "x.png"), as: alice},
+			status: http.StatusConflict,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "too_many_attachments")
+			},
+		},
+		{
+			name: "total size quota",
+			setup: func(t *testing.T, e *testEnv) {
+				attach(t, e, 1, alice, pdfFile("a.pdf", testMaxAttachment))
+				attach(t, e, 1, alice, pdfFile("b.pdf", testMaxAttachment))
+			},
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: pdfFile("c.pdf", testMaxAttachmentTotal-2*testMaxAttachment+1), as: alice},
+			status: http.StatusConflict,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "attachment_quota_exceeded")
+			},
+		},
+		{
+			name:   "database error leaves no file behind",
+			fail:   []string{"Attachments.Create"},
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, "x.png"), as: alice},
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if files := storedAttachments(e, 1); len(files) != 0 {
+					t.Errorf("orphaned files: %v", files)
+				}
+			},
+		},
+		{name: "quota lookup error", fail: []string{"Attachments.ListByPost"}, req: apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, "x.png"), as: alice}, status: http.StatusInternalServerError},
+		{
+			name:   "quota recheck error leaves no file behind",
+			fail:   []string{"Attachments.ListByPostLocked"},
+			req:    apiRequest{method: "POST", path: "/posts/1/attachments", body: pngFile(t, "x.png"), as: alice},
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if files := storedAttachments(e, 1); len(files) != 0 {
+					t.Errorf("orphaned files: %v", files)
+				}
+			},
+		},
+	})
+}
+
+func TestGetAttachments(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "with signed urls",
+			setup: func(t *testing.T, e *testEnv) {
+				attach(t, e, 1, alice, pngFile(t, "first.png"))
+				attach(t, e, 1, alice, pdfFile("second.pdf", 50))
+				attach(t, e, 2, bob, pngFile(t, "other post.png"))
+			},
+			req:    apiRequest{method: "GET", path: "/posts/1/attachments"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				attachments := decode[[]models.Attachment](t, body)
+				if len(attachments) != 2 || attachments[0].FileName != "first.png" || attachments[1].FileName != "second.pdf" {
+					t.Fatalf("got %+v", attachments)
+				}
+				resp, data := e.do(t, apiRequest{method: "GET", path: attachments[1].URL})
+				if resp.StatusCode != http.StatusOK || !bytes.HasPrefix(data, []byte("%PDF-")) {
+					t.Errorf("signed url: %d %q", resp.StatusCode, data)
+				}
+			},
+		},
+		{
+			name:   "none",
+			req:    apiRequest{method: "GET", path: "/posts/1/attachments"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if string(body) != "[]" {
+					t.Errorf("body = %s", body)
+				}
+			},
+		},
+		{name: "post not found", req: apiRequest{method: "GET", path: "/posts/99/attachments"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Attachments.ListByPost"}, req: apiRequest{method: "GET", path: "/posts/1/attachments"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	setup := func(t *testing.T, e *testEnv) {
+		attach(t, e, 1, alice, pngFile(t, "screen.png"))
+		attach(t, e, 1, alice, pdfFile("résumé.pdf", 50))
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "image inline",
+			setup:  setup,
+			req:    apiRequest{method: "GET", path: "/posts/1/attachments/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := resp.Header.Get("Content-Type"); got != "image/png" {
+					t.Errorf("Content-Type = %q", got)
+				}
+				if got := resp.Header.Get("Content-Disposition"); got != `inline; filename=screen.png` {
+					t.Errorf("Content-Disposition = %q", got)
+				}
+				if resp.Header.Get("X-Content-Type-Options") != "nosniff" || resp.Header.Get("ETag") == "" || resp.Header.Get("Cache-Control") != "private, max-age=86400" {
+					t.Errorf("headers: %v", resp.Header)
+				}
+				wantImageSize(t, body, 8, 8)
+			},
+		},
+		{
+			name:   "pdf as download",
+			setup:  setup,
+			req:    apiRequest{method: "GET", path: "/posts/1/attachments/2"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := resp.Header.Get("Content-Type"); got != "application/pdf" {
+					t.Errorf("Content-Type = %q", got)
+				}
+				if got := resp.Header.Get("Content-Disposition"); got != `attachment; filename*=utf-8''r%C3%A9sum%C3%A9.pdf` {
+					t.Errorf("Content-Disposition = %q", got)
+				}
+			},
+		},
+		{name: "on another post", setup: setup, req: apiRequest{method: "GET", path: "/posts/2/attachments/1"}, status: http.StatusNotFound},
+		{name: "not found", setup: setup, req: apiRequest{method: "GET", path: "/posts/1/attachments/99"}, status: http.StatusNotFound},
+		{name: "invalid id", req: apiRequest{method: "GET", path: "/posts/1/attachments/abc"}, status: http.StatusBadRequest},
+		{
+			name: "file missing from storage",
+			setup: func(t *testing.T, e *testEnv) {
+				setup(t, e)
+				for _, file := range storedAttachments(e, 1) {
+					os.Remove(file)
+				}
+			},
+			req:    apiRequest{method: "GET", path: "/posts/1/attachments/1"},
+			status: http.StatusNotFound,
+		},
+		{name: "database error", setup: setup, fail: []string{"Attachments.GetByID"}, req: apiRequest{method: "GET", path: "/posts/1/attachments/1"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	setup := func(t *testing.T, e *testEnv) { attach(t, e, 1, alice, pngFile(t, "x.png")) }
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by author",
+			setup:  setup,
+			req:    apiRequest{method: "DELETE", path: "/posts/1/attachments/1", as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if list, _ := e.store.Attachments().ListByPost(context.Background(), 1); len(list) != 0 {
+					t.Errorf("attachment still listed: %+v", list)
+				}
+				if files := storedAttachments(e, 1); len(files) != 0 {
+					t.Errorf("file not removed: %v", files)
+				}
+			},
+		},
+		{name: "by moderator", setup: setup, req: apiRequest{method: "DELETE", path: "/posts/1/attachments/1", as: mod}, status: http.StatusOK},
+		{name: "by another customer", setup: setup, req: apiRequest{method: "DELETE", path: "/posts/1/attachments/1", as: bob}, status: http.StatusForbidden},
+		{name: "anonymous", setup: setup, req: apiRequest{method: "DELETE", path: "/posts/1/attachments/1"}, status: http.StatusUnauthorized},
+		{name: "not found", req: apiRequest{method: "DELETE", path: "/posts/1/attachments/1", as: alice}, status: http.StatusNotFound},
+		{name: "database error", setup: setup, fail: []string{"Attachments.Delete"}, req: apiRequest{method: "DELETE", path: "/posts/1/attachments/1", as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestDeletePostKeepsAttachments(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "files stay until the post is purged",
+			setup: func(t *testing.T, e *testEnv) {
+				attach(t, e, 1, alice, pngFile(t, "x.png"))
+				attach(t, e, 1, alice, pdfFile("y.pdf", 50))
+			},
+			req:    apiRequest{method: "DELETE", path: "/posts/1", as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if files := storedAttachments(e, 1); len(files) != 2 {
+					t.Errorf("stored files = %v, want both kept", files)
+				}
+			},
+		},
+	})
+}
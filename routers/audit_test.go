@@ -0,0 +1,272 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// auditEntries returns the whole audit log, newest first
+func auditEntries(t *testing.T, e *testEnv) []models.AuditEntry {
+	t.Helper()
+	entries, _, err := e.store.Audit().List(context.Background(), repository.AuditFilter{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// wantAudit checks the most recent audit entry
+func wantAudit(t *testing.T, e *testEnv, action string, targetID int, actor int) models.AuditEntry {
+	t.Helper()
+	entries := auditEntries(t, e)
+	if len(entries) == 0 {
+		t.Fatalf("no audit entry, want %s", action)
+	}
+	entry := entries[0]
+	if entry.Action != action || entry.TargetID != targetID {
+		t.Errorf("audit entry = %s %s %d, want %s %d", entry.Action, entry.TargetType, entry.TargetID, action, targetID)
+	}
+	if entry.ID_actor == nil || *entry.ID_actor != actor {
+		t.Errorf("actor = %v, want %d", entry.ID_actor, actor)
+	}
+	return entry
+}
+
+// auditField reads a field of the before or after document of an entry
+func auditField(t *testing.T, doc json.RawMessage, field string) any {
+	t.Helper()
+	if doc == nil {
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(doc, &m); err != nil {
+		t.Fatalf("decode %s: %v", doc, err)
+	}
+	return m[field]
+}
+
+func TestAuditRecordsChanges(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "post update with before and after",
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Solved", "content": "It works", "version": 1}, as: alice, header: map[string]string{"User-Agent": "NagariCare/2.1 (Android)"}},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				entry := wantAudit(t, e, models.AuditPostUpdate, 1, alice)
+				if entry.TargetType != models.AuditTargetPost {
+					t.Errorf("target type = %q", entry.TargetType)
+				}
+				if got := auditField(t, entry.Before, "title"); got != "Transfer failed" {
+					t.Errorf("before title = %v", got)
+				}
+				if got := auditField(t, entry.After, "title"); got != "Solved" {
+					t.Errorf("after title = %v", got)
+				}
+				if entry.UserAgent != "NagariCare/2.1 (Android)" || entry.IP == "" {
+					t.Errorf("client = %q %q", entry.IP, entry.UserAgent)
+				}
+			},
+		},
+		{
+			name:   "long multi-byte user agent",
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Solved", "content": "It works", "version": 1}, as: alice, header: map[string]string{"User-Agent": "NagariCare/2.10 " + strings.Repeat("é", 300)}},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				entry := wantAudit(t, e, models.AuditPostUpdate, 1, alice)
+				if !utf8.ValidString(entry.UserAgent) || utf8.RuneCountInString(entry.UserAgent) != 255 || !strings.HasPrefix(entry.UserAgent, "NagariCare/2.10 é") {
+					t.Errorf("user agent = %q", entry.UserAgent)
+				}
+			},
+		},
+		{
+			name:   "post creation has no before",
+			req:    apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "Login issue", "content": "OTP never arrives", "category_id": general}, as: bob},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				entry := wantAudit(t, e, models.AuditPostCreate, 3, bob)
+				if entry.Before != nil || auditField(t, entry.After, "title") != "Login issue" {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name:   "post deletion has no after",
+			req:    apiRequest{method: "DELETE", path: "/posts/2", as: mod},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				entry := wantAudit(t, e, models.AuditPostDelete, 2, mod)
+				if auditField(t, entry.Before, "title") != "Card blocked" || entry.After != nil {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name:   "comment deletion",
+			req:    apiRequest{method: "DELETE", path: "/posts/1/comments/1", as: bob},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantAudit(t, e, models.AuditCommentDelete, 1, bob)
+			},
+		},
+		{
+			name:   "role change",
+			req:    apiRequest{method: "PUT", path: "/users/2/role", body: map[string]any{"role": "agent"}, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				entry := wantAudit(t, e, models.AuditUserRoleUpdate, bob, admin)
+				if auditField(t, entry.Before, "role") != models.RoleCustomer || auditField(t, entry.After, "role") != models.RoleAgent {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name:   "rejected changes are not recorded",
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Mine now"}, as: bob},
+			status: http.StatusForbidden,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if entries := auditEntries(t, e); len(entries) != 0 {
+					t.Errorf("entries = %+v", entries)
+				}
+			},
+		},
+	})
+}
+
+func TestAuditIsTransactional(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "post update is rolled back",
+			fail:   []string{"Audit.Record"},
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Solved", "content": "It works", "version": 1}, as: alice},
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.Title != "Transfer failed" || post.EditedAt != nil {
+					t.Errorf("stored post = %+v", post)
+				}
+				if revisions, _ := e.store.Posts().ListRevisions(context.Background(), 1); len(revisions) != 1 {
+					t.Errorf("revisions = %d, want 1", len(revisions))
+				}
+			},
+		},
+		{
+			name:   "user deletion is rolled back",
+			fail:   []string{"Audit.Record"},
+			req:    apiRequest{method: "DELETE", path: "/users/2", as: admin},
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if _, err := e.store.Users().GetByID(context.Background(), bob); err != nil {
+					t.Errorf("user was deleted: %v", err)
+				}
+			},
+		},
+		{
+			name:   "comment is not created",
+			fail:   []string{"Audit.Record"},
+			req:    apiRequest{method: "POST", path: "/posts/1/comments", body: map[string]any{"content": "Me too"}, as: alice},
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if _, err := e.store.Comments().GetByID(context.Background(), 1, 2); err == nil {
+					t.Error("comment was created")
+				}
+			},
+		},
+	})
+}
+
+func TestGetAuditLog(t *testing.T) {
+	changes := func(t *testing.T, e *testEnv) {
+		for _, r := range []apiRequest{
+			{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Solved", "content": "It works", "version": 1}, as: alice},
+			{method: "DELETE", path: "/posts/2", as: mod},
+			{method: "PUT", path: "/users/2/role", body: map[string]any{"role": "agent"}, as: admin},
+		} {
+			if resp, body := e.do(t, r); resp.StatusCode != http.StatusOK {
+				t.Fatalf("%s %s: %d %s", r.method, r.path, resp.StatusCode, body)
+			}
+		}
+	}
+	type page struct {
+		Data       []map[string]any `json:"data"`
+		Total      int              `json:"total"`
+		NextOffset *int             `json:"next_offset"`
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "newest first",
+			setup:  changes,
+			req:    apiRequest{method: "GET", path: "/audit?limit=2", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				got := decode[page](t, body)
+				if got.Total != 3 || len(got.Data) != 2 || got.NextOffset == nil || *got.NextOffset != 2 {
+					t.Fatalf("page = %s", body)
+				}
+				if got.Data[0]["action"] != models.AuditUserRoleUpdate || got.Data[1]["action"] != models.AuditPostDelete {
+					t.Errorf("order = %v, %v", got.Data[0]["action"], got.Data[1]["action"])
+				}
+				if got.Data[0]["created_at"] == "" {
+					t.Error("created_at missing")
+				}
+			},
+		},
+		{
+			name:   "by actor",
+			setup:  changes,
+			req:    apiRequest{method: "GET", path: "/audit?actor=3", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				got := decode[page](t, body)
+				if got.Total != 1 || got.Data[0]["action"] != models.AuditPostDelete || got.NextOffset != nil {
+					t.Errorf("page = %s", body)
+				}
+			},
+		},
+		{
+			name:   "by target",
+			setup:  changes,
+			req:    apiRequest{method: "GET", path: "/audit?target_type=post&target_id=1&action=post.update", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := decode[page](t, body); got.Total != 1 {
+					t.Errorf("page = %s", body)
+				}
+			},
+		},
+		{
+			name:   "by date",
+			setup:  changes,
+			req:    apiRequest{method: "GET", path: "/audit?to=2024-10-31", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 0)
+			},
+		},
+		{name: "bad limit", req: apiRequest{method: "GET", path: "/audit?limit=500", as: admin}, status: http.StatusBadRequest},
+		{name: "bad actor", req: apiRequest{method: "GET", path: "/audit?actor=bob", as: admin}, status: http.StatusBadRequest},
+		{name: "bad date", req: apiRequest{method: "GET", path: "/audit?from=yesterday", as: admin}, status: http.StatusBadRequest},
+		{name: "moderator", req: apiRequest{method: "GET", path: "/audit", as: mod}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "GET", path: "/audit"}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Audit.List"}, req: apiRequest{method: "GET", path: "/audit", as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestAuditPurge(t *testing.T) {
+	e := newTestEnv(t)
+	trashPost(t, e, 1)
+	if _, _, err := e.handler.PurgeTrash(context.Background(), e.store.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := auditEntries(t, e)
+	if len(entries) != 1 || entries[0].Action != models.AuditPostPurge || entries[0].TargetID != 1 || entries[0].ID_actor != nil {
+		t.Errorf("entries = %+v", entries)
+	}
+}
@@ -0,0 +1,358 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// addCategories files post 2 under a subcategory: general(1), cards(2) and
+// cards/lost-cards(3)
+func addCategories(t *testing.T, e *testEnv) {
+	t.Helper()
+	ctx := context.Background()
+	cards := models.Category{Slug: "cards", Name: "Cards", SortOrder: -1}
+	if err := e.store.Categories().Create(ctx, &cards); err != nil {
+		t.Fatal(err)
+	}
+	lost := models.Category{ID_parent: &cards.ID_category, Slug: "lost-cards", Name: "Lost cards"}
+	if err := e.store.Categories().Create(ctx, &lost); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.store.Posts().SetCategory(ctx, 2, lost.ID_category); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// categoryNode is the part of a category tree the tests look at
+type categoryNode struct {
+	ID_category    int            `json:"id_category"`
+	Slug           string         `json:"slug"`
+	PostCount      int            `json:"post_count"`
+	TotalPostCount int            `json:"total_post_count"`
+	Children       []categoryNode `json:"children"`
+}
+
+func TestGetCategories(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "tree with counts",
+			setup:  addCategories,
+			req:    apiRequest{method: "GET", path: "/categories"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				tree := decode[[]categoryNode](t, body)
+				if len(tree) != 2 || tree[0].Slug != "cards" || tree[1].Slug != "general" {
+					t.Fatalf("tree = %+v", tree)
+				}
+				cards := tree[0]
+				if cards.PostCount != 0 || cards.TotalPostCount != 1 || len(cards.Children) != 1 || cards.Children[0].PostCount != 1 {
+					t.Errorf("cards = %+v", cards)
+				}
+				if tree[1].PostCount != 1 || tree[1].TotalPostCount != 1 {
+					t.Errorf("general = %+v", tree[1])
+				}
+			},
+		},
+		{name: "database error", fail: []string{"Categories.List"}, req: apiRequest{method: "GET", path: "/categories"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetCategory(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "with subcategories",
+			setup:  addCategories,
+			req:    apiRequest{method: "GET", path: "/categories/2"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				node := decode[categoryNode](t, body)
+				if node.Slug != "cards" || node.TotalPostCount != 1 || len(node.Children) != 1 || node.Children[0].Slug != "lost-cards" {
+					t.Errorf("category = %+v", node)
+				}
+			},
+		},
+		{name: "subcategory", setup: addCategories, req: apiRequest{method: "GET", path: "/categories/3"}, status: http.StatusOK},
+		{name: "not found", req: apiRequest{method: "GET", path: "/categories/99"}, status: http.StatusNotFound},
+		{name: "invalid id", req: apiRequest{method: "GET", path: "/categories/x"}, status: http.StatusBadRequest},
+	})
+}
+
+func TestCreateCategory(t *testing.T) {
+	loans := map[string]any{"slug": "loans", "name": "Loans", "description": "Loan applications and repayments"}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by admin",
+			req:    apiRequest{method: "POST", path: "/categories", body: loans, as: admin},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "id_category", 2)
+				category, err := e.store.Categories().GetByID(context.Background(), 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if category.Slug != "loans" || category.ID_parent != nil {
+					t.Errorf("stored category = %+v", category)
+				}
+				entry := wantAudit(t, e, models.AuditCategoryCreate, 2, admin)
+				if auditField(t, entry.After, "slug") != "loans" {
+					t.Errorf("after %s", entry.After)
+				}
+			},
+		},
+		{
+			name:   "under a parent",
+			req:    apiRequest{method: "POST", path: "/categories", body: map[string]any{"id_parent": general, "slug": "login", "name": "Login"}, as: admin},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "id_parent", general)
+			},
+		},
+		{name: "by moderator", req: apiRequest{method: "POST", path: "/categories", body: loans, as: mod}, status: http.StatusForbidden},
+		{name: "invalid slug", req: apiRequest{method: "POST", path: "/categories", body: map[string]any{"slug": "Loans!", "name": "Loans"}, as: admin}, status: http.StatusBadRequest},
+		{name: "missing name", req: apiRequest{method: "POST", path: "/categories", body: map[string]any{"slug": "loans"}, as: admin}, status: http.StatusBadRequest},
+		{name: "duplicate slug", req: apiRequest{method: "POST", path: "/categories", body: map[string]any{"slug": "general", "name": "Other"}, as: admin}, status: http.StatusConflict},
+		{name: "missing parent", req: apiRequest{method: "POST", path: "/categories", body: map[string]any{"id_parent": 99, "slug": "loans", "name": "Loans"}, as: admin}, status: http.StatusBadRequest},
+		{name: "invalid body", req: apiRequest{method: "POST", path: "/categories", body: "{", as: admin}, status: http.StatusBadRequest},
+		{name: "database error", fail: []string{"Categories.Create"}, req: apiRequest{method: "POST", path: "/categories", body: loans, as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestUpdateCategory(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "rename and move",
+			setup:  addCategories,
+			req:    apiRequest{method: "PUT", path: "/categories/3", body: map[string]any{"id_parent": general, "slug": "lost-card", "name": "Lost card", "sort_order": 2}, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				category, _ := e.store.Categories().GetByID(context.Background(), 3)
+				if category.Slug != "lost-card" || category.ID_parent == nil || *category.ID_parent != general || category.SortOrder != 2 {
+					t.Errorf("stored category = %+v", category)
+				}
+				entry := wantAudit(t, e, models.AuditCategoryUpdate, 3, admin)
+				if auditField(t, entry.Before, "slug") != "lost-cards" || auditField(t, entry.After, "slug") != "lost-card" {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{name: "under its own subcategory", setup: addCategories, req: apiRequest{method: "PUT", path: "/categories/2", body: map[string]any{"id_parent": 3, "slug": "cards", "name": "Cards"}, as: admin}, status: http.StatusBadRequest},
+		{name: "under itself", setup: addCategories, req: apiRequest{method: "PUT", path: "/categories/2", body: map[string]any{"id_parent": 2, "slug": "cards", "name": "Cards"}, as: admin}, status: http.StatusBadRequest},
+		{name: "duplicate slug", setup: addCategories, req: apiRequest{method: "PUT", path: "/categories/3", body: map[string]any{"slug": "cards", "name": "Cards"}, as: admin}, status: http.StatusConflict},
+		{name: "not found", req: apiRequest{method: "PUT", path: "/categories/99", body: map[string]any{"slug": "x", "name": "X"}, as: admin}, status: http.StatusNotFound},
+		{name: "by agent", req: apiRequest{method: "PUT", path: "/categories/1", body: map[string]any{"slug": "general", "name": "General"}, as: agent}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Categories.Update"}, req: apiRequest{method: "PUT", path: "/categories/1", body: map[string]any{"slug": "general", "name": "General"}, as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestDeleteCategory(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "empty",
+			setup: func(t *testing.T, e *testEnv) {
+				addCategories(t, e)
+				if err := e.store.Posts().SetCategory(context.Background(), 2, general); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    apiRequest{method: "DELETE", path: "/categories/3", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if _, err := e.store.Categories().GetByID(context.Background(), 3); err == nil {
+					t.Error("category still stored")
+				}
+				wantAudit(t, e, models.AuditCategoryDelete, 3, admin)
+			},
+		},
+		{name: "with posts", setup: addCategories, req: apiRequest{method: "DELETE", path: "/categories/3", as: admin}, status: http.StatusConflict},
+		{name: "with subcategories", setup: addCategories, req: apiRequest{method: "DELETE", path: "/categories/2", as: admin}, status: http.StatusConflict},
+		{
+			name: "with trashed posts",
+			setup: func(t *testing.T, e *testEnv) {
+				addCategories(t, e)
+				if err := e.store.Posts().Delete(context.Background(), 2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    apiRequest{method: "DELETE", path: "/categories/3", as: admin},
+			status: http.StatusConflict,
+		},
+		{name: "not found", req: apiRequest{method: "DELETE", path: "/categories/99", as: admin}, status: http.StatusNotFound},
+		{name: "by moderator", req: apiRequest{method: "DELETE", path: "/categories/1", as: mod}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Categories.Delete"}, req: apiRequest{method: "DELETE", path: "/categories/1", as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestSetPostCategory(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by author",
+			setup:  addCategories,
+			req:    apiRequest{method: "PUT", path: "/posts/1/category", body: map[string]any{"category_id": 2}, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "category_id", 2)
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.ID_category != 2 {
+					t.Errorf("stored post = %+v", post)
+				}
+				entry := wantAudit(t, e, models.AuditPostCategory, 1, alice)
+				if auditField(t, entry.Before, "category_id") != float64(general) || auditField(t, entry.After, "category_id") != float64(2) {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name: "moves deadlines to the category policy",
+			setup: func(t *testing.T, e *testEnv) {
+				addCategories(t, e)
+				cards := 2
+				err := e.store.SLAPolicies().Update(context.Background(), &models.SLAPolicy{ID_category: &cards, Priority: models.PriorityNormal, FirstResponseMinutes: 60, ResolutionMinutes: 600})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    apiRequest{method: "PUT", path: "/posts/1/category", body: map[string]any{"category_id": 2}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.Priority != models.PriorityNormal || post.SLA.FirstResponseDue == nil {
+					t.Fatalf("stored post = %+v", post)
+				}
+				if got := post.SLA.FirstResponseDue.Sub(post.CreatedAt); got != time.Hour {
+					t.Errorf("first response due after %s, want 1h", got)
+				}
+				if got := post.SLA.ResolutionDue.Sub(post.CreatedAt); got != 10*time.Hour {
+					t.Errorf("resolution due after %s, want 10h", got)
+				}
+			},
+		},
+		{name: "by another customer", setup: addCategories, req: apiRequest{method: "PUT", path: "/posts/1/category", body: map[string]any{"category_id": 2}, as: bob}, status: http.StatusForbidden},
+		{name: "unknown category", req: apiRequest{method: "PUT", path: "/posts/1/category", body: map[string]any{"category_id": 99}, as: alice}, status: http.StatusBadRequest},
+		{name: "missing category", req: apiRequest{method: "PUT", path: "/posts/1/category", body: map[string]any{}, as: alice}, status: http.StatusBadRequest},
+		{name: "post not found", req: apiRequest{method: "PUT", path: "/posts/99/category", body: map[string]any{"category_id": general}, as: agent}, status: http.StatusNotFound},
+		{name: "anonymous", req: apiRequest{method: "PUT", path: "/posts/1/category", body: map[string]any{"category_id": general}}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Posts.SetCategory"}, req: apiRequest{method: "PUT", path: "/posts/1/category", body: map[string]any{"category_id": general}, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestListPostsByCategory(t *testing.T) {
+	listed := func(want ...int) func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+		return func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+			page := decode[ticketPage](t, body)
+			if page.Total != len(want) || len(page.Data) != len(want) {
+				t.Fatalf("page = %+v, want posts %v", page, want)
+			}
+			for i, id := range want {
+				if page.Data[i].ID_Posts != id {
+					t.Errorf("post %d = %d, want %d", i, page.Data[i].ID_Posts, id)
+				}
+			}
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{name: "by id", setup: addCategories, req: apiRequest{method: "GET", path: "/posts?category=1"}, status: http.StatusOK, check: listed(1)},
+		{name: "by slug with subcategories", setup: addCategories, req: apiRequest{method: "GET", path: "/posts?category=cards"}, status: http.StatusOK, check: listed(2)},
+		{name: "by subcategory slug", setup: addCategories, req: apiRequest{method: "GET", path: "/posts?category=lost-cards"}, status: http.StatusOK, check: listed(2)},
+		{name: "unknown slug", req: apiRequest{method: "GET", path: "/posts?category=loans"}, status: http.StatusOK, check: listed()},
+		{name: "invalid", req: apiRequest{method: "GET", path: "/posts?category=Lost%20cards"}, status: http.StatusBadRequest},
+	})
+}
+
+func TestSearchPostsByCategory(t *testing.T) {
+	hits := func(want int) func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+		return func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+			wantField(t, body, "total", want)
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{name: "by slug with subcategories", setup: addCategories, req: apiRequest{method: "GET", path: "/posts/search?q=card&category=cards"}, status: http.StatusOK, check: hits(1)},
+		{name: "by subcategory id", setup: addCategories, req: apiRequest{method: "GET", path: "/posts/search?q=card&category=3"}, status: http.StatusOK, check: hits(1)},
+		{name: "outside the category", setup: addCategories, req: apiRequest{method: "GET", path: "/posts/search?q=card&category=general"}, status: http.StatusOK, check: hits(0)},
+		{name: "comments follow their post", setup: addCategories, req: apiRequest{method: "GET", path: "/posts/search?q=transfer&category=1"}, status: http.StatusOK, check: hits(2)},
+		{name: "invalid", req: apiRequest{method: "GET", path: "/posts/search?q=card&category=Lost%20cards"}, status: http.StatusBadRequest},
+	})
+}
+
+func TestCategorySLAPolicies(t *testing.T) {
+	override := func(t *testing.T, e *testEnv) {
+		t.Helper()
+		addCategories(t, e)
+		cards := 2
+		err := e.store.SLAPolicies().Update(context.Background(), &models.SLAPolicy{ID_category: &cards, Priority: models.PriorityHigh, FirstResponseMinutes: 15, ResolutionMinutes: 60})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "list in category",
+			setup:  override,
+			req:    apiRequest{method: "GET", path: "/sla/policies?category=2", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				policies := decode[[]models.SLAPolicy](t, body)
+				if len(policies) != len(models.Priorities) {
+					t.Fatalf("policies = %+v", policies)
+				}
+				for _, policy := range policies {
+					overridden := policy.ID_category != nil && *policy.ID_category == 2
+					if overridden != (policy.Priority == models.PriorityHigh) {
+						t.Errorf("policy = %+v", policy)
+					}
+					if overridden && policy.FirstResponseMinutes != 15 {
+						t.Errorf("override = %+v", policy)
+					}
+				}
+			},
+		},
+		{
+			name:   "override",
+			setup:  addCategories,
+			req:    apiRequest{method: "PUT", path: "/sla/policies/urgent?category=3", body: map[string]any{"first_response_minutes": 10, "resolution_minutes": 120}, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "category_id", 3)
+				ctx := context.Background()
+				if policy, _ := e.store.SLAPolicies().Get(ctx, 3, models.PriorityUrgent); policy.FirstResponseMinutes != 10 {
+					t.Errorf("category policy = %+v", policy)
+				}
+				if policy, _ := e.store.SLAPolicies().Get(ctx, general, models.PriorityUrgent); policy.FirstResponseMinutes != 30 {
+					t.Errorf("default policy changed: %+v", policy)
+				}
+				entry := wantAudit(t, e, models.AuditSLAPolicyUpdate, 3, admin)
+				if auditField(t, entry.Before, "category_id") != nil || auditField(t, entry.After, "category_id") != 3.0 {
+					t.Errorf("audit entry = %+v", entry)
+				}
+			},
+		},
+		{name: "override unknown category", req: apiRequest{method: "PUT", path: "/sla/policies/urgent?category=99", body: map[string]any{"first_response_minutes": 10, "resolution_minutes": 120}, as: admin}, status: http.StatusNotFound},
+		{name: "invalid category", req: apiRequest{method: "GET", path: "/sla/policies?category=cards", as: agent}, status: http.StatusBadRequest},
+		{
+			name:   "delete override",
+			setup:  override,
+			req:    apiRequest{method: "DELETE", path: "/sla/policies/high?category=2", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				policy, _ := e.store.SLAPolicies().Get(context.Background(), 2, models.PriorityHigh)
+				if policy.ID_category != nil || policy.FirstResponseMinutes != 120 {
+					t.Errorf("policy = %+v", policy)
+				}
+				entry := wantAudit(t, e, models.AuditSLAPolicyDelete, 2, admin)
+				if auditField(t, entry.Before, "first_response_minutes") != 15.0 || auditField(t, entry.After, "first_response_minutes") != 120.0 {
+					t.Errorf("audit entry = %+v", entry)
+				}
+			},
+		},
+		{name: "delete missing override", setup: addCategories, req: apiRequest{method: "DELETE", path: "/sla/policies/high?category=2", as: admin}, status: http.StatusNotFound},
+		{name: "delete default", req: apiRequest{method: "DELETE", path: "/sla/policies/high", as: admin}, status: http.StatusBadRequest},
+		{name: "delete by agent", setup: override, req: apiRequest{method: "DELETE", path: "/sla/policies/high?category=2", as: agent}, status: http.StatusForbidden},
+		{name: "delete database error", fail: []string{"SLAPolicies.DeleteOverride"}, req: apiRequest{method: "DELETE", path: "/sla/policies/high?category=1", as: admin}, status: http.StatusInternalServerError},
+	})
+}
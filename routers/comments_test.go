@@ -0,0 +1,120 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestCreateComment(t *testing.T) {
+	reply := map[string]any{"content": "Try again tomorrow", "parent_id": 1}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "reply",
+			req:    apiRequest{method: "POST", path: "/posts/1/comments", body: reply, as: mod},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "id_comment", 2)
+				comment, err := e.store.Comments().GetByID(context.Background(), 1, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if comment.ID_user != mod || comment.ParentID == nil || *comment.ParentID != 1 {
+					t.Errorf("stored comment = %+v", comment)
+				}
+			},
+		},
+		{name: "empty content", req: apiRequest{method: "POST", path: "/posts/1/comments", body: map[string]any{"content": " "}, as: alice}, status: http.StatusBadRequest},
+		{name: "parent on another post", req: apiRequest{method: "POST", path: "/posts/2/comments", body: reply, as: alice}, status: http.StatusBadRequest},
+		{name: "anonymous", req: apiRequest{method: "POST", path: "/posts/1/comments", body: reply}, status: http.StatusUnauthorized},
+		{name: "post not found", req: apiRequest{method: "POST", path: "/posts/99/comments", body: reply, as: alice}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Comments.Create"}, req: apiRequest{method: "POST", path: "/posts/1/comments", body: reply, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetComments(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "tree",
+			setup: func(t *testing.T, e *testEnv) {
+				e.do(t, apiRequest{method: "POST", path: "/posts/1/comments", body: map[string]any{"content": "Reply", "parent_id": 1}, as: alice})
+			},
+			req:    apiRequest{method: "GET", path: "/posts/1/comments"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				page := decode[struct {
+					Data []struct {
+						Replies []struct {
+							ID int `json:"id_comment"`
+						} `json:"replies"`
+					} `json:"data"`
+					Total int `json:"total"`
+				}](t, body)
+				if page.Total != 1 || len(page.Data) != 1 || len(page.Data[0].Replies) != 1 {
+					t.Errorf("unexpected tree: %s", body)
+				}
+			},
+		},
+		{name: "invalid view", req: apiRequest{method: "GET", path: "/posts/1/comments?view=graph"}, status: http.StatusBadRequest},
+		{name: "invalid page", req: apiRequest{method: "GET", path: "/posts/1/comments?page=0"}, status: http.StatusBadRequest},
+		{name: "post not found", req: apiRequest{method: "GET", path: "/posts/99/comments"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Comments.List"}, req: apiRequest{method: "GET", path: "/posts/1/comments"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestUpdateComment(t *testing.T) {
+	edit := map[string]any{"content": "Fixed after an app update"}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by author",
+			req:    apiRequest{method: "PUT", path: "/posts/1/comments/1", body: edit, as: bob},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				comment, _ := e.store.Comments().GetByID(context.Background(), 1, 1)
+				if comment.Content != "Fixed after an app update" || comment.UpdatedAt == nil {
+					t.Errorf("stored comment = %+v", comment)
+				}
+			},
+		},
+		{name: "by moderator", req: apiRequest{method: "PUT", path: "/posts/1/comments/1", body: edit, as: mod}, status: http.StatusOK},
+		{name: "by another customer", req: apiRequest{method: "PUT", path: "/posts/1/comments/1", body: edit, as: alice}, status: http.StatusForbidden},
+		{name: "empty content", req: apiRequest{method: "PUT", path: "/posts/1/comments/1", body: map[string]any{"content": ""}, as: bob}, status: http.StatusBadRequest},
+		{name: "wrong post", req: apiRequest{method: "PUT", path: "/posts/2/comments/1", body: edit, as: bob}, status: http.StatusNotFound},
+		{
+			name:   "on a trashed post",
+			setup:  func(t *testing.T, e *testEnv) { trashPost(t, e, 1) },
+			req:    apiRequest{method: "PUT", path: "/posts/1/comments/1", body: edit, as: bob},
+			status: http.StatusNotFound,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if comment, _ := e.store.Comments().GetByID(context.Background(), 1, 1); comment.UpdatedAt != nil {
+					t.Errorf("stored comment = %+v", comment)
+				}
+			},
+		},
+		{name: "database error", fail: []string{"Comments.UpdateContent"}, req: apiRequest{method: "PUT", path: "/posts/1/comments/1", body: edit, as: bob}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestDeleteComment(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{name: "by author", req: apiRequest{method: "DELETE", path: "/posts/1/comments/1", as: bob}, status: http.StatusOK},
+		{name: "by moderator", req: apiRequest{method: "DELETE", path: "/posts/1/comments/1", as: mod}, status: http.StatusOK},
+		{name: "by another customer", req: apiRequest{method: "DELETE", path: "/posts/1/comments/1", as: alice}, status: http.StatusForbidden},
+		{name: "invalid id", req: apiRequest{method: "DELETE", path: "/posts/1/comments/abc", as: bob}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "DELETE", path: "/posts/1/comments/99", as: bob}, status: http.StatusNotFound},
+		{
+			name:   "on a trashed post",
+			setup:  func(t *testing.T, e *testEnv) { trashPost(t, e, 1) },
+			req:    apiRequest{method: "DELETE", path: "/posts/1/comments/1", as: mod},
+			status: http.StatusNotFound,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if _, err := e.store.Comments().GetByID(context.Background(), 1, 1); err != nil {
+					t.Errorf("comment was deleted: %v", err)
+				}
+			},
+		},
+		{name: "database error", fail: []string{"Comments.Delete"}, req: apiRequest{method: "DELETE", path: "/posts/1/comments/1", as: bob}, status: http.StatusInternalServerError},
+	})
+}
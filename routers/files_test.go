@@ -0,0 +1,39 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeSignedFile(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	if err := e.storage.Put(ctx, "attachments/report 1.txt", strings.NewReader("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := e.storage.SignedURL(ctx, "attachments/report 1.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := e.do(t, apiRequest{method: "GET", path: signed})
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("GET %s: status = %d, body: %s", signed, resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	for name, path := range map[string]string{
+		"tampered key":      strings.Replace(signed, "report", "other", 1),
+		"missing signature": "/files/attachments/report%201.txt",
+		"escaping the root": "/files/../secret?expires=1&signature=x",
+	} {
+		if resp, _ := e.do(t, apiRequest{method: "GET", path: path}); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403", name, resp.StatusCode)
+		}
+	}
+}
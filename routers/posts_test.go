@@ -0,0 +1,307 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetAllPosts(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "newest first",
+			req:    apiRequest{method: "GET", path: "/posts"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				page := decode[struct {
+					Data []struct {
+						ID           int `json:"id_posts"`
+						CommentCount int `json:"comment_count"`
+					} `json:"data"`
+					Total int `json:"total"`
+				}](t, body)
+				if page.Total != 2 || len(page.Data) != 2 || page.Data[0].ID != 2 || page.Data[1].CommentCount != 1 {
+					t.Errorf("unexpected page: %s", body)
+				}
+			},
+		},
+		{
+			name:   "cursor pagination",
+			req:    apiRequest{method: "GET", path: "/posts?limit=1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				first := decode[struct {
+					NextCursor string `json:"next_cursor"`
+				}](t, body)
+				_, body = e.do(t, apiRequest{method: "GET", path: "/posts?limit=1&cursor=" + first.NextCursor})
+				second := decode[struct {
+					Data []struct {
+						ID int `json:"id_posts"`
+					} `json:"data"`
+					NextCursor *string `json:"next_cursor"`
+				}](t, body)
+				if len(second.Data) != 1 || second.Data[0].ID != 1 || second.NextCursor != nil {
+					t.Errorf("unexpected second page: %s", body)
+				}
+			},
+		},
+		{name: "invalid limit", req: apiRequest{method: "GET", path: "/posts?limit=0"}, status: http.StatusBadRequest},
+		{name: "invalid sort", req: apiRequest{method: "GET", path: "/posts?sort=random"}, status: http.StatusBadRequest},
+		{name: "invalid cursor", req: apiRequest{method: "GET", path: "/posts?cursor=!!"}, status: http.StatusBadRequest},
+		{name: "invalid date", req: apiRequest{method: "GET", path: "/posts?from=yesterday"}, status: http.StatusBadRequest},
+		{name: "database error", fail: []string{"Posts.List"}, req: apiRequest{method: "GET", path: "/posts"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetPostByID(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "found",
+			req:    apiRequest{method: "GET", path: "/posts/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "title", "Transfer failed")
+				wantField(t, body, "comment_count", 1)
+				wantField(t, body, "is_edited", false)
+				wantField(t, body, "edited_at", nil)
+				wantField(t, body, "version", 1)
+				wantField(t, body, "status", "open")
+				wantField(t, body, "id_assignee", nil)
+				if got := resp.Header.Get("ETag"); got != `"1"` {
+					t.Errorf("ETag = %s", got)
+				}
+			},
+		},
+		{name: "invalid id", req: apiRequest{method: "GET", path: "/posts/abc"}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "GET", path: "/posts/99"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.GetByID"}, req: apiRequest{method: "GET", path: "/posts/1"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetPostByUserID(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "found",
+			req:    apiRequest{method: "GET", path: "/posts/user/2"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 1)
+			},
+		},
+		{name: "invalid id", req: apiRequest{method: "GET", path: "/posts/user/abc"}, status: http.StatusBadRequest},
+		{name: "invalid limit", req: apiRequest{method: "GET", path: "/posts/user/1?limit=500"}, status: http.StatusBadRequest},
+		{name: "no posts", req: apiRequest{method: "GET", path: "/posts/user/99"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.List"}, req: apiRequest{method: "GET", path: "/posts/user/1"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestCreatePost(t *testing.T) {
+	newPost := map[string]any{"title": "Login issue", "content": "Cannot log in", "id_user": admin, "category_id": general}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "created as the caller",
+			req:    apiRequest{method: "POST", path: "/posts", body: newPost, as: alice},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "id_posts", 3)
+				post, err := e.store.Posts().GetByID(context.Background(), 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if post.ID_user != alice || post.Title != "Login issue" || post.ID_category != general {
+					t.Errorf("stored post = %+v", post)
+				}
+			},
+		},
+		{name: "invalid body", req: apiRequest{method: "POST", path: "/posts", body: "{", as: alice}, status: http.StatusBadRequest},
+		{name: "missing category", req: apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c"}, as: alice}, status: http.StatusBadRequest},
+		{name: "unknown category", req: apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c", "category_id": 99}, as: alice}, status: http.StatusBadRequest},
+		{name: "anonymous", req: apiRequest{method: "POST", path: "/posts", body: newPost}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Posts.Create"}, req: apiRequest{method: "POST", path: "/posts", body: newPost, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestUpdatePost(t *testing.T) {
+	edit := map[string]any{"title": "Transfer failed (solved)", "content": "It works now", "version": 1}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by author",
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "revision", 2)
+				wantField(t, body, "version", 2)
+				if got := resp.Header.Get("ETag"); got != `"2"` {
+					t.Errorf("ETag = %s", got)
+				}
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.Title != "Transfer failed (solved)" || post.EditedAt == nil {
+					t.Errorf("stored post = %+v", post)
+				}
+			},
+		},
+		{
+			name:   "unchanged",
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Transfer failed", "content": "My bank transfer failed twice", "version": 1}, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if revisions, _ := e.store.Posts().ListRevisions(context.Background(), 1); len(revisions) != 1 {
+					t.Errorf("revisions = %d, want 1", len(revisions))
+				}
+			},
+		},
+		{
+			name:   "version in If-Match",
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Solved", "content": "It works"}, as: alice, header: map[string]string{"If-Match": `"1"`}},
+			status: http.StatusOK,
+		},
+		{
+			name: "stale version",
+			setup: func(t *testing.T, e *testEnv) {
+				editPost(t, e, 1, mod, "Transfer failed [bank]", "My bank transfer failed twice")
+			},
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice},
+			status: http.StatusConflict,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				got := decode[struct {
+					Current map[string]any `json:"current"`
+				}](t, body)
+				if got.Current["title"] != "Transfer failed [bank]" || got.Current["version"] != float64(2) {
+					t.Errorf("current = %v", got.Current)
+				}
+				if resp.Header.Get("ETag") != `"2"` {
+					t.Errorf("ETag = %s", resp.Header.Get("ETag"))
+				}
+				if revisions, _ := e.store.Posts().ListRevisions(context.Background(), 1); len(revisions) != 2 {
+					t.Errorf("revisions = %d, want 2", len(revisions))
+				}
+			},
+		},
+		{
+			name:   "If-Match wins over the body",
+			req:    apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice, header: map[string]string{"If-Match": `"7"`}},
+			status: http.StatusConflict,
+		},
+		{name: "no version", req: apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Solved", "content": "It works"}, as: alice}, status: http.StatusPreconditionRequired},
+		{name: "If-Match any with body version", req: apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice, header: map[string]string{"If-Match": "*"}}, status: http.StatusOK},
+		{name: "If-Match any alone", req: apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "Solved", "content": "It works"}, as: alice, header: map[string]string{"If-Match": "*"}}, status: http.StatusPreconditionRequired},
+		{name: "weak If-Match", req: apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice, header: map[string]string{"If-Match": `W/"1"`}}, status: http.StatusBadRequest},
+		{name: "If-Match without opening quote", req: apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice, header: map[string]string{"If-Match": `1"`}}, status: http.StatusBadRequest},
+		{name: "If-Match without closing quote", req: apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice, header: map[string]string{"If-Match": `"1`}}, status: http.StatusBadRequest},
+		{name: "by moderator", req: apiRequest{method: "PUT", path: "/posts/1", body: edit, as: mod}, status: http.StatusOK},
+		{name: "by another customer", req: apiRequest{method: "PUT", path: "/posts/1", body: edit, as: bob}, status: http.StatusForbidden},
+		{name: "invalid body", req: apiRequest{method: "PUT", path: "/posts/1", body: "{", as: alice}, status: http.StatusBadRequest},
+		{name: "invalid id", req: apiRequest{method: "PUT", path: "/posts/abc", body: edit, as: alice}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "PUT", path: "/posts/99", body: edit, as: alice}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.Update"}, req: apiRequest{method: "PUT", path: "/posts/1", body: edit, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestDeletePost(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by author moves it to the trash",
+			req:    apiRequest{method: "DELETE", path: "/posts/1", as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if _, err := e.store.Posts().GetByID(context.Background(), 1); !errors.Is(err, repository.ErrNotFound) {
+					t.Errorf("post still visible: %v", err)
+				}
+				page, err := e.store.Posts().List(context.Background(), repository.PostFilter{Limit: 10, Sort: repository.SortNewest, Trash: true})
+				if err != nil || page.Total != 1 {
+					t.Errorf("trash: %+v, %v", page, err)
+				}
+				// Comments are kept until the post is purged
+				if _, err := e.store.Comments().GetByID(context.Background(), 1, 1); err != nil {
+					t.Errorf("comment removed: %v", err)
+				}
+			},
+		},
+		{name: "by moderator", req: apiRequest{method: "DELETE", path: "/posts/1", as: mod}, status: http.StatusOK},
+		{name: "by another customer", req: apiRequest{method: "DELETE", path: "/posts/1", as: bob}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "DELETE", path: "/posts/1"}, status: http.StatusUnauthorized},
+		{name: "not found", req: apiRequest{method: "DELETE", path: "/posts/99", as: alice}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.Delete"}, req: apiRequest{method: "DELETE", path: "/posts/1", as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestSearchPosts(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "matches posts and comments",
+			req:    apiRequest{method: "GET", path: "/posts/search?q=transfer"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				result := decode[struct {
+					Data []struct {
+						PostID  int    `json:"id_posts"`
+						Snippet string `json:"snippet"`
+					} `json:"data"`
+					Total int `json:"total"`
+				}](t, body)
+				if result.Total != 2 || result.Data[0].PostID != 1 || result.Data[0].Snippet == "" {
+					t.Errorf("unexpected result: %s", body)
+				}
+			},
+		},
+		{
+			name: "skips trashed posts and their comments",
+			setup: func(t *testing.T, e *testEnv) {
+				if err := e.store.Posts().Delete(context.Background(), 1); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    apiRequest{method: "GET", path: "/posts/search?q=transfer"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 0)
+			},
+		},
+		{name: "missing query", req: apiRequest{method: "GET", path: "/posts/search"}, status: http.StatusBadRequest},
+		{name: "sort not allowed", req: apiRequest{method: "GET", path: "/posts/search?q=card&sort=oldest"}, status: http.StatusBadRequest},
+		{
+			name:   "by status",
+			setup:  func(t *testing.T, e *testEnv) { moveTicket(t, e, 1, models.StatusOpen, models.StatusInProgress) },
+			req:    apiRequest{method: "GET", path: "/posts/search?q=transfer&status=in_progress,resolved"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 2)
+			},
+		},
+		{
+			name:   "by other status",
+			setup:  func(t *testing.T, e *testEnv) { moveTicket(t, e, 1, models.StatusOpen, models.StatusInProgress) },
+			req:    apiRequest{method: "GET", path: "/posts/search?q=transfer&status=open"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 0)
+			},
+		},
+		{
+			name:   "by assignee",
+			setup:  func(t *testing.T, e *testEnv) { assignTicket(t, e, 1, agent) },
+			req:    apiRequest{method: "GET", path: fmt.Sprintf("/posts/search?q=transfer&assignee=%d", agent)},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 2)
+			},
+		},
+		{
+			name:   "unassigned",
+			setup:  func(t *testing.T, e *testEnv) { assignTicket(t, e, 1, agent) },
+			req:    apiRequest{method: "GET", path: "/posts/search?q=transfer&assignee=none"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 0)
+			},
+		},
+		{name: "invalid status", req: apiRequest{method: "GET", path: "/posts/search?q=card&status=done"}, status: http.StatusBadRequest},
+		{name: "search error", fail: []string{"Search"}, req: apiRequest{method: "GET", path: "/posts/search?q=card"}, status: http.StatusInternalServerError},
+	})
+}
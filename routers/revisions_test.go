@@ -0,0 +1,183 @@
+package routes_test
+
+import (
+	"backend-nagaricare/diff"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// editPost saves a new revision of a seeded post
+func editPost(t *testing.T, e *testEnv, postID, editor int, title, content string) {
+	t.Helper()
+	post, err := e.store.Posts().GetByID(context.Background(), postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Title, post.Content = title, content
+	if _, err := e.store.Posts().Update(context.Background(), post, editor); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetPostRevisions(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "oldest first",
+			setup: func(t *testing.T, e *testEnv) {
+				editPost(t, e, 1, mod, "Transfer failed (solved)", "My bank transfer failed twice")
+			},
+			req:    apiRequest{method: "GET", path: "/posts/1/revisions"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				revisions := decode[[]map[string]any](t, body)
+				if len(revisions) != 2 {
+					t.Fatalf("revisions = %s", body)
+				}
+				if revisions[0]["revision"] != float64(1) || revisions[0]["title"] != "Transfer failed" || revisions[0]["id_editor"] != float64(alice) {
+					t.Errorf("first revision = %v", revisions[0])
+				}
+				if revisions[1]["revision"] != float64(2) || revisions[1]["title"] != "Transfer failed (solved)" || revisions[1]["id_editor"] != float64(mod) {
+					t.Errorf("second revision = %v", revisions[1])
+				}
+				if revisions[1]["created_at"] == "" {
+					t.Error("created_at missing")
+				}
+			},
+		},
+		{
+			name:   "edited post is flagged",
+			setup:  func(t *testing.T, e *testEnv) { editPost(t, e, 1, alice, "Transfer failed", "Fixed") },
+			req:    apiRequest{method: "GET", path: "/posts/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "is_edited", true)
+				if decode[map[string]any](t, body)["edited_at"] == nil {
+					t.Error("edited_at missing")
+				}
+			},
+		},
+		{name: "unknown post", req: apiRequest{method: "GET", path: "/posts/99/revisions"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.ListRevisions"}, req: apiRequest{method: "GET", path: "/posts/1/revisions"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetRevisionDiff(t *testing.T) {
+	edits := func(t *testing.T, e *testEnv) {
+		editPost(t, e, 1, alice, "Transfer failed", "My bank transfer failed twice\nI used the app")
+		editPost(t, e, 1, alice, "Transfer failed (solved)", "My bank transfer failed twice\nI used the website")
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "between any two revisions",
+			setup:  edits,
+			req:    apiRequest{method: "GET", path: "/posts/1/revisions/diff?from=1&to=3"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				got := decode[struct {
+					From    int          `json:"from"`
+					To      int          `json:"to"`
+					Title   []diff.Chunk `json:"title"`
+					Content []diff.Chunk `json:"content"`
+				}](t, body)
+				if got.From != 1 || got.To != 3 {
+					t.Errorf("from %d to %d", got.From, got.To)
+				}
+				wantTitle := []diff.Chunk{
+					{Op: diff.OpDelete, Lines: []string{"Transfer failed"}},
+					{Op: diff.OpInsert, Lines: []string{"Transfer failed (solved)"}},
+				}
+				if !reflect.DeepEqual(got.Title, wantTitle) {
+					t.Errorf("title diff = %+v", got.Title)
+				}
+				wantContent := []diff.Chunk{
+					{Op: diff.OpEqual, Lines: []string{"My bank transfer failed twice"}},
+					{Op: diff.OpInsert, Lines: []string{"I used the website"}},
+				}
+				if !reflect.DeepEqual(got.Content, wantContent) {
+					t.Errorf("content diff = %+v", got.Content)
+				}
+			},
+		},
+		{name: "missing revision numbers", req: apiRequest{method: "GET", path: "/posts/1/revisions/diff?from=1"}, status: http.StatusBadRequest},
+		{name: "unknown revision", req: apiRequest{method: "GET", path: "/posts/1/revisions/diff?from=1&to=5"}, status: http.StatusNotFound},
+		{name: "unknown post", req: apiRequest{method: "GET", path: "/posts/99/revisions/diff?from=1&to=1"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.GetRevision"}, req: apiRequest{method: "GET", path: "/posts/1/revisions/diff?from=1&to=1"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestRollbackPost(t *testing.T) {
+	vandalise := func(t *testing.T, e *testEnv) { editPost(t, e, 1, alice, "spam", "buy now") }
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by moderator",
+			setup:  vandalise,
+			req:    apiRequest{method: "POST", path: "/posts/1/revisions/1/rollback", as: mod},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "revision", 3)
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.Title != "Transfer failed" || post.Content != "My bank transfer failed twice" {
+					t.Errorf("stored post = %+v", post)
+				}
+				revisions, _ := e.store.Posts().ListRevisions(context.Background(), 1)
+				if last := revisions[len(revisions)-1]; last.ID_editor == nil || *last.ID_editor != mod {
+					t.Errorf("rollback recorded as %+v", last)
+				}
+			},
+		},
+		{
+			name:   "already current",
+			req:    apiRequest{method: "POST", path: "/posts/1/revisions/1/rollback", as: mod},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if revisions, _ := e.store.Posts().ListRevisions(context.Background(), 1); len(revisions) != 1 {
+					t.Errorf("revisions = %d, want 1", len(revisions))
+				}
+			},
+		},
+		{
+			name:   "stale If-Match",
+			setup:  vandalise,
+			req:    apiRequest{method: "POST", path: "/posts/1/revisions/1/rollback", as: mod, header: map[string]string{"If-Match": `"1"`}},
+			status: http.StatusConflict,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if post, _ := e.store.Posts().GetByID(context.Background(), 1); post.Title != "spam" {
+					t.Errorf("stored post = %+v", post)
+				}
+			},
+		},
+		{name: "If-Match any", setup: vandalise, req: apiRequest{method: "POST", path: "/posts/1/revisions/1/rollback", as: mod, header: map[string]string{"If-Match": "*"}}, status: http.StatusOK},
+		{name: "author", setup: vandalise, req: apiRequest{method: "POST", path: "/posts/1/revisions/1/rollback", as: alice}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "POST", path: "/posts/1/revisions/1/rollback"}, status: http.StatusUnauthorized},
+		{name: "invalid revision", req: apiRequest{method: "POST", path: "/posts/1/revisions/abc/rollback", as: mod}, status: http.StatusBadRequest},
+		{name: "unknown revision", req: apiRequest{method: "POST", path: "/posts/1/revisions/9/rollback", as: mod}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.Update"}, setup: vandalise, req: apiRequest{method: "POST", path: "/posts/1/revisions/1/rollback", as: mod}, status: http.StatusInternalServerError},
+	})
+}
+
+// Purging a user keeps the revisions they made on other posts, without an editor
+func TestPurgedEditorRevisions(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+
+	editPost(t, e, 2, alice, "Card blocked", "Edited by alice")
+	trashUser(t, e, alice)
+	if err := e.store.Users().Purge(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := e.store.Posts().ListRevisions(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*int{ptr(bob), nil}
+	if len(revisions) != 2 || !reflect.DeepEqual([]*int{revisions[0].ID_editor, revisions[1].ID_editor}, want) {
+		t.Errorf("revisions = %+v", revisions)
+	}
+}
+
+func ptr(v int) *int { return &v }
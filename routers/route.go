@@ -1,30 +1,106 @@
 package routes
 
 import (
+	"backend-nagaricare/auth"
 	"backend-nagaricare/controllers"
+	"backend-nagaricare/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupRoutes(app *fiber.App) {
+func SetupRoutes(app *fiber.App, h *controllers.Handler) {
+	authn := &middleware.Authenticator{Users: h.Users, Tokens: h.Tokens}
+
 	// Forum routes
 	forum := app.Group("/posts") // Create a group for forum posts
 
-	forum.Post("/", controllers.CreatePost)                  // Create a new post
-	forum.Get("/", controllers.GetAllPosts)                  // Get all posts
-	forum.Get("/:id_post", controllers.GetPostByID)          // Get a specific post by ID
-	forum.Get("/user/:id_user", controllers.GetPostByUserID) // Get all posts by a specific user (email)
-	forum.Put("/:id_post", controllers.UpdatePost)           // Update a specific post by ID
-	forum.Delete("/:id_post", controllers.DeletePost)        // Delete a post by ID
+	forum.Post("/", authn.RequireAuth, middleware.RequirePermission(auth.PermPostCreate), h.CreatePost)                   // Create a new post as the signed-in user
+	forum.Get("/", h.GetAllPosts)                                                                                         // Get all posts
+	forum.Get("/search", h.SearchPosts)                                                                                   // Full-text search over posts and comments
+	forum.Get("/trash", authn.RequireAuth, middleware.RequirePermission(auth.PermTrashManage), h.GetPostTrash)            // List trashed posts (admin)
+	forum.Get("/queue", authn.RequireAuth, middleware.RequirePermission(auth.PermTicketWork), h.GetMyQueue)               // List the tickets assigned to the caller (agents and above)
+	forum.Get("/:id_post", h.GetPostByID)                                                                                 // Get a specific post by ID
+	forum.Get("/user/:id_user", h.GetPostByUserID)                                                                        // Get all posts by a specific user (email)
+	forum.Put("/:id_post", authn.RequireAuth, h.UpdatePost)                                                               // Update a specific post by ID (author or moderator)
+	forum.Delete("/:id_post", authn.RequireAuth, h.DeletePost)                                                            // Move a post to the trash (author or moderator)
+	forum.Post("/:id_post/restore", authn.RequireAuth, middleware.RequirePermission(auth.PermTrashManage), h.RestorePost) // Restore a trashed post (admin)
+
+	// Ticket workflow routes
+	forum.Post("/:id_post/status", authn.RequireAuth, h.SetPostStatus)                                                       // Move a ticket to another status (agent, or author to close or reopen)
+	forum.Get("/:id_post/status-history", h.GetPostStatusHistory)                                                            // List the status changes of a ticket
+	forum.Put("/:id_post/assignee", authn.RequireAuth, middleware.RequirePermission(auth.PermTicketWork), h.AssignPost)      // Assign a ticket (agents take their own, moderators assign anyone)
+	forum.Put("/:id_post/category", authn.RequireAuth, h.SetPostCategory)                                                    // File a post under another category (author or agent)
+	forum.Put("/:id_post/tags", authn.RequireAuth, h.SetPostTags)                                                            // Replace the tags of a post (author or agent)
+	forum.Put("/:id_post/accepted-answer", authn.RequireAuth, h.SetAcceptedAnswer)                                           // Mark the comment that solved a post (author or agent)
+	forum.Put("/:id_post/priority", authn.RequireAuth, middleware.RequirePermission(auth.PermTicketWork), h.SetPostPriority) // Change a ticket's priority and SLA deadlines (agents and above)
+
+	// Comment routes, nested under a post
+	comments := forum.Group("/:id_post/comments")
+
+	comments.Post("/", authn.RequireAuth, middleware.RequirePermission(auth.PermCommentCreate), h.CreateComment) // Comment on a post or reply to a comment
+	comments.Get("/", h.GetComments)                                                                             // List comments as a tree or flat, paginated
+	comments.Put("/:id_comment", authn.RequireAuth, h.UpdateComment)                                             // Edit a comment (author or moderator)
+	comments.Delete("/:id_comment", authn.RequireAuth, h.DeleteComment)                                          // Delete a comment and its replies (author or moderator)
+
+	// Revision routes, nested under a post
+	revisions := forum.Group("/:id_post/revisions")
+
+	revisions.Get("/", h.GetPostRevisions)                                                                                         // List every version of a post
+	revisions.Get("/diff", h.GetRevisionDiff)                                                                                      // Line diff between two revisions
+	revisions.Post("/:revision/rollback", authn.RequireAuth, middleware.RequirePermission(auth.PermPostUpdateAny), h.RollbackPost) // Restore an earlier revision (moderator)
+
+	// Attachment routes, nested under a post
+	attachments := forum.Group("/:id_post/attachments")
+
+	attachments.Post("/", authn.RequireAuth, h.UploadAttachment)                 // Attach an image or PDF (author or moderator)
+	attachments.Get("/", h.GetAttachments)                                       // List the files on a post with signed URLs
+	attachments.Get("/:id_attachment", h.DownloadAttachment)                     // Download an attached file
+	attachments.Delete("/:id_attachment", authn.RequireAuth, h.DeleteAttachment) // Remove an attached file (author or moderator)
 
 	// User routes
 	user := app.Group("/users") // Create a group for user-related routes
 
-	user.Post("/", controllers.CreateUser)                                // Create a new user
-	user.Post("/signin", controllers.SignInGoogle)                        // Google Sign-In
-	user.Get("/", controllers.GetUsers)                                   // Get all users
-	user.Get("/:id_user", controllers.GetUserDetails)                     // Get user details by email
-	user.Put("/uploadprofilepicture/:id_user", controllers.SaveUserPhoto) // Save user profile picture
-	user.Get("/profilepicture/:id_user", controllers.GetUserPhoto)        // Get user profile picture
-	user.Put("/:id_user", controllers.UpdateUser)                         // Edit user profile data
+	user.Post("/", authn.RequireAuth, middleware.RequirePermission(auth.PermUserCreate), h.CreateUser)                     // Create a new user (admin)
+	user.Post("/signin", h.SignInGoogle)                                                                                   // Google Sign-In, returns session tokens
+	user.Post("/refresh", h.RefreshToken)                                                                                  // Exchange a refresh token for a new token pair
+	user.Get("/", authn.RequireAuth, middleware.RequirePermission(auth.PermUserList), h.GetUsers)                          // Get all users (agents and above)
+	user.Get("/trash", authn.RequireAuth, middleware.RequirePermission(auth.PermTrashManage), h.GetUserTrash)              // List trashed users (admin)
+	user.Get("/:id_user", authn.RequireAuth, h.GetUserDetails)                                                             // Get a user, with contact details for the owner and staff
+	user.Put("/uploadprofilepicture/:id_user", authn.RequireAuth, h.SaveUserPhoto)                                         // Save user profile picture (owner or admin)
+	user.Get("/profilepicture/:id_user", h.GetUserPhoto)                                                                   // Get user profile picture
+	user.Put("/:id_user", authn.RequireAuth, h.UpdateUser)                                                                 // Edit user profile data (owner or admin)
+	user.Put("/:id_user/role", authn.RequireAuth, middleware.RequirePermission(auth.PermUserRoleUpdate), h.UpdateUserRole) // Change a user's role (admin)
+	user.Delete("/:id_user", authn.RequireAuth, h.DeleteUser)                                                              // Move a user to the trash (owner or admin)
+	user.Post("/:id_user/restore", authn.RequireAuth, middleware.RequirePermission(auth.PermTrashManage), h.RestoreUser)   // Restore a trashed user (admin)
+
+	// Category routes
+	categories := app.Group("/categories")
+
+	categories.Get("/", h.GetCategories)                                                                                           // List the category tree with post counts
+	categories.Get("/:id_category", h.GetCategory)                                                                                 // Get a category with its subcategories
+	categories.Post("/", authn.RequireAuth, middleware.RequirePermission(auth.PermCategoryManage), h.CreateCategory)               // Create a category (admin)
+	categories.Put("/:id_category", authn.RequireAuth, middleware.RequirePermission(auth.PermCategoryManage), h.UpdateCategory)    // Edit or move a category (admin)
+	categories.Delete("/:id_category", authn.RequireAuth, middleware.RequirePermission(auth.PermCategoryManage), h.DeleteCategory) // Delete an empty category (admin)
+
+	// Tag routes
+	tags := app.Group("/tags")
+
+	tags.Get("/", h.GetTags)                                                                                  // Suggest tags by prefix with usage counts
+	tags.Get("/:tag/posts", h.GetTagPosts)                                                                    // List the posts carrying a tag
+	tags.Post("/:tag/merge", authn.RequireAuth, middleware.RequirePermission(auth.PermTagManage), h.MergeTag) // Merge a tag into another or rename it on every post (admin)
+
+	// SLA policy and breach routes
+	sla := app.Group("/sla", authn.RequireAuth)
+
+	sla.Get("/policies", middleware.RequirePermission(auth.PermTicketWork), h.GetSLAPolicies)                      // List the SLA policy of each priority (agents and above)
+	sla.Put("/policies/:priority", middleware.RequirePermission(auth.PermSLAManage), h.UpdateSLAPolicy)            // Change the default targets of a priority, or override them in a category (admin)
+	sla.Delete("/policies/:priority", middleware.RequirePermission(auth.PermSLAManage), h.DeleteSLAPolicyOverride) // Return a priority in a category to the defaults (admin)
+	sla.Get("/breached", middleware.RequirePermission(auth.PermTicketWork), h.GetBreachedTickets)                  // List unresolved tickets with a missed deadline (agents and above)
+	sla.Get("/at-risk", middleware.RequirePermission(auth.PermTicketWork), h.GetAtRiskTickets)                     // List unresolved tickets due soon (agents and above)
+
+	// Audit log of every change made through the API
+	app.Get("/audit", authn.RequireAuth, middleware.RequirePermission(auth.PermAuditRead), h.GetAuditLog) // Filter and page through the audit log (admin)
+
+	// Signed download links for the local storage backend
+	app.Get("/files/*", h.ServeSignedFile)
 }
@@ -0,0 +1,746 @@
+package routes_test
+
+import (
+	"backend-nagaricare/auth"
+	"backend-nagaricare/config"
+	"backend-nagaricare/controllers"
+	"backend-nagaricare/models"
+	"backend-nagaricare/notify"
+	"backend-nagaricare/repository"
+	routes "backend-nagaricare/routers"
+	"backend-nagaricare/search"
+	"backend-nagaricare/storage"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Seeded users, see testEnv.seed
+const (
+	alice = 1 // customer, author of post 1
+	bob   = 2 // customer, author of post 2 and comment 1
+	mod   = 3 // moderator
+	admin = 4 // admin
+	agent = 5 // support agent
+)
+
+// general is the category the store starts with, holding the seeded posts
+const general = models.DefaultCategoryID
+
+const (
+	testClientID  = "test-client.apps.googleusercontent.com"
+	testKeyID     = "test-key"
+	testMaxUpload = 4096
+	testMaxPixels = 64
+
+	// Attachment quotas: three files of at most 2 KiB, 5 KiB together
+	testMaxAttachment      = 2048
+	testMaxAttachments     = 3
+	testMaxAttachmentTotal = 5120
+)
+
+var (
+	// testTokens signs session tokens for every test app, so tokens can be
+	// minted while declaring test cases
+	testTokens = auth.NewTokenIssuer([]byte("test-secret"), 15*time.Minute, time.Hour)
+
+	// testGoogleKey stands in for Google's signing key
+	testGoogleKey = mustGenerateKey()
+
+	errInjected = errors.New("injected failure")
+)
+
+func mustGenerateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// staticKeys serves testGoogleKey as the only JWKS key
+type staticKeys struct{}
+
+func (staticKeys) Key(kid string) (*rsa.PublicKey, error) {
+	if kid != testKeyID {
+		return nil, auth.ErrUnknownKey
+	}
+	return &testGoogleKey.PublicKey, nil
+}
+
+// googleIDToken signs an ID token for email the way Google would
+func googleIDToken(email string) string {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.GoogleClaims{
+		Email:         email,
+		EmailVerified: true,
+		Name:          "New User",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://accounts.google.com",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	})
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(testGoogleKey)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+// mustIssue returns a session token pair for the user
+func mustIssue(userID int) auth.TokenPair {
+	tokens, err := testTokens.Issue(userID)
+	if err != nil {
+		panic(err)
+	}
+	return tokens
+}
+
+// faults names the repository methods that fail, e.g. "Posts.List"
+type faults map[string]bool
+
+func (f faults) check(method string) error {
+	if f[method] {
+		return errInjected
+	}
+	return nil
+}
+
+type faultyUsers struct {
+	repository.UserRepository
+	faults faults
+}
+
+func (r faultyUsers) Create(ctx context.Context, user *models.User) error {
+	if err := r.faults.check("Users.Create"); err != nil {
+		return err
+	}
+	return r.UserRepository.Create(ctx, user)
+}
+
+func (r faultyUsers) List(ctx context.Context) ([]models.User, error) {
+	if err := r.faults.check("Users.List"); err != nil {
+		return nil, err
+	}
+	return r.UserRepository.List(ctx)
+}
+
+func (r faultyUsers) GetByID(ctx context.Context, id int) (*models.User, error) {
+	if err := r.faults.check("Users.GetByID"); err != nil {
+		return nil, err
+	}
+	return r.UserRepository.GetByID(ctx, id)
+}
+
+func (r faultyUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	if err := r.faults.check("Users.GetByEmail"); err != nil {
+		return nil, err
+	}
+	return r.UserRepository.GetByEmail(ctx, email)
+}
+
+func (r faultyUsers) Update(ctx context.Context, user *models.User) error {
+	if err := r.faults.check("Users.Update"); err != nil {
+		return err
+	}
+	return r.UserRepository.Update(ctx, user)
+}
+
+func (r faultyUsers) UpdateRole(ctx context.Context, id int, role string) error {
+	if err := r.faults.check("Users.UpdateRole"); err != nil {
+		return err
+	}
+	return r.UserRepository.UpdateRole(ctx, id, role)
+}
+
+func (r faultyUsers) SetPicture(ctx context.Context, id int, picture *string) error {
+	if err := r.faults.check("Users.SetPicture"); err != nil {
+		return err
+	}
+	return r.UserRepository.SetPicture(ctx, id, picture)
+}
+
+func (r faultyUsers) Delete(ctx context.Context, id int) error {
+	if err := r.faults.check("Users.Delete"); err != nil {
+		return err
+	}
+	return r.UserRepository.Delete(ctx, id)
+}
+
+func (r faultyUsers) Restore(ctx context.Context, id int) error {
+	if err := r.faults.check("Users.Restore"); err != nil {
+		return err
+	}
+	return r.UserRepository.Restore(ctx, id)
+}
+
+func (r faultyUsers) ListDeleted(ctx context.Context) ([]models.User, error) {
+	if err := r.faults.check("Users.ListDeleted"); err != nil {
+		return nil, err
+	}
+	return r.UserRepository.ListDeleted(ctx)
+}
+
+type faultyPosts struct {
+	repository.PostRepository
+	faults faults
+}
+
+func (r faultyPosts) Create(ctx context.Context, post *models.Post) error {
+	if err := r.faults.check("Posts.Create"); err != nil {
+		return err
+	}
+	return r.PostRepository.Create(ctx, post)
+}
+
+func (r faultyPosts) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	if err := r.faults.check("Posts.GetByID"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.GetByID(ctx, id)
+}
+
+func (r faultyPosts) List(ctx context.Context, filter repository.PostFilter) (*repository.PostPage, error) {
+	if err := r.faults.check("Posts.List"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.List(ctx, filter)
+}
+
+func (r faultyPosts) Update(ctx context.Context, post *models.Post, editorID int) (*models.PostRevision, error) {
+	if err := r.faults.check("Posts.Update"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.Update(ctx, post, editorID)
+}
+
+func (r faultyPosts) ListRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	if err := r.faults.check("Posts.ListRevisions"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.ListRevisions(ctx, postID)
+}
+
+func (r faultyPosts) GetRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	if err := r.faults.check("Posts.GetRevision"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.GetRevision(ctx, postID, revision)
+}
+
+func (r faultyPosts) SetStatus(ctx context.Context, id int, from, to string, actorID int) (*models.StatusChange, error) {
+	if err := r.faults.check("Posts.SetStatus"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.SetStatus(ctx, id, from, to, actorID)
+}
+
+func (r faultyPosts) Assign(ctx context.Context, id int, assigneeID *int) error {
+	if err := r.faults.check("Posts.Assign"); err != nil {
+		return err
+	}
+	return r.PostRepository.Assign(ctx, id, assigneeID)
+}
+
+func (r faultyPosts) SetCategory(ctx context.Context, id, categoryID int) error {
+	if err := r.faults.check("Posts.SetCategory"); err != nil {
+		return err
+	}
+	return r.PostRepository.SetCategory(ctx, id, categoryID)
+}
+
+func (r faultyPosts) ListStatusChanges(ctx context.Context, postID int) ([]models.StatusChange, error) {
+	if err := r.faults.check("Posts.ListStatusChanges"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.ListStatusChanges(ctx, postID)
+}
+
+func (r faultyPosts) SetPriority(ctx context.Context, id int, priority string, firstResponseDue, resolutionDue time.Time) error {
+	if err := r.faults.check("Posts.SetPriority"); err != nil {
+		return err
+	}
+	return r.PostRepository.SetPriority(ctx, id, priority, firstResponseDue, resolutionDue)
+}
+
+func (r faultyPosts) MarkFirstResponse(ctx context.Context, id int, at time.Time) error {
+	if err := r.faults.check("Posts.MarkFirstResponse"); err != nil {
+		return err
+	}
+	return r.PostRepository.MarkFirstResponse(ctx, id, at)
+}
+
+func (r faultyPosts) ListSLABreaches(ctx context.Context, now time.Time) ([]models.SLABreach, error) {
+	if err := r.faults.check("Posts.ListSLABreaches"); err != nil {
+		return nil, err
+	}
+	return r.PostRepository.ListSLABreaches(ctx, now)
+}
+
+func (r faultyPosts) FlagSLABreach(ctx context.Context, id int, kind string, at time.Time) error {
+	if err := r.faults.check("Posts.FlagSLABreach"); err != nil {
+		return err
+	}
+	return r.PostRepository.FlagSLABreach(ctx, id, kind, at)
+}
+
+func (r faultyPosts) Delete(ctx context.Context, id int) error {
+	if err := r.faults.check("Posts.Delete"); err != nil {
+		return err
+	}
+	return r.PostRepository.Delete(ctx, id)
+}
+
+func (r faultyPosts) Restore(ctx context.Context, id int) error {
+	if err := r.faults.check("Posts.Restore"); err != nil {
+		return err
+	}
+	return r.PostRepository.Restore(ctx, id)
+}
+
+type faultyComments struct {
+	repository.CommentRepository
+	faults faults
+}
+
+func (r faultyComments) Create(ctx context.Context, comment *models.Comment) error {
+	if err := r.faults.check("Comments.Create"); err != nil {
+		return err
+	}
+	return r.CommentRepository.Create(ctx, comment)
+}
+
+func (r faultyComments) GetByID(ctx context.Context, postID, commentID int) (*models.Comment, error) {
+	if err := r.faults.check("Comments.GetByID"); err != nil {
+		return nil, err
+	}
+	return r.CommentRepository.GetByID(ctx, postID, commentID)
+}
+
+func (r faultyComments) List(ctx context.Context, postID int, filter repository.CommentFilter) ([]*models.Comment, int, error) {
+	if err := r.faults.check("Comments.List"); err != nil {
+		return nil, 0, err
+	}
+	return r.CommentRepository.List(ctx, postID, filter)
+}
+
+func (r faultyComments) UpdateContent(ctx context.Context, commentID int, content string) error {
+	if err := r.faults.check("Comments.UpdateContent"); err != nil {
+		return err
+	}
+	return r.CommentRepository.UpdateContent(ctx, commentID, content)
+}
+
+func (r faultyComments) Delete(ctx context.Context, commentID int) error {
+	if err := r.faults.check("Comments.Delete"); err != nil {
+		return err
+	}
+	return r.CommentRepository.Delete(ctx, commentID)
+}
+
+func (r faultyComments) SetAccepted(ctx context.Context, postID int, commentID *int) error {
+	if err := r.faults.check("Comments.SetAccepted"); err != nil {
+		return err
+	}
+	return r.CommentRepository.SetAccepted(ctx, postID, commentID)
+}
+
+type faultyAttachments struct {
+	repository.AttachmentRepository
+	faults faults
+}
+
+func (r faultyAttachments) Create(ctx context.Context, attachment *models.Attachment) error {
+	if err := r.faults.check("Attachments.Create"); err != nil {
+		return err
+	}
+	return r.AttachmentRepository.Create(ctx, attachment)
+}
+
+func (r faultyAttachments) GetByID(ctx context.Context, postID, attachmentID int) (*models.Attachment, error) {
+	if err := r.faults.check("Attachments.GetByID"); err != nil {
+		return nil, err
+	}
+	return r.AttachmentRepository.GetByID(ctx, postID, attachmentID)
+}
+
+func (r faultyAttachments) ListByPost(ctx context.Context, postID int) ([]models.Attachment, error) {
+	if err := r.faults.check("Attachments.ListByPost"); err != nil {
+		return nil, err
+	}
+	return r.AttachmentRepository.ListByPost(ctx, postID)
+}
+
+func (r faultyAttachments) ListByPostLocked(ctx context.Context, postID int) ([]models.Attachment, error) {
+	if err := r.faults.check("Attachments.ListByPostLocked"); err != nil {
+		return nil, err
+	}
+	return r.AttachmentRepository.ListByPostLocked(ctx, postID)
+}
+
+func (r faultyAttachments) Delete(ctx context.Context, attachmentID int) error {
+	if err := r.faults.check("Attachments.Delete"); err != nil {
+		return err
+	}
+	return r.AttachmentRepository.Delete(ctx, attachmentID)
+}
+
+type faultyAudit struct {
+	repository.AuditRepository
+	faults faults
+}
+
+func (r faultyAudit) Record(ctx context.Context, entry *models.AuditEntry) error {
+	if err := r.faults.check("Audit.Record"); err != nil {
+		return err
+	}
+	return r.AuditRepository.Record(ctx, entry)
+}
+
+func (r faultyAudit) List(ctx context.Context, filter repository.AuditFilter) ([]models.AuditEntry, int, error) {
+	if err := r.faults.check("Audit.List"); err != nil {
+		return nil, 0, err
+	}
+	return r.AuditRepository.List(ctx, filter)
+}
+
+type faultySLAPolicies struct {
+	repository.SLAPolicyRepository
+	faults faults
+}
+
+func (r faultySLAPolicies) List(ctx context.Context, categoryID int) ([]models.SLAPolicy, error) {
+	if err := r.faults.check("SLAPolicies.List"); err != nil {
+		return nil, err
+	}
+	return r.SLAPolicyRepository.List(ctx, categoryID)
+}
+
+func (r faultySLAPolicies) Update(ctx context.Context, policy *models.SLAPolicy) error {
+	if err := r.faults.check("SLAPolicies.Update"); err != nil {
+		return err
+	}
+	return r.SLAPolicyRepository.Update(ctx, policy)
+}
+
+func (r faultySLAPolicies) DeleteOverride(ctx context.Context, categoryID int, priority string) error {
+	if err := r.faults.check("SLAPolicies.DeleteOverride"); err != nil {
+		return err
+	}
+	return r.SLAPolicyRepository.DeleteOverride(ctx, categoryID, priority)
+}
+
+type faultyCategories struct {
+	repository.CategoryRepository
+	faults faults
+}
+
+func (r faultyCategories) Create(ctx context.Context, category *models.Category) error {
+	if err := r.faults.check("Categories.Create"); err != nil {
+		return err
+	}
+	return r.CategoryRepository.Create(ctx, category)
+}
+
+func (r faultyCategories) List(ctx context.Context) ([]models.Category, error) {
+	if err := r.faults.check("Categories.List"); err != nil {
+		return nil, err
+	}
+	return r.CategoryRepository.List(ctx)
+}
+
+func (r faultyCategories) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	if err := r.faults.check("Categories.GetByID"); err != nil {
+		return nil, err
+	}
+	return r.CategoryRepository.GetByID(ctx, id)
+}
+
+func (r faultyCategories) Update(ctx context.Context, category *models.Category) error {
+	if err := r.faults.check("Categories.Update"); err != nil {
+		return err
+	}
+	return r.CategoryRepository.Update(ctx, category)
+}
+
+func (r faultyCategories) Delete(ctx context.Context, id int) error {
+	if err := r.faults.check("Categories.Delete"); err != nil {
+		return err
+	}
+	return r.CategoryRepository.Delete(ctx, id)
+}
+
+type faultyTags struct {
+	repository.TagRepository
+	faults faults
+}
+
+func (r faultyTags) List(ctx context.Context, prefix string, limit int) ([]models.Tag, error) {
+	if err := r.faults.check("Tags.List"); err != nil {
+		return nil, err
+	}
+	return r.TagRepository.List(ctx, prefix, limit)
+}
+
+func (r faultyTags) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	if err := r.faults.check("Tags.SetPostTags"); err != nil {
+		return err
+	}
+	return r.TagRepository.SetPostTags(ctx, postID, tags)
+}
+
+func (r faultyTags) Merge(ctx context.Context, from, into string) (int, error) {
+	if err := r.faults.check("Tags.Merge"); err != nil {
+		return 0, err
+	}
+	return r.TagRepository.Merge(ctx, from, into)
+}
+
+// notifications records what the handler sends instead of delivering it
+type notifications struct {
+	sent []notify.Message
+}
+
+func (n *notifications) Notify(ctx context.Context, msg notify.Message) error {
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+type faultySearch struct {
+	search.Searcher
+	faults faults
+}
+
+func (s faultySearch) Search(ctx context.Context, q search.Query) (*search.Result, error) {
+	if err := s.faults.check("Search"); err != nil {
+		return nil, err
+	}
+	return s.Searcher.Search(ctx, q)
+}
+
+// testEnv is the Fiber app from routes.SetupRoutes backed by a MemoryStore
+type testEnv struct {
+	app       *fiber.App
+	handler   *controllers.Handler
+	store     *repository.MemoryStore
+	storage   *storage.Local
+	notified  *notifications
+	uploadDir string
+	cacheDir  string
+}
+
+// newTestEnv builds the app with the named repository methods failing
+func newTestEnv(t *testing.T, fail ...string) *testEnv {
+	t.Helper()
+
+	f := faults{}
+	for _, method := range fail {
+		f[method] = true
+	}
+
+	// Tick the clock one second per write so listings have a stable order
+	store := repository.NewMemoryStore()
+	clock := time.Date(2024, 11, 1, 10, 0, 0, 0, time.UTC)
+	store.Now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	e := &testEnv{
+		app:       fiber.New(),
+		store:     store,
+		uploadDir: t.TempDir(),
+		cacheDir:  t.TempDir(),
+		notified:  &notifications{},
+	}
+	e.storage = storage.NewLocal(e.uploadDir, "/files", []byte("test-secret"))
+	e.handler = &controllers.Handler{
+		Users:            faultyUsers{store.Users(), f},
+		Posts:            faultyPosts{store.Posts(), f},
+		Comments:         faultyComments{store.Comments(), f},
+		Attachments:      faultyAttachments{store.Attachments(), f},
+		Audit:            faultyAudit{store.Audit(), f},
+		Categories:       faultyCategories{store.Categories(), f},
+		Tags:             faultyTags{store.Tags(), f},
+		SLAPolicies:      faultySLAPolicies{store.SLAPolicies(), f},
+		Tx:               store,
+		Search:           faultySearch{store, f},
+		Google:           auth.NewGoogleVerifier(staticKeys{}, []string{testClientID}),
+		Tokens:           testTokens,
+		Uploads:          config.UploadConfig{MaxBytes: testMaxUpload, MaxDimension: testMaxPixels, CacheDir: e.cacheDir, CacheMaxAge: time.Minute},
+		AttachmentLimits: config.AttachmentConfig{MaxBytes: testMaxAttachment, MaxPerPost: testMaxAttachments, MaxTotalBytes: testMaxAttachmentTotal},
+		Storage:          e.storage,
+		Trash:            config.TrashConfig{Retention: 24 * time.Hour, PurgeInterval: time.Hour},
+		SLA:              config.SLAConfig{CheckInterval: time.Minute, AtRiskWindow: time.Hour},
+		Notify:           e.notified,
+	}
+	routes.SetupRoutes(e.app, e.handler)
+	e.seed(t)
+	return e
+}
+
+// seed creates the users in the constants above, two posts and a comment
+func (e *testEnv) seed(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+
+	users := []models.User{
+		{ID_user: alice, Email: "alice@example.com", Name: "Alice", Role: models.RoleCustomer},
+		{ID_user: bob, Email: "bob@example.com", Name: "Bob", Role: models.RoleCustomer},
+		{ID_user: mod, Email: "mod@example.com", Name: "Mod", Role: models.RoleModerator},
+		{ID_user: admin, Email: "admin@example.com", Name: "Admin", Role: models.RoleAdmin},
+		{ID_user: agent, Email: "agent@example.com", Name: "Agent", Role: models.RoleAgent},
+	}
+	for _, user := range users {
+		if err := e.store.Users().Create(ctx, &user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	posts := []models.Post{
+		{Title: "Transfer failed", Content: "My bank transfer failed twice", ID_user: alice, ID_category: general},
+		{Title: "Card blocked", Content: "How do I unblock my card?", ID_user: bob, ID_category: general},
+	}
+	for _, post := range posts {
+		if err := e.store.Posts().Create(ctx, &post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	comment := models.Comment{ID_post: 1, ID_user: bob, Content: "Same here, the transfer failed"}
+	if err := e.store.Comments().Create(ctx, &comment); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// multipartFile is a request body uploading one file
+type multipartFile struct {
+	field    string
+	filename string
+	data     []byte
+}
+
+// apiRequest describes a request to the test app
+type apiRequest struct {
+	method string
+	path   string
+	body   any // string is sent as-is, multipartFile as a form, anything else as JSON
+	as     int // id_user of the caller, 0 for anonymous
+	header map[string]string
+}
+
+// do sends the request and returns the response with its body
+func (e *testEnv) do(t *testing.T, r apiRequest) (*http.Response, []byte) {
+	t.Helper()
+
+	var body io.Reader
+	contentType := fiber.MIMEApplicationJSON
+	switch b := r.body.(type) {
+	case nil:
+	case string:
+		body = bytes.NewBufferString(b)
+	case multipartFile:
+		buf := &bytes.Buffer{}
+		w := multipart.NewWriter(buf)
+		part, err := w.CreateFormFile(b.field, b.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(b.data)
+		w.Close()
+		body, contentType = buf, w.FormDataContentType()
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(r.method, r.path, body)
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	if r.as != 0 {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+mustIssue(r.as).AccessToken)
+	}
+	for name, value := range r.header {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+// routeCase is one request against a freshly seeded app
+type routeCase struct {
+	name   string
+	fail   []string // Repository methods returning errInjected
+	setup  func(t *testing.T, e *testEnv)
+	req    apiRequest
+	status int
+	check  func(t *testing.T, e *testEnv, resp *http.Response, body []byte)
+}
+
+func runRouteCases(t *testing.T, cases []routeCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestEnv(t, tc.fail...)
+			if tc.setup != nil {
+				tc.setup(t, e)
+			}
+
+			resp, body := e.do(t, tc.req)
+			if resp.StatusCode != tc.status {
+				t.Fatalf("%s %s: status = %d, want %d, body: %s", tc.req.method, tc.req.path, resp.StatusCode, tc.status, body)
+			}
+			if tc.check != nil {
+				tc.check(t, e, resp, body)
+			}
+		})
+	}
+}
+
+// decode unmarshals a JSON response body
+func decode[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+	return v
+}
+
+// wantField fails unless the JSON object has the field with the given value
+func wantField(t *testing.T, body []byte, field string, want any) {
+	t.Helper()
+	got := decode[map[string]any](t, body)[field]
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s = %v, want %v", field, got, want)
+	}
+}
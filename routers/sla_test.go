@@ -0,0 +1,376 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// setPriority gives a seeded post a priority and the deadlines of its policy
+func setPriority(t *testing.T, e *testEnv, postID int, priority string) *models.Post {
+	t.Helper()
+	ctx := context.Background()
+	post, err := e.store.Posts().GetByID(ctx, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := e.store.SLAPolicies().Get(ctx, post.ID_category, priority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstResponse, resolution := policy.Deadlines(post.CreatedAt)
+	if err := e.store.Posts().SetPriority(ctx, postID, priority, firstResponse, resolution); err != nil {
+		t.Fatal(err)
+	}
+	post, _ = e.store.Posts().GetByID(ctx, postID)
+	return post
+}
+
+// stopClock makes the store's current time the opening of post 1 plus d
+func stopClock(t *testing.T, e *testEnv, d time.Duration) {
+	t.Helper()
+	post, err := e.store.Posts().GetByID(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := post.CreatedAt.Add(d)
+	e.store.Now = func() time.Time { return now }
+}
+
+func TestCreatePostAppliesSLA(t *testing.T) {
+	e := newTestEnv(t)
+	resp, body := e.do(t, apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "Loan", "content": "Where is my loan?", "category_id": general}, as: alice})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, body: %s", resp.StatusCode, body)
+	}
+
+	post, err := e.store.Posts().GetByID(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Priority != models.PriorityNormal || post.SLA.FirstResponseDue == nil || post.SLA.ResolutionDue == nil {
+		t.Fatalf("post = %+v", post)
+	}
+	if got := post.SLA.FirstResponseDue.Sub(post.CreatedAt); got != 8*time.Hour {
+		t.Errorf("first response due after %s, want 8h", got)
+	}
+	if got := post.SLA.ResolutionDue.Sub(post.CreatedAt); got != 72*time.Hour {
+		t.Errorf("resolution due after %s, want 72h", got)
+	}
+}
+
+func TestSetPostPriority(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by agent",
+			req:    apiRequest{method: "PUT", path: "/posts/1/priority", body: map[string]any{"priority": "urgent"}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "priority", models.PriorityUrgent)
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.Priority != models.PriorityUrgent || post.SLA.FirstResponseDue == nil {
+					t.Fatalf("stored post = %+v", post)
+				}
+				if got := post.SLA.FirstResponseDue.Sub(post.CreatedAt); got != 30*time.Minute {
+					t.Errorf("first response due after %s, want 30m", got)
+				}
+				if got := post.SLA.ResolutionDue.Sub(post.CreatedAt); got != 4*time.Hour {
+					t.Errorf("resolution due after %s, want 4h", got)
+				}
+				sla := decode[struct {
+					SLA map[string]any `json:"sla"`
+				}](t, body).SLA
+				if sla["first_response_due"] != post.SLA.FirstResponseDue.Format("2006-01-02 15:04:05") {
+					t.Errorf("sla = %v", sla)
+				}
+				entry := wantAudit(t, e, models.AuditPostPriority, 1, agent)
+				if auditField(t, entry.Before, "priority") != models.PriorityNormal || auditField(t, entry.After, "priority") != models.PriorityUrgent {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name: "clears breaches",
+			setup: func(t *testing.T, e *testEnv) {
+				post := setPriority(t, e, 1, models.PriorityUrgent)
+				if _, err := e.handler.CheckSLA(context.Background(), post.SLA.ResolutionDue.Add(time.Minute)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    apiRequest{method: "PUT", path: "/posts/1/priority", body: map[string]any{"priority": "low"}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.SLA.FirstResponseBreachedAt != nil || post.SLA.ResolutionBreachedAt != nil {
+					t.Errorf("sla = %+v", post.SLA)
+				}
+			},
+		},
+		{
+			name:   "invalid priority",
+			req:    apiRequest{method: "PUT", path: "/posts/1/priority", body: map[string]any{"priority": "asap"}, as: agent},
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "priorities", models.Priorities)
+			},
+		},
+		{name: "invalid body", req: apiRequest{method: "PUT", path: "/posts/1/priority", body: "{", as: agent}, status: http.StatusBadRequest},
+		{name: "by author", req: apiRequest{method: "PUT", path: "/posts/1/priority", body: map[string]any{"priority": "urgent"}, as: alice}, status: http.StatusForbidden},
+		{name: "not found", req: apiRequest{method: "PUT", path: "/posts/99/priority", body: map[string]any{"priority": "urgent"}, as: agent}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.SetPriority"}, req: apiRequest{method: "PUT", path: "/posts/1/priority", body: map[string]any{"priority": "urgent"}, as: agent}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestFirstResponse(t *testing.T) {
+	comment := func(as int) apiRequest {
+		return apiRequest{method: "POST", path: "/posts/1/comments", body: map[string]any{"content": "Looking into it"}, as: as}
+	}
+	responded := func(t *testing.T, e *testEnv) bool {
+		post, _ := e.store.Posts().GetByID(context.Background(), 1)
+		return post.SLA.FirstResponseAt != nil
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "agent comment",
+			req:    comment(agent),
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if !responded(t, e) {
+					t.Error("first response not recorded")
+				}
+			},
+		},
+		{
+			name:   "customer comment",
+			req:    comment(bob),
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if responded(t, e) {
+					t.Error("customer comment counted as first response")
+				}
+			},
+		},
+		{
+			name: "later staff comment",
+			setup: func(t *testing.T, e *testEnv) {
+				if err := e.store.Posts().MarkFirstResponse(context.Background(), 1, time.Date(2024, 11, 1, 9, 0, 0, 0, time.UTC)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    comment(mod),
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.SLA.FirstResponseAt.Hour() != 9 {
+					t.Errorf("first response moved to %s", post.SLA.FirstResponseAt)
+				}
+			},
+		},
+		{name: "database error", fail: []string{"Posts.MarkFirstResponse"}, req: comment(agent), status: http.StatusInternalServerError},
+	})
+}
+
+func TestSLAListings(t *testing.T) {
+	// Post 1 is urgent, due in 30m and 4h; post 2 is normal, due in 8h and 72h
+	prioritize := func(t *testing.T, e *testEnv) {
+		setPriority(t, e, 1, models.PriorityUrgent)
+		setPriority(t, e, 2, models.PriorityNormal)
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "breached",
+			setup:  func(t *testing.T, e *testEnv) { prioritize(t, e); stopClock(t, e, time.Hour) },
+			req:    apiRequest{method: "GET", path: "/sla/breached", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				page := decode[ticketPage](t, body)
+				if page.Total != 1 || page.Data[0].ID_Posts != 1 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{
+			name: "answered in time",
+			setup: func(t *testing.T, e *testEnv) {
+				prioritize(t, e)
+				if err := e.store.Posts().MarkFirstResponse(context.Background(), 1, time.Date(2024, 11, 1, 10, 10, 0, 0, time.UTC)); err != nil {
+					t.Fatal(err)
+				}
+				stopClock(t, e, time.Hour)
+			},
+			req:    apiRequest{method: "GET", path: "/sla/breached", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if page := decode[ticketPage](t, body); page.Total != 0 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{
+			name: "resolved tickets are not breached",
+			setup: func(t *testing.T, e *testEnv) {
+				prioritize(t, e)
+				moveTicket(t, e, 1, models.StatusOpen, models.StatusResolved)
+				stopClock(t, e, 5*time.Hour)
+			},
+			req:    apiRequest{method: "GET", path: "/sla/breached", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if page := decode[ticketPage](t, body); page.Total != 0 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{
+			name:   "at risk",
+			setup:  func(t *testing.T, e *testEnv) { prioritize(t, e); stopClock(t, e, 7*time.Hour+30*time.Minute) },
+			req:    apiRequest{method: "GET", path: "/sla/at-risk", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				page := decode[ticketPage](t, body)
+				if page.Total != 1 || page.Data[0].ID_Posts != 2 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{
+			name:   "nothing at risk yet",
+			setup:  func(t *testing.T, e *testEnv) { prioritize(t, e); stopClock(t, e, -time.Hour) },
+			req:    apiRequest{method: "GET", path: "/sla/at-risk", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if page := decode[ticketPage](t, body); page.Total != 0 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{name: "customer", req: apiRequest{method: "GET", path: "/sla/breached", as: alice}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "GET", path: "/sla/at-risk"}, status: http.StatusUnauthorized},
+		{name: "invalid filter", req: apiRequest{method: "GET", path: "/sla/breached?limit=0", as: agent}, status: http.StatusBadRequest},
+		{name: "database error", fail: []string{"Posts.List"}, req: apiRequest{method: "GET", path: "/sla/breached", as: agent}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestCheckSLA(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+
+	// Post 1 is assigned to the agent and post 2 to nobody, both urgent
+	post := setPriority(t, e, 1, models.PriorityUrgent)
+	setPriority(t, e, 2, models.PriorityUrgent)
+	assignTicket(t, e, 1, agent)
+
+	recipients := func() map[int]int {
+		got := map[int]int{}
+		for _, msg := range e.notified.sent {
+			got[msg.ID_user]++
+		}
+		e.notified.sent = nil
+		return got
+	}
+
+	// A missed first response goes to the assignee, or to supervisors when
+	// nobody is assigned
+	flagged, err := e.handler.CheckSLA(ctx, post.CreatedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flagged != 2 {
+		t.Errorf("flagged %d breaches, want 2", flagged)
+	}
+	if got := recipients(); len(got) != 3 || got[agent] != 1 || got[mod] != 1 || got[admin] != 1 {
+		t.Errorf("notified %v", got)
+	}
+	stored, _ := e.store.Posts().GetByID(ctx, 1)
+	if stored.SLA.FirstResponseBreachedAt == nil || stored.SLA.ResolutionBreachedAt != nil {
+		t.Errorf("sla = %+v", stored.SLA)
+	}
+	entry := auditEntries(t, e)[0]
+	if entry.Action != models.AuditPostSLABreach || entry.ID_actor != nil || auditField(t, entry.After, "kind") != models.SLAFirstResponse {
+		t.Errorf("audit entry = %+v", entry)
+	}
+
+	// Breaches are flagged once
+	if flagged, err := e.handler.CheckSLA(ctx, post.CreatedAt.Add(2*time.Hour)); err != nil || flagged != 0 {
+		t.Errorf("second check flagged %d, %v", flagged, err)
+	}
+	if got := recipients(); len(got) != 0 {
+		t.Errorf("notified again %v", got)
+	}
+
+	// A missed resolution also escalates to supervisors
+	if flagged, err := e.handler.CheckSLA(ctx, post.CreatedAt.Add(5*time.Hour)); err != nil || flagged != 2 {
+		t.Errorf("resolution check flagged %d, %v", flagged, err)
+	}
+	if got := recipients(); got[agent] != 1 || got[mod] != 2 || got[admin] != 2 || got[alice] != 0 {
+		t.Errorf("notified %v", got)
+	}
+}
+
+func TestCheckSLAError(t *testing.T) {
+	e := newTestEnv(t, "Posts.FlagSLABreach")
+	post := setPriority(t, e, 1, models.PriorityUrgent)
+
+	if _, err := e.handler.CheckSLA(context.Background(), post.CreatedAt.Add(time.Hour)); err == nil {
+		t.Fatal("expected the injected error")
+	}
+	if len(e.notified.sent) != 0 {
+		t.Errorf("notified %d users of an unflagged breach", len(e.notified.sent))
+	}
+}
+
+func TestSLAPolicies(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "list",
+			req:    apiRequest{method: "GET", path: "/sla/policies", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				policies := decode[[]models.SLAPolicy](t, body)
+				if len(policies) != 4 || policies[0].Priority != models.PriorityLow || policies[3].FirstResponseMinutes != 30 {
+					t.Errorf("policies = %s", body)
+				}
+			},
+		},
+		{name: "list as customer", req: apiRequest{method: "GET", path: "/sla/policies", as: alice}, status: http.StatusForbidden},
+		{name: "list database error", fail: []string{"SLAPolicies.List"}, req: apiRequest{method: "GET", path: "/sla/policies", as: agent}, status: http.StatusInternalServerError},
+		{
+			name:   "update",
+			req:    apiRequest{method: "PUT", path: "/sla/policies/high", body: map[string]any{"first_response_minutes": 60, "resolution_minutes": 720}, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "priority", models.PriorityHigh)
+				policy, _ := e.store.SLAPolicies().Get(context.Background(), 0, models.PriorityHigh)
+				if policy.FirstResponseMinutes != 60 || policy.ResolutionMinutes != 720 {
+					t.Errorf("policy = %+v", policy)
+				}
+				entry := wantAudit(t, e, models.AuditSLAPolicyUpdate, 0, admin)
+				if entry.TargetType != models.AuditTargetSLAPolicy || auditField(t, entry.Before, "first_response_minutes") != 120.0 || auditField(t, entry.After, "first_response_minutes") != 60.0 {
+					t.Errorf("audit entry = %+v", entry)
+				}
+			},
+		},
+		{name: "update as moderator", req: apiRequest{method: "PUT", path: "/sla/policies/high", body: map[string]any{"first_response_minutes": 60, "resolution_minutes": 720}, as: mod}, status: http.StatusForbidden},
+		{name: "unknown priority", req: apiRequest{method: "PUT", path: "/sla/policies/asap", body: map[string]any{"first_response_minutes": 60, "resolution_minutes": 720}, as: admin}, status: http.StatusNotFound},
+		{name: "zero target", req: apiRequest{method: "PUT", path: "/sla/policies/high", body: map[string]any{"first_response_minutes": 0, "resolution_minutes": 720}, as: admin}, status: http.StatusBadRequest},
+		{name: "first response after resolution", req: apiRequest{method: "PUT", path: "/sla/policies/high", body: map[string]any{"first_response_minutes": 800, "resolution_minutes": 720}, as: admin}, status: http.StatusBadRequest},
+		{name: "invalid body", req: apiRequest{method: "PUT", path: "/sla/policies/high", body: "{", as: admin}, status: http.StatusBadRequest},
+		{
+			name:   "update audit error",
+			fail:   []string{"Audit.Record"},
+			req:    apiRequest{method: "PUT", path: "/sla/policies/high", body: map[string]any{"first_response_minutes": 60, "resolution_minutes": 720}, as: admin},
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				// The change rolls back with its audit entry
+				if policy, _ := e.store.SLAPolicies().Get(context.Background(), 0, models.PriorityHigh); policy.FirstResponseMinutes != 120 {
+					t.Errorf("policy = %+v", policy)
+				}
+			},
+		},
+		{name: "update database error", fail: []string{"SLAPolicies.Update"}, req: apiRequest{method: "PUT", path: "/sla/policies/high", body: map[string]any{"first_response_minutes": 60, "resolution_minutes": 720}, as: admin}, status: http.StatusInternalServerError},
+	})
+}
@@ -0,0 +1,246 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// tagPost replaces the tags of a seeded post
+func tagPost(t *testing.T, e *testEnv, postID int, tags ...string) {
+	t.Helper()
+	if err := e.store.Tags().SetPostTags(context.Background(), postID, tags); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tagSeededPosts tags post 1 with otp and transfer-failed, and post 2 with otp
+func tagSeededPosts(t *testing.T, e *testEnv) {
+	t.Helper()
+	tagPost(t, e, 1, "otp", "transfer-failed")
+	tagPost(t, e, 2, "otp")
+}
+
+// wantTags fails unless the stored post carries exactly the tags
+func wantTags(t *testing.T, e *testEnv, postID int, want ...string) {
+	t.Helper()
+	post, err := e.store.Posts().GetByID(context.Background(), postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(post.Tags, want) {
+		t.Errorf("post %d tags = %v, want %v", postID, post.Tags, want)
+	}
+}
+
+func TestCreatePostWithTags(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "normalized",
+			req:    apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c", "category_id": general, "tags": []string{"Transfer-Failed", " OTP ", "otp"}}, as: alice},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantTags(t, e, 3, "otp", "transfer-failed")
+				entry := wantAudit(t, e, models.AuditPostCreate, 3, alice)
+				if tags := auditField(t, entry.After, "tags").([]any); len(tags) != 2 {
+					t.Errorf("after %s", entry.After)
+				}
+			},
+		},
+		{
+			name:   "without tags",
+			req:    apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c", "category_id": general}, as: alice},
+			status: http.StatusCreated,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantTags(t, e, 3)
+			},
+		},
+		{name: "invalid tag", req: apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c", "category_id": general, "tags": []string{"two words"}}, as: alice}, status: http.StatusBadRequest},
+		{name: "too many tags", req: apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c", "category_id": general, "tags": []string{"a", "b", "c", "d", "e", "f"}}, as: alice}, status: http.StatusBadRequest},
+		{name: "database error", fail: []string{"Tags.SetPostTags"}, req: apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c", "category_id": general, "tags": []string{"otp"}}, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestSetPostTags(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by author",
+			setup:  tagSeededPosts,
+			req:    apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{"login", "OTP", "otp"}}, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "tags", []string{"login", "otp"})
+				wantTags(t, e, 1, "login", "otp")
+				entry := wantAudit(t, e, models.AuditPostTags, 1, alice)
+				if before := auditField(t, entry.Before, "tags").([]any); len(before) != 2 || before[1] != "transfer-failed" {
+					t.Errorf("before %s", entry.Before)
+				}
+			},
+		},
+		{
+			name:   "by agent",
+			req:    apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{"transfer-failed"}}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantTags(t, e, 1, "transfer-failed")
+			},
+		},
+		{
+			name:   "cleared",
+			setup:  tagSeededPosts,
+			req:    apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{}}, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantTags(t, e, 1)
+			},
+		},
+		{name: "by another customer", req: apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{"otp"}}, as: bob}, status: http.StatusForbidden},
+		{name: "invalid tag", req: apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{"otp!"}}, as: alice}, status: http.StatusBadRequest},
+		{name: "too many tags", req: apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{"a", "b", "c", "d", "e", "f"}}, as: alice}, status: http.StatusBadRequest},
+		{name: "invalid body", req: apiRequest{method: "PUT", path: "/posts/1/tags", body: "{", as: alice}, status: http.StatusBadRequest},
+		{name: "post not found", req: apiRequest{method: "PUT", path: "/posts/99/tags", body: map[string]any{"tags": []string{"otp"}}, as: agent}, status: http.StatusNotFound},
+		{name: "anonymous", req: apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{"otp"}}}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Tags.SetPostTags"}, req: apiRequest{method: "PUT", path: "/posts/1/tags", body: map[string]any{"tags": []string{"otp"}}, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetTags(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "most used first",
+			setup:  tagSeededPosts,
+			req:    apiRequest{method: "GET", path: "/tags"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				tags := decode[[]models.Tag](t, body)
+				if len(tags) != 2 || tags[0].Name != "otp" || tags[0].PostCount != 2 || tags[1].Name != "transfer-failed" || tags[1].PostCount != 1 {
+					t.Errorf("tags = %+v", tags)
+				}
+			},
+		},
+		{
+			name:   "by prefix",
+			setup:  tagSeededPosts,
+			req:    apiRequest{method: "GET", path: "/tags?prefix=Tr"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if tags := decode[[]models.Tag](t, body); len(tags) != 1 || tags[0].Name != "transfer-failed" {
+					t.Errorf("tags = %+v", tags)
+				}
+			},
+		},
+		{
+			name: "trashed and untagged posts not counted",
+			setup: func(t *testing.T, e *testEnv) {
+				tagSeededPosts(t, e)
+				tagPost(t, e, 1, "otp")
+				if err := e.store.Posts().Delete(context.Background(), 2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:    apiRequest{method: "GET", path: "/tags"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if tags := decode[[]models.Tag](t, body); len(tags) != 1 || tags[0].Name != "otp" || tags[0].PostCount != 1 {
+					t.Errorf("tags = %+v", tags)
+				}
+			},
+		},
+		{
+			name:   "limited",
+			setup:  tagSeededPosts,
+			req:    apiRequest{method: "GET", path: "/tags?limit=1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if tags := decode[[]models.Tag](t, body); len(tags) != 1 {
+					t.Errorf("tags = %+v", tags)
+				}
+			},
+		},
+		{name: "invalid limit", req: apiRequest{method: "GET", path: "/tags?limit=51"}, status: http.StatusBadRequest},
+		{name: "database error", fail: []string{"Tags.List"}, req: apiRequest{method: "GET", path: "/tags"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetTagPosts(t *testing.T) {
+	listed := func(want ...int) func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+		return func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+			page := decode[ticketPage](t, body)
+			if page.Total != len(want) || len(page.Data) != len(want) {
+				t.Fatalf("page = %+v, want posts %v", page, want)
+			}
+			for i, id := range want {
+				if page.Data[i].ID_Posts != id {
+					t.Errorf("post %d = %d, want %d", i, page.Data[i].ID_Posts, id)
+				}
+			}
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{name: "shared tag", setup: tagSeededPosts, req: apiRequest{method: "GET", path: "/tags/otp/posts"}, status: http.StatusOK, check: listed(2, 1)},
+		{name: "normalized", setup: tagSeededPosts, req: apiRequest{method: "GET", path: "/tags/Transfer-Failed/posts"}, status: http.StatusOK, check: listed(1)},
+		{name: "with listing parameters", setup: tagSeededPosts, req: apiRequest{method: "GET", path: "/tags/otp/posts?user=2"}, status: http.StatusOK, check: listed(2)},
+		{name: "unknown tag", req: apiRequest{method: "GET", path: "/tags/loans/posts"}, status: http.StatusOK, check: listed()},
+		{name: "listing filter", setup: tagSeededPosts, req: apiRequest{method: "GET", path: "/posts?tag=transfer-failed"}, status: http.StatusOK, check: listed(1)},
+		{name: "invalid tag", req: apiRequest{method: "GET", path: "/tags/otp%21/posts"}, status: http.StatusBadRequest},
+		{name: "invalid listing filter", req: apiRequest{method: "GET", path: "/posts?tag=otp%21"}, status: http.StatusBadRequest},
+		{name: "database error", fail: []string{"Posts.List"}, req: apiRequest{method: "GET", path: "/tags/otp/posts"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestSearchPostsByTag(t *testing.T) {
+	hits := func(want int) func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+		return func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+			wantField(t, body, "total", want)
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{name: "post and its comments", setup: tagSeededPosts, req: apiRequest{method: "GET", path: "/posts/search?q=transfer&tag=transfer-failed"}, status: http.StatusOK, check: hits(2)},
+		{name: "normalized", setup: tagSeededPosts, req: apiRequest{method: "GET", path: "/posts/search?q=card&tag=OTP"}, status: http.StatusOK, check: hits(1)},
+		{name: "untagged", setup: tagSeededPosts, req: apiRequest{method: "GET", path: "/posts/search?q=card&tag=transfer-failed"}, status: http.StatusOK, check: hits(0)},
+		{name: "invalid", req: apiRequest{method: "GET", path: "/posts/search?q=card&tag=one%20time"}, status: http.StatusBadRequest},
+	})
+}
+
+func TestMergeTag(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "into an existing tag",
+			setup:  tagSeededPosts,
+			req:    apiRequest{method: "POST", path: "/tags/transfer-failed/merge", body: map[string]any{"into": "otp"}, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "posts", 1)
+				wantTags(t, e, 1, "otp")
+				wantTags(t, e, 2, "otp")
+				if _, err := e.store.Tags().GetByName(context.Background(), "transfer-failed"); err == nil {
+					t.Error("merged tag still stored")
+				}
+				entry := wantAudit(t, e, models.AuditTagMerge, 2, admin)
+				if auditField(t, entry.Before, "name") != "transfer-failed" || auditField(t, entry.After, "post_count") != float64(2) {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name:   "rename",
+			setup:  tagSeededPosts,
+			req:    apiRequest{method: "POST", path: "/tags/otp/merge", body: map[string]any{"into": "One-Time-Password"}, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "posts", 2)
+				wantTags(t, e, 1, "one-time-password", "transfer-failed")
+				wantTags(t, e, 2, "one-time-password")
+			},
+		},
+		{name: "not found", req: apiRequest{method: "POST", path: "/tags/otp/merge", body: map[string]any{"into": "login"}, as: admin}, status: http.StatusNotFound},
+		{name: "into itself", setup: tagSeededPosts, req: apiRequest{method: "POST", path: "/tags/otp/merge", body: map[string]any{"into": "OTP"}, as: admin}, status: http.StatusBadRequest},
+		{name: "invalid target", setup: tagSeededPosts, req: apiRequest{method: "POST", path: "/tags/otp/merge", body: map[string]any{"into": "one time"}, as: admin}, status: http.StatusBadRequest},
+		{name: "by moderator", setup: tagSeededPosts, req: apiRequest{method: "POST", path: "/tags/otp/merge", body: map[string]any{"into": "login"}, as: mod}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Tags.Merge"}, setup: tagSeededPosts, req: apiRequest{method: "POST", path: "/tags/otp/merge", body: map[string]any{"into": "login"}, as: admin}, status: http.StatusInternalServerError},
+	})
+}
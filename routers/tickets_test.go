@@ -0,0 +1,255 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"context"
+	"net/http"
+	"testing"
+)
+
+// moveTicket changes the status of a seeded post as the agent
+func moveTicket(t *testing.T, e *testEnv, postID int, from, to string) {
+	t.Helper()
+	if _, err := e.store.Posts().SetStatus(context.Background(), postID, from, to, agent); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assignTicket assigns a seeded post
+func assignTicket(t *testing.T, e *testEnv, postID, assigneeID int) {
+	t.Helper()
+	if err := e.store.Posts().Assign(context.Background(), postID, &assigneeID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ticketPage is the part of a post listing the ticket tests look at
+type ticketPage struct {
+	Data []struct {
+		ID_Posts int    `json:"id_posts"`
+		Status   string `json:"status"`
+	} `json:"data"`
+	Total int `json:"total"`
+}
+
+func TestSetPostStatus(t *testing.T) {
+	resolve := func(t *testing.T, e *testEnv) { moveTicket(t, e, 1, models.StatusOpen, models.StatusResolved) }
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by agent",
+			req:    apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "in_progress"}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "status", models.StatusInProgress)
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.Status != models.StatusInProgress || !post.StatusChangedAt.After(post.CreatedAt) {
+					t.Errorf("stored post = %+v", post)
+				}
+				changes, _ := e.store.Posts().ListStatusChanges(context.Background(), 1)
+				if len(changes) != 1 || changes[0].FromStatus != models.StatusOpen || *changes[0].ID_actor != agent {
+					t.Errorf("changes = %+v", changes)
+				}
+				entry := wantAudit(t, e, models.AuditPostStatus, 1, agent)
+				if auditField(t, entry.Before, "status") != models.StatusOpen || auditField(t, entry.After, "status") != models.StatusInProgress {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{name: "author closes", req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "closed"}, as: alice}, status: http.StatusOK},
+		{name: "author reopens resolved", setup: resolve, req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "open"}, as: alice}, status: http.StatusOK},
+		{
+			name:   "author reopens closed",
+			setup:  func(t *testing.T, e *testEnv) { moveTicket(t, e, 1, models.StatusOpen, models.StatusClosed) },
+			req:    apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "open"}, as: alice},
+			status: http.StatusForbidden,
+		},
+		{name: "author starts work", req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "in_progress"}, as: alice}, status: http.StatusForbidden},
+		{name: "another customer", req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "closed"}, as: bob}, status: http.StatusForbidden},
+		{
+			name:   "invalid transition",
+			setup:  resolve,
+			req:    apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "awaiting_customer"}, as: agent},
+			status: http.StatusConflict,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "status", models.StatusResolved)
+				wantField(t, body, "allowed", []string{models.StatusOpen, models.StatusClosed})
+			},
+		},
+		{name: "same status", req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "open"}, as: agent}, status: http.StatusConflict},
+		{name: "invalid status", req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "done"}, as: agent}, status: http.StatusBadRequest},
+		{name: "invalid body", req: apiRequest{method: "POST", path: "/posts/1/status", body: "{", as: agent}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "POST", path: "/posts/99/status", body: map[string]any{"status": "closed"}, as: agent}, status: http.StatusNotFound},
+		{name: "anonymous", req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "closed"}}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Posts.SetStatus"}, req: apiRequest{method: "POST", path: "/posts/1/status", body: map[string]any{"status": "closed"}, as: agent}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetPostStatusHistory(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "oldest first",
+			setup: func(t *testing.T, e *testEnv) {
+				moveTicket(t, e, 1, models.StatusOpen, models.StatusInProgress)
+				moveTicket(t, e, 1, models.StatusInProgress, models.StatusResolved)
+			},
+			req:    apiRequest{method: "GET", path: "/posts/1/status-history"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				changes := decode[[]map[string]any](t, body)
+				if len(changes) != 2 || changes[0]["to_status"] != models.StatusInProgress || changes[1]["to_status"] != models.StatusResolved {
+					t.Fatalf("changes = %s", body)
+				}
+				if changes[1]["created_at"] == "" || changes[1]["id_actor"] != float64(agent) {
+					t.Errorf("change = %v", changes[1])
+				}
+			},
+		},
+		{name: "not found", req: apiRequest{method: "GET", path: "/posts/99/status-history"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.ListStatusChanges"}, req: apiRequest{method: "GET", path: "/posts/1/status-history"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestAssignPost(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "agent takes a ticket",
+			req:    apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": agent}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				post, _ := e.store.Posts().GetByID(context.Background(), 1)
+				if post.ID_assignee == nil || *post.ID_assignee != agent {
+					t.Errorf("assignee = %v", post.ID_assignee)
+				}
+				entry := wantAudit(t, e, models.AuditPostAssign, 1, agent)
+				if auditField(t, entry.Before, "id_assignee") != nil || auditField(t, entry.After, "id_assignee") != float64(agent) {
+					t.Errorf("before %s, after %s", entry.Before, entry.After)
+				}
+			},
+		},
+		{
+			name:   "agent gives a ticket back",
+			setup:  func(t *testing.T, e *testEnv) { assignTicket(t, e, 1, agent) },
+			req:    apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": nil}, as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if post, _ := e.store.Posts().GetByID(context.Background(), 1); post.ID_assignee != nil {
+					t.Errorf("assignee = %v", *post.ID_assignee)
+				}
+			},
+		},
+		{name: "agent assigns someone else", req: apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": mod}, as: agent}, status: http.StatusForbidden},
+		{
+			name:   "agent takes another agent's ticket",
+			setup:  func(t *testing.T, e *testEnv) { assignTicket(t, e, 1, mod) },
+			req:    apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": agent}, as: agent},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "moderator reassigns",
+			setup:  func(t *testing.T, e *testEnv) { assignTicket(t, e, 1, mod) },
+			req:    apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": agent}, as: mod},
+			status: http.StatusOK,
+		},
+		{name: "customer assignee", req: apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": bob}, as: mod}, status: http.StatusBadRequest},
+		{name: "unknown assignee", req: apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": 99}, as: mod}, status: http.StatusBadRequest},
+		{name: "customer", req: apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": agent}, as: alice}, status: http.StatusForbidden},
+		{name: "not found", req: apiRequest{method: "PUT", path: "/posts/99/assignee", body: map[string]any{"id_assignee": agent}, as: agent}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Posts.Assign"}, req: apiRequest{method: "PUT", path: "/posts/1/assignee", body: map[string]any{"id_assignee": agent}, as: agent}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetMyQueue(t *testing.T) {
+	assignBoth := func(t *testing.T, e *testEnv) {
+		assignTicket(t, e, 1, agent)
+		assignTicket(t, e, 2, agent)
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "active tickets, oldest first",
+			setup:  assignBoth,
+			req:    apiRequest{method: "GET", path: "/posts/queue", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				got := decode[ticketPage](t, body)
+				if got.Total != 2 || got.Data[0].ID_Posts != 1 || got.Data[1].ID_Posts != 2 {
+					t.Errorf("queue = %s", body)
+				}
+			},
+		},
+		{
+			name: "resolved tickets leave the queue",
+			setup: func(t *testing.T, e *testEnv) {
+				assignBoth(t, e)
+				moveTicket(t, e, 2, models.StatusOpen, models.StatusResolved)
+			},
+			req:    apiRequest{method: "GET", path: "/posts/queue", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := decode[ticketPage](t, body); got.Total != 1 || got.Data[0].ID_Posts != 1 {
+					t.Errorf("queue = %s", body)
+				}
+			},
+		},
+		{
+			name: "by status",
+			setup: func(t *testing.T, e *testEnv) {
+				assignBoth(t, e)
+				moveTicket(t, e, 2, models.StatusOpen, models.StatusResolved)
+			},
+			req:    apiRequest{method: "GET", path: "/posts/queue?status=resolved", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := decode[ticketPage](t, body); got.Total != 1 || got.Data[0].Status != models.StatusResolved {
+					t.Errorf("queue = %s", body)
+				}
+			},
+		},
+		{
+			name:   "only the caller's tickets",
+			setup:  func(t *testing.T, e *testEnv) { assignTicket(t, e, 1, mod) },
+			req:    apiRequest{method: "GET", path: "/posts/queue", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 0)
+			},
+		},
+		{name: "customer", req: apiRequest{method: "GET", path: "/posts/queue", as: alice}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Posts.List"}, req: apiRequest{method: "GET", path: "/posts/queue", as: agent}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestListPostsByTicket(t *testing.T) {
+	setup := func(t *testing.T, e *testEnv) {
+		assignTicket(t, e, 1, agent)
+		moveTicket(t, e, 1, models.StatusOpen, models.StatusInProgress)
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by status",
+			setup:  setup,
+			req:    apiRequest{method: "GET", path: "/posts?status=in_progress,awaiting_customer"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := decode[ticketPage](t, body); got.Total != 1 || got.Data[0].ID_Posts != 1 {
+					t.Errorf("page = %s", body)
+				}
+			},
+		},
+		{
+			name:   "unassigned",
+			setup:  setup,
+			req:    apiRequest{method: "GET", path: "/posts?assignee=none"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := decode[ticketPage](t, body); got.Total != 1 || got.Data[0].ID_Posts != 2 {
+					t.Errorf("page = %s", body)
+				}
+			},
+		},
+		{name: "invalid status", req: apiRequest{method: "GET", path: "/posts?status=done"}, status: http.StatusBadRequest},
+		{name: "invalid assignee", req: apiRequest{method: "GET", path: "/posts?assignee=me"}, status: http.StatusBadRequest},
+	})
+}
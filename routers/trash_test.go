@@ -0,0 +1,227 @@
+package routes_test
+
+import (
+	"backend-nagaricare/models"
+	"backend-nagaricare/repository"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// trashPost moves a seeded post to the trash
+func trashPost(t *testing.T, e *testEnv, postID int) {
+	t.Helper()
+	if err := e.store.Posts().Delete(context.Background(), postID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// trashUser moves a seeded user to the trash
+func trashUser(t *testing.T, e *testEnv, userID int) {
+	t.Helper()
+	if err := e.store.Users().Delete(context.Background(), userID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeletedPostIsHidden(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{name: "get", setup: func(t *testing.T, e *testEnv) { trashPost(t, e, 1) }, req: apiRequest{method: "GET", path: "/posts/1"}, status: http.StatusNotFound},
+		{name: "update", setup: func(t *testing.T, e *testEnv) { trashPost(t, e, 1) }, req: apiRequest{method: "PUT", path: "/posts/1", body: map[string]any{"title": "t", "content": "c", "version": 1}, as: alice}, status: http.StatusNotFound},
+		{name: "comments", setup: func(t *testing.T, e *testEnv) { trashPost(t, e, 1) }, req: apiRequest{method: "GET", path: "/posts/1/comments"}, status: http.StatusNotFound},
+		{
+			name:   "listing",
+			setup:  func(t *testing.T, e *testEnv) { trashPost(t, e, 1) },
+			req:    apiRequest{method: "GET", path: "/posts"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 1)
+			},
+		},
+		{
+			name:   "search",
+			setup:  func(t *testing.T, e *testEnv) { trashPost(t, e, 1) },
+			req:    apiRequest{method: "GET", path: "/posts/search?q=transfer"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "total", 0)
+			},
+		},
+	})
+}
+
+func TestGetPostTrash(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "admin",
+			setup:  func(t *testing.T, e *testEnv) { trashPost(t, e, 1) },
+			req:    apiRequest{method: "GET", path: "/posts/trash", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				page := decode[struct {
+					Data  []map[string]any `json:"data"`
+					Total int              `json:"total"`
+				}](t, body)
+				if page.Total != 1 || len(page.Data) != 1 || page.Data[0]["id_posts"] != float64(1) {
+					t.Fatalf("trash = %s", body)
+				}
+				if page.Data[0]["deleted_at"] == nil {
+					t.Error("deleted_at missing")
+				}
+			},
+		},
+		{name: "bad filter", req: apiRequest{method: "GET", path: "/posts/trash?limit=0", as: admin}, status: http.StatusBadRequest},
+		{name: "moderator", req: apiRequest{method: "GET", path: "/posts/trash", as: mod}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "GET", path: "/posts/trash"}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Posts.List"}, req: apiRequest{method: "GET", path: "/posts/trash", as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestRestorePost(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "admin",
+			setup:  func(t *testing.T, e *testEnv) { trashPost(t, e, 1) },
+			req:    apiRequest{method: "POST", path: "/posts/1/restore", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if resp, body := e.do(t, apiRequest{method: "GET", path: "/posts/1"}); resp.StatusCode != http.StatusOK {
+					t.Errorf("restored post: %d %s", resp.StatusCode, body)
+				}
+			},
+		},
+		{name: "not in trash", req: apiRequest{method: "POST", path: "/posts/1/restore", as: admin}, status: http.StatusNotFound},
+		{name: "unknown post", req: apiRequest{method: "POST", path: "/posts/99/restore", as: admin}, status: http.StatusNotFound},
+		{name: "author", setup: func(t *testing.T, e *testEnv) { trashPost(t, e, 1) }, req: apiRequest{method: "POST", path: "/posts/1/restore", as: alice}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Posts.Restore"}, setup: func(t *testing.T, e *testEnv) { trashPost(t, e, 1) }, req: apiRequest{method: "POST", path: "/posts/1/restore", as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestDeleteUser(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "own account",
+			req:    apiRequest{method: "DELETE", path: "/users/1", as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if resp, _ := e.do(t, apiRequest{method: "GET", path: "/users/1", as: admin}); resp.StatusCode != http.StatusNotFound {
+					t.Errorf("deleted user: status %d", resp.StatusCode)
+				}
+				if resp, _ := e.do(t, apiRequest{method: "GET", path: "/posts/user/1"}); resp.StatusCode != http.StatusOK {
+					t.Errorf("posts of deleted user: status %d", resp.StatusCode)
+				}
+				// The deleted user's session no longer works
+				if resp, _ := e.do(t, apiRequest{method: "POST", path: "/posts", body: map[string]any{"title": "t", "content": "c", "version": 1}, as: alice}); resp.StatusCode != http.StatusUnauthorized {
+					t.Errorf("token of deleted user: status %d", resp.StatusCode)
+				}
+			},
+		},
+		{name: "admin", req: apiRequest{method: "DELETE", path: "/users/2", as: admin}, status: http.StatusOK},
+		{name: "other user", req: apiRequest{method: "DELETE", path: "/users/2", as: alice}, status: http.StatusForbidden},
+		{name: "moderator", req: apiRequest{method: "DELETE", path: "/users/2", as: mod}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "DELETE", path: "/users/2"}, status: http.StatusUnauthorized},
+		{name: "unknown user", req: apiRequest{method: "DELETE", path: "/users/99", as: admin}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Users.Delete"}, req: apiRequest{method: "DELETE", path: "/users/1", as: alice}, status: http.StatusInternalServerError},
+		{
+			name:   "email stays taken",
+			setup:  func(t *testing.T, e *testEnv) { trashUser(t, e, bob) },
+			req:    apiRequest{method: "POST", path: "/users/signin", body: map[string]any{"id_token": googleIDToken("bob@example.com")}},
+			status: http.StatusForbidden,
+		},
+	})
+}
+
+func TestGetUserTrash(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "most recent first",
+			setup: func(t *testing.T, e *testEnv) {
+				trashUser(t, e, alice)
+				trashUser(t, e, bob)
+			},
+			req:    apiRequest{method: "GET", path: "/users/trash", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				users := decode[[]map[string]any](t, body)
+				if len(users) != 2 || users[0]["id_user"] != float64(bob) || users[1]["id_user"] != float64(alice) {
+					t.Fatalf("trash = %s", body)
+				}
+				if users[0]["deleted_at"] == nil {
+					t.Error("deleted_at missing")
+				}
+			},
+		},
+		{name: "moderator", req: apiRequest{method: "GET", path: "/users/trash", as: mod}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Users.ListDeleted"}, req: apiRequest{method: "GET", path: "/users/trash", as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestRestoreUser(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "admin",
+			setup:  func(t *testing.T, e *testEnv) { trashUser(t, e, bob) },
+			req:    apiRequest{method: "POST", path: "/users/2/restore", as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if resp, _ := e.do(t, apiRequest{method: "GET", path: "/users/2", as: admin}); resp.StatusCode != http.StatusOK {
+					t.Errorf("restored user: status %d", resp.StatusCode)
+				}
+			},
+		},
+		{name: "not in trash", req: apiRequest{method: "POST", path: "/users/2/restore", as: admin}, status: http.StatusNotFound},
+		{name: "moderator", setup: func(t *testing.T, e *testEnv) { trashUser(t, e, bob) }, req: apiRequest{method: "POST", path: "/users/2/restore", as: mod}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Users.Restore"}, setup: func(t *testing.T, e *testEnv) { trashUser(t, e, bob) }, req: apiRequest{method: "POST", path: "/users/2/restore", as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestPurgeTrash(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+
+	attach(t, e, 1, alice, pngFile(t, "x.png"))
+	attach(t, e, 2, bob, pdfFile("y.pdf", 50))
+	if err := e.store.Comments().Create(ctx, &models.Comment{ID_post: 2, ID_user: alice, Content: "Try the app"}); err != nil {
+		t.Fatal(err)
+	}
+	trashPost(t, e, 1)
+	trashUser(t, e, alice)
+	// Everything trashed before the cutoff is purged, later deletions are kept
+	cutoff := e.store.Now()
+	trashPost(t, e, 2)
+
+	posts, users, err := e.handler.PurgeTrash(ctx, cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts != 1 || users != 1 {
+		t.Errorf("purged %d posts and %d users, want 1 and 1", posts, users)
+	}
+	if files := storedAttachments(e, 1); len(files) != 0 {
+		t.Errorf("files of purged post left behind: %v", files)
+	}
+	if files := storedAttachments(e, 2); len(files) != 1 {
+		t.Errorf("files of post still in trash: %v", files)
+	}
+
+	// Purged rows are gone for good, the later deletion can still be restored
+	if err := e.store.Posts().Restore(ctx, 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("restore purged post: %v", err)
+	}
+	if err := e.store.Users().Restore(ctx, alice); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("restore purged user: %v", err)
+	}
+	if err := e.store.Posts().Restore(ctx, 2); err != nil {
+		t.Errorf("restore post 2: %v", err)
+	}
+
+	// The purged user's comments went with them
+	comments, _, err := e.store.Comments().List(ctx, 2, repository.CommentFilter{View: repository.CommentViewFlat, Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 0 {
+		t.Errorf("comments of purged user kept: %d", len(comments))
+	}
+}
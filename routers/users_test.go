@@ -0,0 +1,521 @@
+package routes_test
+
+import (
+	"backend-nagaricare/imaging"
+	"backend-nagaricare/models"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPNG returns a small valid PNG image
+func testPNG(t *testing.T) []byte {
+	return testPNGSized(t, 8, 8)
+}
+
+func testPNGSized(t *testing.T, w, h int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadPicture sets a profile picture for the user through the API
+func uploadPicture(t *testing.T, e *testEnv, userID int) {
+	t.Helper()
+	resp, body := e.do(t, apiRequest{method: "PUT", path: fmt.Sprintf("/users/uploadprofilepicture/%d", userID), body: multipartFile{field: "profile_picture", filename: "me.png", data: testPNGSized(t, 40, 30)}, as: userID})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload failed: %s", body)
+	}
+}
+
+// wantImageSize fails unless body is an image of the given dimensions
+func wantImageSize(t *testing.T, body []byte, width, height int) {
+	t.Helper()
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("response is not an image: %v", err)
+	}
+	if cfg.Width != width || cfg.Height != height {
+		t.Errorf("image is %dx%d, want %dx%d", cfg.Width, cfg.Height, width, height)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	newUser := map[string]any{"email": "carol@example.com", "name": "Carol"}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by admin",
+			req:    apiRequest{method: "POST", path: "/users", body: newUser, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "id_user", 6)
+				user, err := e.store.Users().GetByEmail(context.Background(), "carol@example.com")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if user.Role != models.RoleCustomer {
+					t.Errorf("role = %q, want customer", user.Role)
+				}
+			},
+		},
+		{name: "invalid role", req: apiRequest{method: "POST", path: "/users", body: map[string]any{"email": "carol@example.com", "role": "owner"}, as: admin}, status: http.StatusBadRequest},
+		{name: "duplicate email", req: apiRequest{method: "POST", path: "/users", body: map[string]any{"email": "bob@example.com"}, as: admin}, status: http.StatusBadRequest},
+		{name: "invalid body", req: apiRequest{method: "POST", path: "/users", body: "{", as: admin}, status: http.StatusBadRequest},
+		{name: "by customer", req: apiRequest{method: "POST", path: "/users", body: newUser, as: alice}, status: http.StatusForbidden},
+		{name: "database error", fail: []string{"Users.Create"}, req: apiRequest{method: "POST", path: "/users", body: newUser, as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestSignInGoogle(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "creates the user on first sign-in",
+			req:    apiRequest{method: "POST", path: "/users/signin", body: map[string]any{"id_token": googleIDToken("new@example.com")}},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				signIn := decode[struct {
+					AccessToken string      `json:"access_token"`
+					User        models.User `json:"user"`
+				}](t, body)
+				if signIn.User.ID_user != 6 || signIn.User.Role != models.RoleCustomer {
+					t.Errorf("user = %+v", signIn.User)
+				}
+				if id, err := testTokens.ParseAccess(signIn.AccessToken); err != nil || id != 6 {
+					t.Errorf("access token for %d: %v", id, err)
+				}
+			},
+		},
+		{
+			name:   "existing user",
+			req:    apiRequest{method: "POST", path: "/users/signin", body: map[string]any{"id_token": googleIDToken("bob@example.com")}},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				signIn := decode[struct {
+					User models.User `json:"user"`
+				}](t, body)
+				if signIn.User.ID_user != bob {
+					t.Errorf("signed in as %d, want %d", signIn.User.ID_user, bob)
+				}
+			},
+		},
+		{name: "missing token", req: apiRequest{method: "POST", path: "/users/signin", body: map[string]any{}}, status: http.StatusBadRequest},
+		{name: "forged token", req: apiRequest{method: "POST", path: "/users/signin", body: map[string]any{"id_token": mustIssue(bob).AccessToken}}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Users.GetByEmail"}, req: apiRequest{method: "POST", path: "/users/signin", body: map[string]any{"id_token": googleIDToken("bob@example.com")}}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{name: "valid", req: apiRequest{method: "POST", path: "/users/refresh", body: map[string]any{"refresh_token": mustIssue(bob).RefreshToken}}, status: http.StatusOK},
+		{name: "missing token", req: apiRequest{method: "POST", path: "/users/refresh", body: "{}"}, status: http.StatusBadRequest},
+		{name: "access token", req: apiRequest{method: "POST", path: "/users/refresh", body: map[string]any{"refresh_token": mustIssue(bob).AccessToken}}, status: http.StatusUnauthorized},
+		{name: "deleted user", req: apiRequest{method: "POST", path: "/users/refresh", body: map[string]any{"refresh_token": mustIssue(99).RefreshToken}}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Users.GetByID"}, req: apiRequest{method: "POST", path: "/users/refresh", body: map[string]any{"refresh_token": mustIssue(bob).RefreshToken}}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetUsers(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by moderator",
+			req:    apiRequest{method: "GET", path: "/users", as: mod},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if users := decode[[]models.User](t, body); len(users) != 5 {
+					t.Errorf("got %d users, want 5", len(users))
+				}
+			},
+		},
+		{name: "by customer", req: apiRequest{method: "GET", path: "/users", as: alice}, status: http.StatusForbidden},
+		{name: "anonymous", req: apiRequest{method: "GET", path: "/users"}, status: http.StatusUnauthorized},
+		{name: "invalid token", req: apiRequest{method: "GET", path: "/users", as: 99}, status: http.StatusUnauthorized},
+		{name: "database error", fail: []string{"Users.List"}, req: apiRequest{method: "GET", path: "/users", as: mod}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetUserDetails(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "own account",
+			req:    apiRequest{method: "GET", path: "/users/2", as: bob},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "email", "bob@example.com")
+				if got := resp.Header.Get("ETag"); got != `"1"` {
+					t.Errorf("ETag = %s", got)
+				}
+			},
+		},
+		{
+			name:   "by agent",
+			req:    apiRequest{method: "GET", path: "/users/2", as: agent},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "role", "customer")
+			},
+		},
+		{
+			name:   "by another customer",
+			req:    apiRequest{method: "GET", path: "/users/2", as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				user := decode[map[string]any](t, body)
+				if len(user) != 3 || user["id_user"] != float64(bob) || user["name"] == nil {
+					t.Errorf("unexpected public user: %s", body)
+				}
+				if got := resp.Header.Get("ETag"); got != "" {
+					t.Errorf("ETag = %s", got)
+				}
+			},
+		},
+		{name: "anonymous", req: apiRequest{method: "GET", path: "/users/2"}, status: http.StatusUnauthorized},
+		{name: "invalid id", req: apiRequest{method: "GET", path: "/users/abc", as: admin}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "GET", path: "/users/99", as: admin}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Users.GetByID"}, req: apiRequest{method: "GET", path: "/users/2", as: admin}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestSaveUserPhoto(t *testing.T) {
+	upload := func(t *testing.T) multipartFile {
+		return multipartFile{field: "profile_picture", filename: "me.gif", data: testPNG(t)}
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by owner",
+			req:    apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: upload(t), as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				user, _ := e.store.Users().GetByID(context.Background(), alice)
+				if user.Picture == nil {
+					t.Fatal("profile picture not stored")
+				}
+				wantField(t, body, "profile_picture", *user.Picture)
+				if filepath.Ext(*user.Picture) != ".png" {
+					t.Errorf("stored as %s, want the sniffed .png extension", *user.Picture)
+				}
+				if _, err := os.Stat(filepath.Join(e.uploadDir, "userProfile", filepath.Base(*user.Picture))); err != nil {
+					t.Errorf("uploaded file missing: %v", err)
+				}
+				if files, _ := os.ReadDir(filepath.Join(e.uploadDir, "userProfile")); len(files) != 4 {
+					t.Errorf("got %d files, want the original and 3 thumbnails", len(files))
+				}
+			},
+		},
+		{
+			name:   "replacing removes the old files",
+			setup:  func(t *testing.T, e *testEnv) { uploadPicture(t, e, alice) },
+			req:    apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: upload(t), as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if files, _ := os.ReadDir(filepath.Join(e.uploadDir, "userProfile")); len(files) != 4 {
+					t.Errorf("got %d files, want only the new picture and its thumbnails", len(files))
+				}
+			},
+		},
+		{name: "by admin", req: apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: upload(t), as: admin}, status: http.StatusOK},
+		{
+			name:   "without a file clears the picture",
+			req:    apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: multipartFile{field: "other", filename: "x.txt"}, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "profile_picture", nil)
+			},
+		},
+		{name: "by another customer", req: apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: upload(t), as: bob}, status: http.StatusForbidden},
+		{name: "invalid id", req: apiRequest{method: "PUT", path: "/users/uploadprofilepicture/abc", body: upload(t), as: admin}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "PUT", path: "/users/uploadprofilepicture/99", body: upload(t), as: admin}, status: http.StatusNotFound},
+		{
+			name:   "too large",
+			req:    apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: multipartFile{field: "profile_picture", filename: "big.png", data: make([]byte, testMaxUpload+1)}, as: alice},
+			status: http.StatusRequestEntityTooLarge,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "file_too_large")
+			},
+		},
+		{
+			name:   "not an image",
+			req:    apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: multipartFile{field: "profile_picture", filename: "me.png", data: []byte("<svg onload=alert(1)>")}, as: alice},
+			status: http.StatusUnsupportedMediaType,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "unsupported_type")
+			},
+		},
+		{
+			name:   "corrupt image",
+			req:    apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: multipartFile{field: "profile_picture", filename: "me.png", data: testPNG(t)[:30]}, as: alice},
+			status: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "invalid_image")
+			},
+		},
+		{
+			name:   "too many pixels",
+			req:    apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: multipartFile{field: "profile_picture", filename: "me.png", data: testPNGSized(t, testMaxPixels+1, 1)}, as: alice},
+			status: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "code", "dimensions_too_large")
+			},
+		},
+		{name: "database error", fail: []string{"Users.SetPicture"}, req: apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: upload(t), as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetUserPhoto(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name: "image",
+			setup: func(t *testing.T, e *testEnv) {
+				e.do(t, apiRequest{method: "PUT", path: "/users/uploadprofilepicture/1", body: multipartFile{field: "profile_picture", filename: "me.png", data: testPNG(t)}, as: alice})
+			},
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := resp.Header.Get("Content-Type"); got != "image/png" {
+					t.Errorf("Content-Type = %q", got)
+				}
+				if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+					t.Errorf("response is not a PNG: %v", err)
+				}
+			},
+		},
+		{
+			name:   "standard thumbnail",
+			setup:  func(t *testing.T, e *testEnv) { uploadPicture(t, e, alice) },
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1?size=128"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantImageSize(t, body, 128, 128)
+				if files, _ := os.ReadDir(e.cacheDir); len(files) != 0 {
+					t.Errorf("standard size was resized on demand")
+				}
+			},
+		},
+		{
+			name:   "other size is resized and cached",
+			setup:  func(t *testing.T, e *testEnv) { uploadPicture(t, e, alice) },
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1?size=100"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantImageSize(t, body, 100, 100)
+				if files, _ := os.ReadDir(e.cacheDir); len(files) != 1 {
+					t.Fatalf("got %d cached files, want 1", len(files))
+				}
+
+				// Served from the cache even once the original is gone
+				user, _ := e.store.Users().GetByID(context.Background(), alice)
+				os.Remove(filepath.Join(e.uploadDir, "userProfile", filepath.Base(*user.Picture)))
+				_, body = e.do(t, apiRequest{method: "GET", path: "/users/profilepicture/1?size=100"})
+				wantImageSize(t, body, 100, 100)
+			},
+		},
+		{
+			name:   "size too small",
+			setup:  func(t *testing.T, e *testEnv) { uploadPicture(t, e, alice) },
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1?size=8"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "size not a number",
+			setup:  func(t *testing.T, e *testEnv) { uploadPicture(t, e, alice) },
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1?size=large"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no picture draws an avatar",
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := resp.Header.Get("Content-Type"); got != "image/png" {
+					t.Errorf("Content-Type = %q", got)
+				}
+				wantImageSize(t, body, imaging.DefaultAvatarSize, imaging.DefaultAvatarSize)
+			},
+		},
+		{
+			name:   "avatar at any size",
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1?size=48"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantImageSize(t, body, 48, 48)
+			},
+		},
+		{
+			name:   "avatar as svg",
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1?format=svg&size=48"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if got := resp.Header.Get("Content-Type"); got != "image/svg+xml" {
+					t.Errorf("Content-Type = %q", got)
+				}
+				if !strings.Contains(string(body), `width="48"`) || !strings.Contains(string(body), ">A</text>") {
+					t.Errorf("unexpected SVG: %s", body)
+				}
+			},
+		},
+		{name: "avatar format unknown", req: apiRequest{method: "GET", path: "/users/profilepicture/1?format=gif"}, status: http.StatusBadRequest},
+		{
+			name: "missing file falls back to the avatar",
+			setup: func(t *testing.T, e *testEnv) {
+				missing := "/userProfile/gone.png"
+				e.store.Users().SetPicture(context.Background(), alice, &missing)
+			},
+			req:    apiRequest{method: "GET", path: "/users/profilepicture/1"},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantImageSize(t, body, imaging.DefaultAvatarSize, imaging.DefaultAvatarSize)
+			},
+		},
+		{name: "invalid id", req: apiRequest{method: "GET", path: "/users/profilepicture/abc"}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "GET", path: "/users/profilepicture/99"}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Users.GetByID"}, req: apiRequest{method: "GET", path: "/users/profilepicture/1"}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestGetUserPhotoCaching(t *testing.T) {
+	e := newTestEnv(t)
+	uploadPicture(t, e, alice)
+
+	resp, body := e.do(t, apiRequest{method: "GET", path: "/users/profilepicture/1"})
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("missing validators: ETag %q, Last-Modified %q", etag, lastModified)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	wantImageSize(t, body, 40, 30)
+
+	for _, tc := range []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"matching etag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"etag in a list", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"stale etag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"etag wins over date", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": "Mon, 01 Jan 2001 00:00:00 GMT"}, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := e.do(t, apiRequest{method: "GET", path: "/users/profilepicture/1", header: tc.header})
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tc.status)
+			}
+			if tc.status == http.StatusNotModified && len(body) != 0 {
+				t.Errorf("304 with a %d byte body", len(body))
+			}
+		})
+	}
+
+	// Each size has its own validators
+	resp, _ = e.do(t, apiRequest{method: "GET", path: "/users/profilepicture/1?size=64", header: map[string]string{"If-None-Match": etag}})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("thumbnail revalidated against the original: status %d", resp.StatusCode)
+	}
+
+	// Generated avatars revalidate the same way
+	resp, _ = e.do(t, apiRequest{method: "GET", path: "/users/profilepicture/2"})
+	avatarETag := resp.Header.Get("ETag")
+	if avatarETag == "" || resp.Header.Get("Cache-Control") != "public, max-age=60" {
+		t.Errorf("avatar headers: %v", resp.Header)
+	}
+	resp, _ = e.do(t, apiRequest{method: "GET", path: "/users/profilepicture/2", header: map[string]string{"If-None-Match": avatarETag}})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("avatar revalidation: status %d", resp.StatusCode)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	edit := map[string]any{"name": "Alice B", "phone": "0812", "version": 1}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by owner",
+			req:    apiRequest{method: "PUT", path: "/users/1", body: edit, as: alice},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				wantField(t, body, "version", 2)
+				user, _ := e.store.Users().GetByID(context.Background(), alice)
+				if user.Email != "alice@example.com" || user.Name != "Alice B" || user.Phone == nil || *user.Phone != "0812" || user.Version != 2 {
+					t.Errorf("stored user = %+v", user)
+				}
+			},
+		},
+		{
+			name: "stale after role change",
+			setup: func(t *testing.T, e *testEnv) {
+				e.store.Users().UpdateRole(context.Background(), alice, models.RoleAgent)
+			},
+			req:    apiRequest{method: "PUT", path: "/users/1", body: edit, as: alice},
+			status: http.StatusConflict,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				got := decode[struct {
+					Current models.User `json:"current"`
+				}](t, body)
+				if got.Current.Role != models.RoleAgent || got.Current.Version != 2 || resp.Header.Get("ETag") != `"2"` {
+					t.Errorf("conflict = %s, ETag %s", body, resp.Header.Get("ETag"))
+				}
+				if user, _ := e.store.Users().GetByID(context.Background(), alice); user.Name == "Alice B" {
+					t.Errorf("stored user = %+v", user)
+				}
+			},
+		},
+		{name: "version in If-Match", req: apiRequest{method: "PUT", path: "/users/1", body: map[string]any{"name": "Alice B"}, as: alice, header: map[string]string{"If-Match": `"1"`}}, status: http.StatusOK},
+		{name: "same email", req: apiRequest{method: "PUT", path: "/users/1", body: map[string]any{"email": "alice@example.com", "version": 1}, as: alice}, status: http.StatusOK},
+		{name: "no version", req: apiRequest{method: "PUT", path: "/users/1", body: map[string]any{"name": "Alice B"}, as: alice}, status: http.StatusPreconditionRequired},
+		{name: "by another customer", req: apiRequest{method: "PUT", path: "/users/1", body: edit, as: bob}, status: http.StatusForbidden},
+		{
+			name:   "changed email",
+			req:    apiRequest{method: "PUT", path: "/users/1", body: map[string]any{"email": "victim@example.com", "version": 1}, as: alice},
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				if user, _ := e.store.Users().GetByID(context.Background(), alice); user.Email != "alice@example.com" || user.Version != 1 {
+					t.Errorf("stored user = %+v", user)
+				}
+			},
+		},
+		{name: "changed email by admin", req: apiRequest{method: "PUT", path: "/users/1", body: map[string]any{"email": "alice@example.org", "version": 1}, as: admin}, status: http.StatusBadRequest},
+		{name: "invalid body", req: apiRequest{method: "PUT", path: "/users/1", body: "{", as: alice}, status: http.StatusBadRequest},
+		{name: "invalid id", req: apiRequest{method: "PUT", path: "/users/abc", body: edit, as: admin}, status: http.StatusBadRequest},
+		{name: "not found", req: apiRequest{method: "PUT", path: "/users/99", body: edit, as: admin}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Users.Update"}, req: apiRequest{method: "PUT", path: "/users/1", body: edit, as: alice}, status: http.StatusInternalServerError},
+	})
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	promote := map[string]any{"role": models.RoleAgent}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "by admin",
+			req:    apiRequest{method: "PUT", path: "/users/1/role", body: promote, as: admin},
+			status: http.StatusOK,
+			check: func(t *testing.T, e *testEnv, resp *http.Response, body []byte) {
+				user, _ := e.store.Users().GetByID(context.Background(), alice)
+				if user.Role != models.RoleAgent {
+					t.Errorf("role = %q, want agent", user.Role)
+				}
+			},
+		},
+		{name: "invalid role", req: apiRequest{method: "PUT", path: "/users/1/role", body: map[string]any{"role": "owner"}, as: admin}, status: http.StatusBadRequest},
+		{name: "by moderator", req: apiRequest{method: "PUT", path: "/users/1/role", body: promote, as: mod}, status: http.StatusForbidden},
+		{name: "not found", req: apiRequest{method: "PUT", path: "/users/99/role", body: promote, as: admin}, status: http.StatusNotFound},
+		{name: "database error", fail: []string{"Users.UpdateRole"}, req: apiRequest{method: "PUT", path: "/users/1/role", body: promote, as: admin}, status: http.StatusInternalServerError},
+	})
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// snippetLength is the approximate number of characters in a snippet
+const snippetLength = 160
+
+// words splits text into lowercase words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Terms splits a query into distinct lowercase words
+func Terms(text string) []string {
+	fields := words(text)
+
+	// Drop duplicates while keeping the order
+	seen := make(map[string]bool, len(fields))
+	terms := fields[:0]
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// Highlight returns an HTML-escaped excerpt of text centred on the first
+// matching term, with every occurrence of a term wrapped in <mark> tags
+func Highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes)) // Lowercased rune by rune so indexes line up
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// Locate the first match to centre the excerpt on it
+	first := -1
+	for _, term := range terms {
+		if i := indexRunes(lower, []rune(term), 0); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	start, end := 0, len(runes)
+	if len(runes) > snippetLength {
+		if first > snippetLength/4 {
+			start = first - snippetLength/4
+		}
+		end = min(start+snippetLength, len(runes))
+		start = max(end-snippetLength, 0)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, term := range terms {
+			tr := []rune(term)
+			if i+len(tr) <= end && string(lower[i:i+len(tr)]) == term && len(tr) > matched {
+				matched = len(tr)
+			}
+		}
+		if matched > 0 {
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(string(runes[i : i+matched])))
+			b.WriteString("</mark>")
+			i += matched
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// indexRunes finds needle in haystack starting at from, or returns -1
+func indexRunes(haystack, needle []rune, from int) int {
+	for i := from; i+len(needle) <= len(haystack); i++ {
+		if string(haystack[i:i+len(needle)]) == string(needle) {
+			return i
+		}
+	}
+	return -1
+}
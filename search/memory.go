@@ -0,0 +1,164 @@
+package search
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MemoryIndex is an in-process index for tests and deployments without MySQL.
+// Documents are scored by term frequency, with title matches weighted double
+// and solved threads boosted by SolvedBoost.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	posts    map[int]Document // Keyed by post ID
+	comments map[int]Document // Keyed by comment ID
+}
+
+// NewMemoryIndex creates an empty index
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		posts:    map[int]Document{},
+		comments: map[int]Document{},
+	}
+}
+
+// Index adds or replaces a post or comment
+func (m *MemoryIndex) Index(doc Document) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if doc.CommentID != nil {
+		m.comments[*doc.CommentID] = doc
+	} else {
+		m.posts[doc.PostID] = doc
+	}
+}
+
+// RemovePost removes a post together with its comments
+func (m *MemoryIndex) RemovePost(postID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.posts, postID)
+	for id, doc := range m.comments {
+		if doc.PostID == postID {
+			delete(m.comments, id)
+		}
+	}
+}
+
+// RemoveComment removes a single comment
+func (m *MemoryIndex) RemoveComment(commentID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.comments, commentID)
+}
+
+// Search returns one page of hits ordered by score, then newest first
+func (m *MemoryIndex) Search(ctx context.Context, q Query) (*Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := Terms(q.Text)
+	var hits []Hit
+	consider := func(doc Document) {
+		if !m.matchesFilters(doc, q) {
+			return
+		}
+		score := float64(2*countTerms(doc.Title, terms) + countTerms(doc.Content, terms))
+		if score == 0 {
+			return
+		}
+		if doc.Solved {
+			score *= SolvedBoost
+		}
+
+		hit := Hit{
+			PostID:    doc.PostID,
+			CommentID: doc.CommentID,
+			UserID:    doc.UserID,
+			Title:     doc.Title,
+			Snippet:   Highlight(doc.Content, terms),
+			Solved:    doc.Solved,
+			Score:     score,
+			CreatedAt: doc.CreatedAt,
+		}
+		if doc.CommentID != nil {
+			hit.Title = m.posts[doc.PostID].Title
+		}
+		hits = append(hits, hit)
+	}
+
+	for _, doc := range m.posts {
+		consider(doc)
+	}
+	for _, doc := range m.comments {
+		consider(doc)
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].CreatedAt.After(hits[j].CreatedAt)
+	})
+
+	result := &Result{Hits: []Hit{}, Total: len(hits)}
+	if q.Offset < len(hits) {
+		end := min(q.Offset+q.Limit, len(hits))
+		result.Hits = hits[q.Offset:end]
+	}
+	return result, nil
+}
+
+// matchesFilters applies the user, date and post filters of the query
+func (m *MemoryIndex) matchesFilters(doc Document, q Query) bool {
+	if q.UserID != 0 && q.UserID != doc.UserID {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, doc.Status) {
+		return false
+	}
+	if q.AssigneeID != 0 && (doc.AssigneeID == nil || *doc.AssigneeID != q.AssigneeID) {
+		return false
+	}
+	if q.Unassigned && doc.AssigneeID != nil {
+		return false
+	}
+	if q.CategoryID != 0 {
+		if _, ok := doc.Categories[q.CategoryID]; !ok {
+			return false
+		}
+	}
+	if q.CategorySlug != "" && !slices.Contains(slices.Collect(maps.Values(doc.Categories)), q.CategorySlug) {
+		return false
+	}
+	if q.Tag != "" && !slices.Contains(doc.Tags, q.Tag) {
+		return false
+	}
+	if q.Solved != nil && doc.Solved != *q.Solved {
+		return false
+	}
+	if q.From != nil && doc.CreatedAt.Before(*q.From) {
+		return false
+	}
+	if q.To != nil && !doc.CreatedAt.Before(*q.To) {
+		return false
+	}
+	return true
+}
+
+// countTerms counts how many words of text are one of the terms
+func countTerms(text string, terms []string) int {
+	count := 0
+	for _, word := range words(text) {
+		if slices.Contains(terms, word) {
+			count++
+		}
+	}
+	return count
+}
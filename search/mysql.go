@@ -0,0 +1,139 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MySQL searches posts and comments through FULLTEXT indexes on
+// posts(title, content) and comments(content)
+type MySQL struct {
+	db *sql.DB
+}
+
+// NewMySQL creates a searcher backed by the given database
+func NewMySQL(db *sql.DB) *MySQL {
+	return &MySQL{db: db}
+}
+
+// hitsQuery unions matching posts and comments outside the trash; each branch
+// repeats the search text
+const hitsQuery = `
+	SELECT p.id_posts, NULL AS id_comment, p.id_user, p.title, p.content, p.created_at, ` + solvedColumn + `, ` + postColumns + `,
+		MATCH(p.title, p.content) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+	FROM posts p
+	WHERE MATCH(p.title, p.content) AGAINST (? IN NATURAL LANGUAGE MODE) AND p.deleted_at IS NULL
+	UNION ALL
+	SELECT c.id_post, c.id_comment, c.id_user, p.title, c.content, c.created_at, ` + solvedColumn + `, ` + postColumns + `,
+		MATCH(c.content) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+	FROM comments c JOIN posts p ON p.id_posts = c.id_post
+	WHERE MATCH(c.content) AGAINST (? IN NATURAL LANGUAGE MODE) AND p.deleted_at IS NULL`
+
+// solvedColumn tells whether the post p of a hit has an accepted answer
+const solvedColumn = "EXISTS (SELECT 1 FROM comments a WHERE a.id_post = p.id_posts AND a.accepted_at IS NOT NULL) AS solved"
+
+// postColumns are the attributes of the post p of a hit that queries filter on
+const postColumns = "p.status, p.id_assignee, p.id_category"
+
+// Search runs the query and returns one page of hits ordered by relevance
+func (m *MySQL) Search(ctx context.Context, q Query) (*Result, error) {
+	args := []any{q.Text, q.Text, q.Text, q.Text}
+
+	// Apply the filters on the union of posts and comments
+	var conds []string
+	if q.UserID != 0 {
+		conds = append(conds, "id_user = ?")
+		args = append(args, q.UserID)
+	}
+	if len(q.Statuses) > 0 {
+		conds = append(conds, "status IN (?"+strings.Repeat(", ?", len(q.Statuses)-1)+")")
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+	if q.AssigneeID != 0 {
+		conds = append(conds, "id_assignee = ?")
+		args = append(args, q.AssigneeID)
+	}
+	if q.Unassigned {
+		conds = append(conds, "id_assignee IS NULL")
+	}
+	if q.CategoryID != 0 || q.CategorySlug != "" {
+		// Walk down the tree from the named category
+		root, key := "id_category = ?", any(q.CategoryID)
+		if q.CategoryID == 0 {
+			root, key = "slug = ?", q.CategorySlug
+		}
+		conds = append(conds, "id_category IN (WITH RECURSIVE subtree AS ("+
+			"SELECT id_category FROM categories WHERE "+root+
+			" UNION ALL SELECT categories.id_category FROM categories JOIN subtree ON categories.id_parent = subtree.id_category"+
+			") SELECT id_category FROM subtree)")
+		args = append(args, key)
+	}
+	if q.Tag != "" {
+		conds = append(conds, "id_posts IN (SELECT post_tags.id_post FROM post_tags JOIN tags ON tags.id_tag = post_tags.id_tag WHERE tags.name = ?)")
+		args = append(args, q.Tag)
+	}
+	if q.Solved != nil {
+		conds = append(conds, "solved = ?")
+		args = append(args, *q.Solved)
+	}
+	if q.From != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, q.From.Format("2006-01-02 15:04:05"))
+	}
+	if q.To != nil {
+		conds = append(conds, "created_at < ?")
+		args = append(args, q.To.Format("2006-01-02 15:04:05"))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+hitsQuery+") AS hits"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("count search hits: %w", err)
+	}
+
+	// The boost comes first as it is the first placeholder of the query
+	query := "SELECT id_posts, id_comment, id_user, title, content, created_at, solved, score * IF(solved, ?, 1) AS ranked FROM (" + hitsQuery + ") AS hits" + where +
+		" ORDER BY ranked DESC, created_at DESC LIMIT ? OFFSET ?"
+	rows, err := m.db.QueryContext(ctx, query, append(append([]any{SolvedBoost}, args...), q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("query search hits: %w", err)
+	}
+	defer rows.Close()
+
+	terms := Terms(q.Text)
+	result := &Result{Hits: []Hit{}, Total: total}
+	for rows.Next() {
+		var hit Hit
+		var commentID sql.NullInt64
+		var content, createdAtStr string
+		if err := rows.Scan(&hit.PostID, &commentID, &hit.UserID, &hit.Title, &content, &createdAtStr, &hit.Solved, &hit.Score); err != nil {
+			return nil, fmt.Errorf("scan search hit: %w", err)
+		}
+
+		if commentID.Valid {
+			id := int(commentID.Int64)
+			hit.CommentID = &id
+		}
+		hit.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAtStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		hit.Snippet = Highlight(content, terms)
+
+		result.Hits = append(result.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate search hits: %w", err)
+	}
+
+	return result, nil
+}
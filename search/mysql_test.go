@@ -0,0 +1,104 @@
+package search
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// hitColumns are the columns of the page query
+var hitColumns = []string{"id_posts", "id_comment", "id_user", "title", "content", "created_at", "solved", "ranked"}
+
+// searchMySQL runs q against a fake database answering the count and page
+// queries, and returns the statements it received
+func searchMySQL(t *testing.T, q Query, rows ...[]driver.Value) (*Result, []fakeStatement) {
+	t.Helper()
+	db, fake := newFakeDB(t, func(query string, args []any) fakeAnswer {
+		if strings.HasPrefix(query, "SELECT COUNT(*)") {
+			return fakeAnswer{columns: []string{"COUNT(*)"}, rows: [][]driver.Value{{int64(len(rows))}}}
+		}
+		return fakeAnswer{columns: hitColumns, rows: rows}
+	})
+
+	result, err := NewMySQL(db).Search(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, fake.log()
+}
+
+func TestMySQLSearchSkipsTrash(t *testing.T) {
+	_, statements := searchMySQL(t, Query{Text: "transfer", Limit: 20})
+	if len(statements) != 2 {
+		t.Fatalf("statements = %+v", statements)
+	}
+	for _, statement := range statements {
+		// Both the post and the comment branch must leave out trashed posts
+		branches := strings.Split(statement.query, "UNION ALL")
+		if len(branches) != 2 {
+			t.Fatalf("query = %s", statement.query)
+		}
+		for _, branch := range branches {
+			if !strings.Contains(branch, "p.deleted_at IS NULL") {
+				t.Errorf("branch does not skip the trash: %s", branch)
+			}
+		}
+	}
+}
+
+func TestMySQLSearchHits(t *testing.T) {
+	result, statements := searchMySQL(t, Query{Text: "transfer", Limit: 20, Offset: 20, UserID: 2},
+		[]driver.Value{int64(1), int64(4), int64(2), "Transfer failed", "Same here, the transfer failed", "2024-11-02 09:30:00", int64(1), 3.0},
+	)
+
+	if result.Total != 1 || len(result.Hits) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	hit := result.Hits[0]
+	if hit.PostID != 1 || hit.CommentID == nil || *hit.CommentID != 4 || hit.UserID != 2 || !hit.Solved || hit.Score != 3 {
+		t.Errorf("hit = %+v", hit)
+	}
+	if hit.Snippet != "Same here, the <mark>transfer</mark> failed" || hit.CreatedAt.Format("2006-01-02 15:04:05") != "2024-11-02 09:30:00" {
+		t.Errorf("hit = %+v", hit)
+	}
+
+	// The page query takes the boost, the text once per MATCH, the filters
+	// and the page
+	args := statements[1].args
+	if len(args) != 8 || args[0] != SolvedBoost || args[1] != "transfer" || args[5] != int64(2) || args[6] != int64(20) || args[7] != int64(20) {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestMySQLSearchFilters(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		q    Query
+		cond string
+		args []any
+	}{
+		{name: "statuses", q: Query{Statuses: []string{"open", "resolved"}}, cond: "WHERE status IN (?, ?)", args: []any{"open", "resolved"}},
+		{name: "assignee", q: Query{AssigneeID: 5}, cond: "WHERE id_assignee = ?", args: []any{int64(5)}},
+		{name: "unassigned", q: Query{Unassigned: true}, cond: "WHERE id_assignee IS NULL"},
+		{name: "category id", q: Query{CategoryID: 3}, cond: "WHERE id_category IN (WITH RECURSIVE subtree AS (SELECT id_category FROM categories WHERE id_category = ?", args: []any{int64(3)}},
+		{name: "tag", q: Query{Tag: "otp"}, cond: "WHERE id_posts IN (SELECT post_tags.id_post FROM post_tags JOIN tags ON tags.id_tag = post_tags.id_tag WHERE tags.name = ?)", args: []any{"otp"}},
+		{name: "solved", q: Query{Solved: new(bool)}, cond: "WHERE solved = ?", args: []any{false}},
+		{name: "category slug", q: Query{CategorySlug: "cards"}, cond: "WHERE id_category IN (WITH RECURSIVE subtree AS (SELECT id_category FROM categories WHERE slug = ?", args: []any{"cards"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.q.Text, tc.q.Limit = "transfer", 20
+			_, statements := searchMySQL(t, tc.q)
+
+			count := statements[0]
+			if !strings.Contains(count.query, ") AS hits "+tc.cond) {
+				t.Errorf("query = %s", count.query)
+			}
+			// The filter arguments follow the search text of each MATCH
+			if got := count.args[4:]; fmt.Sprint(got) != fmt.Sprint(tc.args) {
+				t.Errorf("args = %v, want %v", got, tc.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"time"
+)
+
+// SolvedBoost multiplies the score of hits in threads with an accepted
+// answer, so solved questions come before equally relevant open ones
+const SolvedBoost = 1.5
+
+// Query describes a full-text search over posts and comments
+type Query struct {
+	Text   string
+	Limit  int
+	Offset int
+	UserID int        // Only hits written by this id_user when non-zero
+	From   *time.Time // Inclusive lower bound on created_at
+	To     *time.Time // Exclusive upper bound on created_at
+
+	// Filters on the post a hit belongs to, as for post listings
+	Statuses   []string // Only posts in one of these ticket statuses when set
+	AssigneeID int      // Only posts assigned to this user when non-zero
+	Unassigned bool     // Only posts nobody is assigned to
+
+	// Only posts in the category with this ID or slug, or in one of its
+	// subcategories, when set
+	CategoryID   int
+	CategorySlug string
+
+	Tag    string // Only posts carrying this tag when set
+	Solved *bool  // Only posts with, or without, an accepted answer when set
+}
+
+// Hit is a post or comment matching a query
+type Hit struct {
+	PostID    int       `json:"id_posts"`
+	CommentID *int      `json:"id_comment"` // Set when the match is a comment on the post
+	UserID    int       `json:"id_user"`
+	Title     string    `json:"title"`   // Title of the post the hit belongs to
+	Snippet   string    `json:"snippet"` // HTML-escaped excerpt with matches wrapped in <mark>
+	Solved    bool      `json:"solved"`  // The post has an accepted answer
+	Score     float64   `json:"score"`   // Relevance, boosted by SolvedBoost in solved threads
+	CreatedAt time.Time `json:"-"`
+}
+
+// Result is one page of hits ordered by relevance
+type Result struct {
+	Hits  []Hit
+	Total int
+}
+
+// Searcher runs relevance-ranked searches
+type Searcher interface {
+	Search(ctx context.Context, q Query) (*Result, error)
+}
+
+// Document is a post or comment as seen by an index
+type Document struct {
+	PostID    int
+	CommentID *int // nil for the post itself
+	UserID    int
+	Title     string // Post title, empty for comments
+	Content   string
+	CreatedAt time.Time
+	Solved    bool // The post has an accepted answer
+
+	// Attributes of the post, shared by its comments
+	Status     string         // Ticket status
+	AssigneeID *int           // Agent working on the post, nil when unassigned
+	Categories map[int]string // Category of the post and its ancestors, ID to slug
+	Tags       []string       // Tags of the post
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"userProfile/1.png": true,
+		"a/b/c":             true,
+		"":                  false,
+		"/etc/passwd":       false,
+		"../secret":         false,
+		"a/../../b":         false,
+		"a//b":              false,
+		"a\\b":              false,
+		"./a":               false,
+	} {
+		if got := ValidKey(key); got != want {
+			t.Errorf("ValidKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLocal(dir, "/files", []byte("secret"))
+	ctx := context.Background()
+
+	if err := l.Put(ctx, "userProfile/1.png", strings.NewReader("png bytes"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "userProfile", "1.png")); err != nil {
+		t.Fatalf("file not written: %v", err)
+	}
+
+	obj, err := l.Get(ctx, "userProfile/1.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(data) != "png bytes" || obj.Size != 9 || obj.ContentType != "image/png" || obj.ETag == "" {
+		t.Errorf("got %q %+v", data, obj)
+	}
+
+	if err := l.Delete(ctx, "userProfile/1.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Get(ctx, "userProfile/1.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: %v", err)
+	}
+	if err := l.Delete(ctx, "userProfile/1.png"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+	if _, err := l.Get(ctx, "userProfile"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get on a directory: %v", err)
+	}
+	if err := l.Put(ctx, "../outside", strings.NewReader(""), ""); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put outside the root: %v", err)
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	l := NewLocal(t.TempDir(), "/files/", []byte("secret"))
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+
+	signed, err := l.SignedURL(context.Background(), "attachments/my file.pdf", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/files/attachments/my file.pdf" {
+		t.Errorf("path = %s", u.Path)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	if err := l.Verify("attachments/my file.pdf", expires, signature); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := l.Verify("attachments/other.pdf", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify for another key: %v", err)
+	}
+	if err := l.Verify("attachments/my file.pdf", "1800000000", signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with a changed expiry: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := l.Verify("attachments/my file.pdf", expires, signature); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify after expiry: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"backend-nagaricare/config"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a MinIO-style stand-in serving path-style requests for one
+// bucket; it checks every request's signature against the test credentials
+type fakeS3 struct {
+	bucket  string
+	signer  signer
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("X-Amz-Signature") != "" {
+		f.checkPresigned(w, r)
+		return
+	}
+
+	// Recompute the signature over what arrived on the wire
+	got := r.Header.Get("Authorization")
+	check := r.Clone(r.Context())
+	check.Header = http.Header{}
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			check.Header[name] = values
+		}
+	}
+	f.signer.sign(check, r.Header.Get("X-Amz-Content-Sha256"), time.Time{})
+	if got == "" || got != check.Header.Get("Authorization") {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+	f.serveObject(w, r, key)
+}
+
+func (f *fakeS3) checkPresigned(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	date, _ := time.Parse(sigV4TimeFormat, query.Get("X-Amz-Date"))
+	u := *r.URL
+	u.Host = r.Host
+	u.RawQuery = ""
+	for name := range query {
+		if name != "X-Amz-Signature" {
+			u.RawQuery += "&" + name + "=" + url.QueryEscape(query.Get(name))
+		}
+	}
+	expires, _ := time.ParseDuration(query.Get("X-Amz-Expires") + "s")
+	resigned, _ := url.Parse(f.signer.presign(r.Method, &u, expires, date))
+	if resigned.Query().Get("X-Amz-Signature") != query.Get("X-Amz-Signature") {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+	f.serveObject(w, r, strings.TrimPrefix(r.URL.Path, "/"+f.bucket+"/"))
+}
+
+func (f *fakeS3) serveObject(w http.ResponseWriter, r *http.Request, key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC().Truncate(time.Second)}
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	case http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		sum := md5.Sum(obj.data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newFakeS3(t *testing.T) (*S3, *fakeS3) {
+	t.Helper()
+	cfg := config.S3Config{
+		Region:          "eu-west-1",
+		Bucket:          "avatars",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio-secret",
+		PathStyle:       true,
+	}
+	fake := &fakeS3{
+		bucket:  cfg.Bucket,
+		signer:  signer{accessKey: cfg.AccessKeyID, secretKey: cfg.SecretAccessKey, region: cfg.Region, service: "s3"},
+		objects: map[string]fakeObject{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg.Endpoint = server.URL
+	s3, err := NewS3(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3, fake
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	s3, fake := newFakeS3(t)
+	ctx := context.Background()
+	key := "userProfile/a file (1).png"
+
+	if err := s3.Put(ctx, key, strings.NewReader("png bytes"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects[key]; !ok {
+		t.Fatalf("object stored under %v", fake.objects)
+	}
+
+	obj, err := s3.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(data) != "png bytes" || obj.ContentType != "image/png" || obj.Size != 9 || obj.ETag == "" || obj.ModTime.IsZero() {
+		t.Errorf("got %q %+v", data, obj)
+	}
+
+	// A presigned URL works without credentials
+	signed, err := s3.SignedURL(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET signed URL: %s", resp.Status)
+	}
+
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: %v", err)
+	}
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestS3Errors(t *testing.T) {
+	s3, _ := newFakeS3(t)
+	ctx := context.Background()
+
+	if err := s3.Put(ctx, "../escape", strings.NewReader(""), ""); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put with invalid key: %v", err)
+	}
+
+	s3.signer.secretKey = "wrong"
+	err := s3.Put(ctx, "key", strings.NewReader("x"), "")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put with bad credentials: %v", err)
+	}
+}
+
+func TestS3VirtualHostURL(t *testing.T) {
+	s3, err := NewS3(config.S3Config{Endpoint: "https://s3.eu-west-1.amazonaws.com", Region: "eu-west-1", Bucket: "avatars"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s3.objectURL("userProfile/1 2.png").String(); got != "https://avatars.s3.eu-west-1.amazonaws.com/userProfile/1%202.png" {
+		t.Errorf("objectURL = %s", got)
+	}
+	if _, err := NewS3(config.S3Config{Endpoint: "s3.amazonaws.com"}); err == nil {
+		t.Error("endpoint without scheme accepted")
+	}
+}
@@ -0,0 +1,76 @@
+// Package storage keeps uploaded files in a local directory or an
+// S3-compatible bucket behind one interface
+package storage
+
+import (
+	"backend-nagaricare/config"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by Get when no object has the key
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrInvalidKey is returned for keys that could escape the storage root
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Object is a stored file opened for reading; the caller must close Body
+type Object struct {
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string // Quoted entity tag
+}
+
+// Storage stores files under slash-separated keys such as "userProfile/1.png"
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads the object without other
+	// credentials until the expiry has passed
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// ValidKey reports whether key is a relative slash-separated path without
+// empty, "." or ".." segments
+func ValidKey(key string) bool {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// FromConfig creates the configured storage backend
+func FromConfig(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Backend {
+	case config.StorageLocal:
+		secret := []byte(cfg.URLSecret)
+		if len(secret) == 0 {
+			// Signed URLs then stop working after a restart, like sessions without a token secret
+			log.Println("No storage URL secret configured, using a random secret")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, fmt.Errorf("generate storage URL secret: %w", err)
+			}
+		}
+		return NewLocal(cfg.LocalDir, "/files", secret), nil
+	case config.StorageS3:
+		return NewS3(cfg.S3)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+}